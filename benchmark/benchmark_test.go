@@ -26,6 +26,7 @@ import (
 
 //go:noinline
 func doInfoOneArg(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		log.Info("this is", "a", "string")
 	}
@@ -33,6 +34,7 @@ func doInfoOneArg(b *testing.B, log logr.Logger) {
 
 //go:noinline
 func doInfoSeveralArgs(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		log.Info("multi",
 			"bool", true, "string", "str", "int", 42,
@@ -42,6 +44,7 @@ func doInfoSeveralArgs(b *testing.B, log logr.Logger) {
 
 //go:noinline
 func doInfoPointerArgs(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	vBool := true
 	vString := "string"
 	vInt := 42
@@ -59,6 +62,7 @@ func doInfoPointerArgs(b *testing.B, log logr.Logger) {
 
 //go:noinline
 func doInfoWithValues(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	log = log.WithValues("k1", "str", "k2", 222, "k3", true, "k4", 1.0)
 	for i := 0; i < b.N; i++ {
 		log.Info("multi",
@@ -67,8 +71,43 @@ func doInfoWithValues(b *testing.B, log logr.Logger) {
 	}
 }
 
+type Tstruct struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace,omitempty"`
+	Count     int      `json:"count"`
+	Ratio     float64  `json:"ratio"`
+	Enabled   bool     `json:"enabled"`
+	Tags      []string `json:"tags,omitempty"`
+	Inner     struct {
+		X, Y int
+	} `json:"inner"`
+}
+
+//go:noinline
+func doInfoStruct(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
+	v := Tstruct{Name: "name", Count: 12345, Ratio: 0.75, Enabled: true, Tags: []string{"a", "b"}}
+	v.Inner.X, v.Inner.Y = 1000, 2000
+	for i := 0; i < b.N; i++ {
+		log.Info("struct", "struct", v)
+	}
+}
+
+//go:noinline
+func doInfoMap(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
+	m := map[string]int{
+		"one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+		"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+	}
+	for i := 0; i < b.N; i++ {
+		log.Info("map", "map", m)
+	}
+}
+
 //go:noinline
 func doV0Info(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		log.V(0).Info("multi",
 			"bool", true, "string", "str", "int", 42,
@@ -78,6 +117,7 @@ func doV0Info(b *testing.B, log logr.Logger) {
 
 //go:noinline
 func doV9Info(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		log.V(9).Info("multi",
 			"bool", true, "string", "str", "int", 42,
@@ -87,6 +127,7 @@ func doV9Info(b *testing.B, log logr.Logger) {
 
 //go:noinline
 func doError(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	err := fmt.Errorf("error message")
 	for i := 0; i < b.N; i++ {
 		log.Error(err, "multi",
@@ -97,6 +138,7 @@ func doError(b *testing.B, log logr.Logger) {
 
 //go:noinline
 func doWithValues(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		l := log.WithValues("k1", "v1", "k2", "v2")
 		_ = l
@@ -105,6 +147,7 @@ func doWithValues(b *testing.B, log logr.Logger) {
 
 //go:noinline
 func doWithName(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		l := log.WithName("name")
 		_ = l
@@ -113,6 +156,7 @@ func doWithName(b *testing.B, log logr.Logger) {
 
 //go:noinline
 func doWithCallDepth(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		l := log.WithCallDepth(1)
 		_ = l
@@ -127,6 +171,7 @@ func (t Tstringer) String() string {
 
 //go:noinline
 func doStringerValue(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		log.Info("this is", "a", Tstringer{"stringer"})
 	}
@@ -140,6 +185,7 @@ func (t Terror) Error() string {
 
 //go:noinline
 func doErrorValue(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		log.Info("this is", "an", Terror{"error"})
 	}
@@ -153,11 +199,35 @@ func (t Tmarshaler) MarshalLog() any {
 
 //go:noinline
 func doMarshalerValue(b *testing.B, log logr.Logger) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		log.Info("this is", "a", Tmarshaler{"marshaler"})
 	}
 }
 
+//go:noinline
+func doFormatInfoTo(b *testing.B, f funcr.Formatter) {
+	b.ReportAllocs()
+	f.AddName("name")
+	f.AddValues([]any{"k1", "str", "k2", 222, "k3", true, "k4", 1.0})
+	kvList := []any{"bool", true, "string", "str", "int", 42, "float", 3.14}
+	buf := make([]byte, 0, 1024)
+	for i := 0; i < b.N; i++ {
+		_, buf = f.FormatInfoTo(buf[:0], 0, "msg", kvList)
+	}
+}
+
+//go:noinline
+func doFormatErrorTo(b *testing.B, f funcr.Formatter) {
+	b.ReportAllocs()
+	err := fmt.Errorf("error message")
+	kvList := []any{"bool", true, "string", "str", "int", 42, "float", 3.14}
+	buf := make([]byte, 0, 1024)
+	for i := 0; i < b.N; i++ {
+		_, buf = f.FormatErrorTo(buf[:0], err, "msg", kvList)
+	}
+}
+
 //
 // discard
 //
@@ -249,6 +319,31 @@ func BenchmarkFuncrJSONLogInfoWithValues(b *testing.B) {
 	doInfoWithValues(b, log)
 }
 
+func BenchmarkFuncrJSONLogInfoWithValuesSortKeys(b *testing.B) {
+	var log logr.Logger = funcr.NewJSON(noopJSON, funcr.Options{SortKeys: true}) //nolint:staticcheck
+	doInfoWithValues(b, log)
+}
+
+func BenchmarkFuncrLogInfoStruct(b *testing.B) {
+	var log logr.Logger = funcr.New(noopKV, funcr.Options{}) //nolint:staticcheck
+	doInfoStruct(b, log)
+}
+
+func BenchmarkFuncrJSONLogInfoStruct(b *testing.B) {
+	var log logr.Logger = funcr.NewJSON(noopJSON, funcr.Options{}) //nolint:staticcheck
+	doInfoStruct(b, log)
+}
+
+func BenchmarkFuncrJSONLogInfoMap(b *testing.B) {
+	var log logr.Logger = funcr.NewJSON(noopJSON, funcr.Options{}) //nolint:staticcheck
+	doInfoMap(b, log)
+}
+
+func BenchmarkFuncrJSONLogInfoMapSortKeys(b *testing.B) {
+	var log logr.Logger = funcr.NewJSON(noopJSON, funcr.Options{SortKeys: true}) //nolint:staticcheck
+	doInfoMap(b, log)
+}
+
 func BenchmarkFuncrLogV0Info(b *testing.B) {
 	var log logr.Logger = funcr.New(noopKV, funcr.Options{}) //nolint:staticcheck
 	doV0Info(b, log)
@@ -294,6 +389,30 @@ func BenchmarkFuncrWithCallDepth(b *testing.B) {
 	doWithCallDepth(b, log)
 }
 
+func BenchmarkFuncrFormatInfoTo(b *testing.B) {
+	doFormatInfoTo(b, funcr.NewFormatter(funcr.Options{}))
+}
+
+func BenchmarkFuncrJSONFormatInfoTo(b *testing.B) {
+	doFormatInfoTo(b, funcr.NewFormatterJSON(funcr.Options{}))
+}
+
+func BenchmarkFuncrCBORFormatInfoTo(b *testing.B) {
+	doFormatInfoTo(b, funcr.NewFormatterCBOR(funcr.Options{}))
+}
+
+func BenchmarkFuncrFormatErrorTo(b *testing.B) {
+	doFormatErrorTo(b, funcr.NewFormatter(funcr.Options{}))
+}
+
+func BenchmarkFuncrJSONFormatErrorTo(b *testing.B) {
+	doFormatErrorTo(b, funcr.NewFormatterJSON(funcr.Options{}))
+}
+
+func BenchmarkFuncrCBORFormatErrorTo(b *testing.B) {
+	doFormatErrorTo(b, funcr.NewFormatterCBOR(funcr.Options{}))
+}
+
 func BenchmarkFuncrJSONLogInfoStringerValue(b *testing.B) {
 	var log logr.Logger = funcr.NewJSON(noopJSON, funcr.Options{}) //nolint:staticcheck
 	doStringerValue(b, log)
@@ -0,0 +1,135 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bulkr implements github.com/go-logr/logr.Logger in terms of
+// documents indexed through the _bulk API of Elasticsearch or OpenSearch.
+//
+// Each log line is one document: the JSON object rendered by funcr, with
+// the time of the log line first, under Options.TimestampKey.  Each
+// document is created in the index named by Options.Index, with an ID chosen
+// by the server, so that data streams work too.
+//
+// A Client batches documents and sends them in the background, as one
+// request with newline-delimited JSON: an action line and the document for
+// each.  Requests which fail with network errors or with 429, 502, 503 or
+// 504 are retried, and so are the documents which the server rejects with
+// one of these statuses in the response.  Documents which it rejects for
+// other reasons, like mapping conflicts, are dropped and counted in Stats.
+//
+// Logging never blocks on the network: documents which do not fit in the
+// queue are dropped and counted in Stats.
+package bulkr
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Options carries parameters which influence the way documents are indexed.
+type Options struct {
+	// Index is the name of the index of each document, in which "{date}"
+	// is replaced by the date of the log line in UTC, formatted with
+	// DateFormat, and "{logger}" by the logger name, lower-cased, with '/'
+	// and characters which index names do not allow replaced by '-'.
+	// Loggers without a name are "root".  If not specified, "logr-{date}"
+	// is used.
+	Index string
+
+	// DateFormat is the layout of "{date}" in Index, as for time.Format.
+	// If not specified, "2006.01.02" is used.
+	DateFormat string
+
+	// TimestampKey is the key of the time of the log line in each
+	// document, which is formatted as RFC 3339 with milliseconds.  If not
+	// specified, "@timestamp" is used.
+	TimestampKey string
+
+	// Headers are added to each request, for example for authentication.
+	Headers map[string]string
+
+	// HTTPClient sends the requests.  If not specified, http.DefaultClient
+	// is used.
+	HTTPClient *http.Client
+
+	// Timeout limits how long each request may take.  If not specified, 30
+	// seconds is used.
+	Timeout time.Duration
+
+	// BatchSize is the most documents sent in one request, and BatchBytes
+	// the most bytes of action lines and documents.  A document which is
+	// larger than BatchBytes is sent on its own.  If not specified, 1000
+	// documents and 5 MiB are used.
+	BatchSize  int
+	BatchBytes int
+
+	// BatchTimeout is how long documents may wait for a batch to fill up.
+	// If not specified, 1 second is used.
+	BatchTimeout time.Duration
+
+	// QueueSize is the number of documents which are queued in memory while
+	// a batch is sent.  Documents which do not fit are dropped.  If not
+	// specified, 4096 is used.
+	QueueSize int
+
+	// MaxRetries is how often a failed request, or a rejected document, is
+	// retried.  If not specified, 5 is used.  Use a negative value to
+	// disable retries.
+	MaxRetries int
+
+	// MinBackoff is how long the client waits before the first retry, and
+	// MaxBackoff the longest it waits, doubling the wait for each retry
+	// unless the server asks for a different one with Retry-After, which
+	// is honoured up to 10 minutes.  If not specified, 500 milliseconds and
+	// 30 seconds are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Clock tells the client how to get the current time, for the
+	// timestamps and index names of documents.  If not specified, time.Now
+	// is used.
+	Clock func() time.Time
+}
+
+// rootLogger is the logger name of loggers without one, in index names.
+const rootLogger = "root"
+
+// timestampFormat is the layout of the timestamp of documents.
+const timestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// indexName returns the name of the index of a document from a logger at a
+// time.
+func (c *Client) indexName(logger string, t time.Time) string {
+	if logger == "" {
+		logger = rootLogger
+	}
+	return strings.NewReplacer(
+		"{date}", t.UTC().Format(c.opts.DateFormat),
+		"{logger}", indexPart(logger),
+	).Replace(c.opts.Index)
+}
+
+// indexPart returns a logger name as part of an index name.
+func indexPart(name string) string {
+	part := []byte(strings.ToLower(name))
+	for i, c := range part {
+		switch c {
+		case '/', '\\', '*', '?', '"', '<', '>', '|', ' ', ',', '#', ':':
+			part[i] = '-'
+		}
+	}
+	return string(part)
+}
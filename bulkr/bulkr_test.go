@@ -0,0 +1,433 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bulkr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+)
+
+// server mimics the _bulk API.  It records the lines of the requests it
+// receives, and answers with the statuses it is given.
+type server struct {
+	*httptest.Server
+
+	// status returns the status of the r-th request.  If nil, or if it
+	// returns 200, each document gets the status from itemStatus.
+	status func(r int) int
+	// itemStatus returns the status of the i-th document of the r-th
+	// request.  If nil, each document is created.
+	itemStatus func(r, i int) int
+
+	mu       sync.Mutex
+	paths    []string
+	requests [][]string
+}
+
+func newServer(t *testing.T, status func(r int) int, itemStatus func(r, i int) int) *server {
+	s := &server{status: status, itemStatus: itemStatus}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/_bulk") || r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("unexpected request %s %s with %q", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var lines []string
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		s.mu.Lock()
+		req := len(s.requests)
+		s.paths = append(s.paths, r.URL.Path)
+		s.requests = append(s.requests, lines)
+		s.mu.Unlock()
+
+		if s.status != nil {
+			if code := s.status(req); code != http.StatusOK {
+				w.WriteHeader(code)
+				return
+			}
+		}
+		type itemRes struct {
+			Index  string            `json:"_index"`
+			Status int               `json:"status"`
+			Error  map[string]string `json:"error,omitempty"`
+		}
+		var resp struct {
+			Took   int                  `json:"took"`
+			Errors bool                 `json:"errors"`
+			Items  []map[string]itemRes `json:"items"`
+		}
+		for i := 0; i+1 < len(lines); i += 2 {
+			var action struct {
+				Create struct {
+					Index string `json:"_index"`
+				} `json:"create"`
+			}
+			if err := json.Unmarshal([]byte(lines[i]), &action); err != nil {
+				t.Errorf("invalid action %q: %v", lines[i], err)
+			}
+			if !json.Valid([]byte(lines[i+1])) {
+				t.Errorf("invalid document %q", lines[i+1])
+			}
+			res := itemRes{Index: action.Create.Index, Status: http.StatusCreated}
+			if s.itemStatus != nil {
+				res.Status = s.itemStatus(req, i/2)
+			}
+			if res.Status > 299 {
+				resp.Errors = true
+				res.Error = map[string]string{"type": "test_exception", "reason": fmt.Sprintf("status %d", res.Status)}
+			}
+			resp.Items = append(resp.Items, map[string]itemRes{"create": res})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *server) get() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+var testTime = time.Date(2006, time.January, 2, 15, 4, 5, 123456789, time.FixedZone("", -7*3600))
+
+func newClient(t *testing.T, url string, opts Options) *Client {
+	t.Helper()
+	if opts.Clock == nil {
+		opts.Clock = func() time.Time { return testTime }
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = time.Millisecond
+	}
+	c, err := New(url, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestLogger(t *testing.T) {
+	s := newServer(t, nil, nil)
+	c := newClient(t, s.URL, Options{Index: "logs-{logger}-{date}"})
+	log := c.Logger(funcr.Options{})
+	log.Info("root", "k", "v")
+	log = log.WithName("Server").WithValues("port", 8080)
+	log.WithName("http handler").Error(os.ErrNotExist, "failed", "html", "<b>")
+	c.Flush()
+
+	expect := [][]string{{
+		`{"create":{"_index":"logs-root-2006.01.02"}}`,
+		`{"@timestamp":"2006-01-02T22:04:05.123Z","logger":"","level":0,"msg":"root","k":"v"}`,
+		`{"create":{"_index":"logs-server-http-handler-2006.01.02"}}`,
+		`{"@timestamp":"2006-01-02T22:04:05.123Z","logger":"Server/http handler","msg":"failed","error":"file does not exist","port":8080,"html":"<b>"}`,
+	}}
+	if got := s.get(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected:\n%s\ngot:\n%v", strings.Join(expect[0], "\n"), got)
+	}
+	if st := c.Stats(); st.Indexed != 2 || st.Requests != 1 || st.Dropped != 0 || st.LastError != nil {
+		t.Errorf("wrong stats: %+v", st)
+	}
+}
+
+func TestLoggerCaller(t *testing.T) {
+	s := newServer(t, nil, nil)
+	c := newClient(t, s.URL, Options{})
+	log := c.Logger(funcr.Options{LogCaller: funcr.All})
+	log.Info("msg")
+	c.Flush()
+	var doc struct {
+		Caller struct {
+			File string `json:"file"`
+		} `json:"caller"`
+	}
+	if got := s.get(); len(got) != 1 || len(got[0]) != 2 || json.Unmarshal([]byte(got[0][1]), &doc) != nil {
+		t.Fatalf("unexpected requests %q", got)
+	}
+	if doc.Caller.File != "bulkr_test.go" {
+		t.Errorf("expected the caller in bulkr_test.go, got %q", doc.Caller.File)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	s := newServer(t, nil, nil)
+	c := newClient(t, s.URL+"/prefix/", Options{
+		Index:        "app-{date}",
+		DateFormat:   "2006-01",
+		TimestampKey: "time",
+	})
+	// An empty object from funcr, without any builtins.
+	c.Logger(funcr.Options{RenderBuiltinsHook: func([]any) []any { return nil }}).Info("ignored")
+	c.Flush()
+	expect := []string{`{"create":{"_index":"app-2006-01"}}`, `{"time":"2006-01-02T22:04:05.123Z"}`}
+	if got := s.get(); len(got) != 1 || !reflect.DeepEqual(got[0], expect) {
+		t.Errorf("expected %v, got %v", expect, got)
+	}
+	if s.paths[0] != "/prefix/_bulk" {
+		t.Errorf("expected the path /prefix/_bulk, got %s", s.paths[0])
+	}
+}
+
+func TestIndexPart(t *testing.T) {
+	for name, expect := range map[string]string{
+		"server":            "server",
+		"Server/HTTP":       "server-http",
+		`a\b*c?d"e<f>g|h i`: "a-b-c-d-e-f-g-h-i",
+		"x,y#z:w":           "x-y-z-w",
+	} {
+		if got := indexPart(name); got != expect {
+			t.Errorf("%q: expected %q, got %q", name, expect, got)
+		}
+	}
+}
+
+func TestBatching(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   Options
+		expect []int // documents per request
+	}{
+		{"size", Options{BatchSize: 2}, []int{2, 2, 1}},
+		{"bytes", Options{BatchBytes: 300}, []int{2, 2, 1}},
+		{"larger than bytes", Options{BatchBytes: 10}, []int{1, 1, 1, 1, 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServer(t, nil, nil)
+			tc.opts.BatchTimeout = time.Hour
+			c := newClient(t, s.URL, tc.opts)
+			log := c.Logger(funcr.Options{})
+			for i := 0; i < 5; i++ {
+				// Each document is about 130 bytes with its action.
+				log.Info("hello", "i", i)
+			}
+			c.Flush()
+			var got []int
+			for _, r := range s.get() {
+				got = append(got, len(r)/2)
+			}
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("expected %v documents per request, got %v", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	testCases := []struct {
+		name       string
+		status     func(r int) int
+		itemStatus func(r, i int) int
+		opts       Options
+		requests   []int // documents per request
+		indexed    uint64
+		dropped    uint64
+		lastError  string
+	}{{
+		name: "retryable",
+		status: func(r int) int {
+			return []int{503, 429, 200}[r]
+		},
+		requests:  []int{3, 3, 3},
+		indexed:   3,
+		lastError: "bulk API returned 429 Too Many Requests",
+	}, {
+		name:      "too many",
+		status:    func(int) int { return 502 },
+		opts:      Options{MaxRetries: 2},
+		requests:  []int{3, 3, 3},
+		dropped:   3,
+		lastError: "bulk API returned 502 Bad Gateway",
+	}, {
+		name:      "disabled",
+		status:    func(int) int { return 503 },
+		opts:      Options{MaxRetries: -1},
+		requests:  []int{3},
+		dropped:   3,
+		lastError: "bulk API returned 503 Service Unavailable",
+	}, {
+		name:      "permanent",
+		status:    func(int) int { return 400 },
+		requests:  []int{3},
+		dropped:   3,
+		lastError: "bulk API returned 400 Bad Request",
+	}, {
+		name: "items",
+		itemStatus: func(r, i int) int {
+			if r == 0 {
+				// The first is created, the second is retried, and
+				// the third is rejected for good.
+				return []int{201, 429, 400}[i]
+			}
+			return 201
+		},
+		requests:  []int{3, 1},
+		indexed:   2,
+		dropped:   1,
+		lastError: `document for index "logr-2006.01.02" rejected with 400: test_exception: status 400`,
+	}, {
+		name: "items too many",
+		itemStatus: func(r, i int) int {
+			if r == 0 && i == 0 {
+				return 201
+			}
+			return 503
+		},
+		opts:      Options{MaxRetries: 1},
+		requests:  []int{3, 2},
+		indexed:   1,
+		dropped:   2,
+		lastError: `document for index "logr-2006.01.02" rejected with 503: test_exception: status 503`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServer(t, tc.status, tc.itemStatus)
+			c := newClient(t, s.URL, tc.opts)
+			log := c.Logger(funcr.Options{})
+			for i := 0; i < 3; i++ {
+				log.Info("hello", "i", i)
+			}
+			c.Flush()
+			st := c.Stats()
+			if st.Indexed != tc.indexed || st.Dropped != tc.dropped || st.Requests != uint64(len(tc.requests)) {
+				t.Errorf("wrong stats: %+v", st)
+			}
+			if got := fmt.Sprint(st.LastError); tc.lastError != "" && got != tc.lastError || tc.lastError == "" && st.LastError != nil {
+				t.Errorf("expected error %q, got %q", tc.lastError, got)
+			}
+			var got []int
+			for _, r := range s.get() {
+				got = append(got, len(r)/2)
+			}
+			if !reflect.DeepEqual(got, tc.requests) {
+				t.Errorf("expected %v documents per request, got %v", tc.requests, got)
+			}
+		})
+	}
+}
+
+func TestRetryItemsResent(t *testing.T) {
+	s := newServer(t, nil, func(r, i int) int {
+		if r == 0 && i == 1 {
+			return 429
+		}
+		return 201
+	})
+	c := newClient(t, s.URL, Options{})
+	log := c.Logger(funcr.Options{})
+	log.Info("one")
+	log.Info("two")
+	c.Flush()
+	requests := s.get()
+	if len(requests) != 2 || !reflect.DeepEqual(requests[1], requests[0][2:]) {
+		t.Errorf("expected the second document to be sent again, got %v", requests)
+	}
+}
+
+func TestNetworkError(t *testing.T) {
+	s := newServer(t, nil, nil)
+	url := s.URL
+	s.Close()
+	c := newClient(t, url, Options{MaxRetries: 1})
+	c.Logger(funcr.Options{}).Info("hello")
+	c.Flush()
+	if st := c.Stats(); st.Requests != 2 || st.Dropped != 1 || st.LastError == nil {
+		t.Errorf("wrong stats: %+v", st)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer ts.Close()
+	c := newClient(t, ts.URL, Options{Headers: map[string]string{"Authorization": "ApiKey secret"}})
+	c.Logger(funcr.Options{}).Info("hello")
+	c.Flush()
+	if auth := got.Get("Authorization"); auth != "ApiKey secret" {
+		t.Errorf("expected the Authorization header, got %q", auth)
+	}
+	// Without a response body, the document is assumed to be created.
+	if st := c.Stats(); st.Indexed != 1 {
+		t.Errorf("wrong stats: %+v", st)
+	}
+}
+
+func TestClose(t *testing.T) {
+	s := newServer(t, func(int) int { return 503 }, nil)
+	c := newClient(t, s.URL, Options{BatchTimeout: time.Hour, MinBackoff: time.Hour})
+	log := c.Logger(funcr.Options{})
+	log.Info("one")
+	log.Info("two")
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close did not return")
+	}
+	log.Info("late")
+	if st := c.Stats(); st.Requests != 1 || st.Dropped != 3 {
+		t.Errorf("wrong stats: %+v", st)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer ts.Close()
+	defer close(block)
+	c := newClient(t, ts.URL, Options{QueueSize: 2, BatchSize: 1})
+	log := c.Logger(funcr.Options{})
+	// The first document is stuck in a request, two are queued, and the
+	// rest are dropped.
+	for i := 0; i < 10; i++ {
+		log.Info("hello")
+		time.Sleep(time.Millisecond)
+	}
+	if dropped := c.Stats().Dropped; dropped < 7 {
+		t.Errorf("expected at least 7 dropped documents, got %d", dropped)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, url := range []string{"localhost:9200", "ftp://localhost", "http://[::1"} {
+		if _, err := New(url, Options{}); err == nil {
+			t.Errorf("%s: expected an error", url)
+		}
+	}
+}
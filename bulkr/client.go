@@ -0,0 +1,288 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bulkr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/internal/batch"
+	"github.com/go-logr/logr/internal/render"
+)
+
+// Stats describes the health of a Client.
+type Stats struct {
+	// Indexed is the number of documents which the server created.
+	Indexed uint64
+	// Dropped is the number of documents which were not indexed: because
+	// the queue was full, the server rejected them, or sending them failed
+	// after all retries.
+	Dropped uint64
+	// Requests is the number of requests sent, including retries.
+	Requests uint64
+	// LastError is the last error from sending a request, or for a
+	// document which the server rejected, or nil.
+	LastError error
+}
+
+// maxResponse limits how much of a response is read.  Bulk responses have an
+// item for each document, so this is generous.
+const maxResponse = 64 << 20
+
+// Client sends documents to the _bulk API.  It is safe for concurrent use.
+type Client struct {
+	opts    Options
+	req     batch.Request
+	batches *batch.Batcher[string] // action and document, each ending with a newline
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New returns a Client which sends documents to the server at baseURL, like
+// "http://localhost:9200", where "/_bulk" is appended.
+func New(baseURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("URL %q is not an HTTP or HTTPS URL", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/_bulk"
+	if opts.Index == "" {
+		opts.Index = "logr-{date}"
+	}
+	if opts.DateFormat == "" {
+		opts.DateFormat = "2006.01.02"
+	}
+	if opts.TimestampKey == "" {
+		opts.TimestampKey = "@timestamp"
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.BatchBytes == 0 {
+		opts.BatchBytes = 5 << 20
+	}
+	if opts.BatchTimeout == 0 {
+		opts.BatchTimeout = time.Second
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = 4096
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 5
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = 500 * time.Millisecond
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	c := &Client{
+		opts: opts,
+		req: batch.Request{
+			Client:      opts.HTTPClient,
+			URL:         u.String(),
+			Server:      "bulk API",
+			ContentType: "application/x-ndjson",
+			Headers:     opts.Headers,
+			Timeout:     opts.Timeout,
+			MaxResponse: maxResponse,
+		},
+	}
+	c.batches = batch.New(batch.Options{
+		Size:      opts.BatchSize,
+		Bytes:     opts.BatchBytes,
+		Timeout:   opts.BatchTimeout,
+		QueueSize: opts.QueueSize,
+	}, func(d string) int { return len(d) }, c.send)
+	return c, nil
+}
+
+// Logger returns a logr.Logger which sends documents through c, rendered by
+// funcr with opts.  StrictJSON is always set, so that each document is
+// valid JSON.
+func (c *Client) Logger(opts funcr.Options) logr.Logger {
+	return logr.New(render.NewSink(opts, c.enqueue))
+}
+
+func (c *Client) enqueue(logger, obj string) {
+	now := c.opts.Clock()
+	buf := make([]byte, 0, len(obj)+128)
+	buf = append(buf, `{"create":{"_index":`...)
+	buf = appendString(buf, c.indexName(logger, now))
+	buf = append(buf, "}}\n{"...)
+	buf = appendString(buf, c.opts.TimestampKey)
+	buf = append(buf, ':')
+	buf = appendString(buf, now.UTC().Format(timestampFormat))
+	if obj != "{}" {
+		buf = append(buf, ',')
+	}
+	buf = append(buf, obj[1:]...)
+	buf = append(buf, '\n')
+	if !c.batches.Add(string(buf)) {
+		c.updateStats(func(s *Stats) { s.Dropped++ })
+	}
+}
+
+// appendString appends s as a JSON string.
+func appendString(buf []byte, s string) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s) // strings always encode
+	return append(buf, bytes.TrimSuffix(b.Bytes(), []byte{'\n'})...)
+}
+
+// Stats returns the current health of c.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *Client) updateStats(fn func(*Stats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.stats)
+}
+
+// Flush sends the queued documents, and waits until they were indexed or
+// dropped.
+func (c *Client) Flush() {
+	c.batches.Flush()
+}
+
+// Close sends the queued documents, and stops the client.  Requests which
+// fail are not retried anymore.  Documents logged after Close are dropped.
+func (c *Client) Close() error {
+	c.batches.Close()
+	return nil
+}
+
+// send indexes a batch, retrying as needed.
+func (c *Client) send(items []string) {
+	backoff := batch.Backoff{Min: c.opts.MinBackoff, Max: c.opts.MaxBackoff}
+	dropped := c.batches.Retry(items, c.opts.MaxRetries, backoff, func(items []string) ([]string, error) {
+		retry, err := c.post(items)
+		c.updateStats(func(s *Stats) {
+			s.Requests++
+			if err != nil {
+				s.LastError = err
+			}
+		})
+		return retry, err
+	})
+	c.updateStats(func(s *Stats) { s.Dropped += uint64(len(dropped)) })
+}
+
+// itemError is a document which the server rejected.
+type itemError struct {
+	index  string
+	status int
+	typ    string
+	reason string
+}
+
+func (err *itemError) Error() string {
+	return fmt.Sprintf("document for index %q rejected with %d: %s: %s", err.index, err.status, err.typ, err.reason)
+}
+
+// bulkResponse is the part of a bulk response which is needed here.
+type bulkResponse struct {
+	Errors bool                     `json:"errors"`
+	Items  []map[string]bulkItemRes `json:"items"`
+}
+
+type bulkItemRes struct {
+	Index  string `json:"_index"`
+	Status int    `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// post sends one request, counts the documents which the server created or
+// rejected for good, and returns the ones to retry.
+func (c *Client) post(items []string) ([]string, error) {
+	var body bytes.Buffer
+	for _, d := range items {
+		body.WriteString(d)
+	}
+	data, err := c.req.Post(body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	var result bulkResponse
+	if json.Unmarshal(data, &result) != nil || !result.Errors || len(result.Items) != len(items) {
+		// Without details, the documents can only be assumed to be
+		// created.
+		c.updateStats(func(s *Stats) { s.Indexed += uint64(len(items)) })
+		return nil, nil
+	}
+	var retry []string
+	var indexed, dropped uint64
+	var lastErr error
+	for i, item := range result.Items {
+		// Each item has a single key, the action.
+		for _, res := range item {
+			switch {
+			case res.Status >= 200 && res.Status <= 299:
+				indexed++
+			default:
+				ierr := &itemError{index: res.Index, status: res.Status}
+				if res.Error != nil {
+					ierr.typ, ierr.reason = res.Error.Type, res.Error.Reason
+				}
+				lastErr = ierr
+				if batch.RetryableStatus(res.Status) {
+					retry = append(retry, items[i])
+				} else {
+					dropped++
+				}
+			}
+		}
+	}
+	c.updateStats(func(s *Stats) {
+		s.Indexed += indexed
+		s.Dropped += dropped
+		if lastErr != nil {
+			s.LastError = lastErr
+		}
+	})
+	return retry, nil
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bulkr_test
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr/bulkr"
+	"github.com/go-logr/logr/funcr"
+)
+
+func ExampleNew() {
+	client, err := bulkr.New("http://localhost:9200", bulkr.Options{
+		// One index per logger and day, like "app-server-2006.01.02".
+		Index:   "app-{logger}-{date}",
+		Headers: map[string]string{"Authorization": "ApiKey " + os.Getenv("ES_API_KEY")},
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer client.Close()
+
+	log := client.Logger(funcr.Options{})
+	log.WithName("server").Info("started", "port", 8080)
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command funcrview pretty-prints logs which were written by funcr, as JSON
+// or key-value lines, and can filter them and convert them to logfmt or
+// funcr's key-value text.
+//
+// Usage:
+//
+//	funcrview [flags] [file...]
+//
+// It reads standard input if no files are given.  Lines which can not be
+// parsed, like panics, are printed unchanged unless a filter is set.  The
+// logs must use funcr's default builtin keys.
+//
+// For example, to show errors and V(0) logs of the "server" logger and its
+// children from the last hour, which have the key "user" with the value
+// "alice":
+//
+//	funcrview -logger server -v 0 -since 1h -match user=alice app.log
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1 // some input could not be read
+	exitUsage = 2 // invalid flags
+)
+
+// run implements the command, and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("funcrview", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: funcrview [flags] [file...]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	var (
+		v          = newViewer(time.Now)
+		since      string
+		until      string
+		colorMode  string
+		styleName  string
+		formatName string
+		utc        bool
+	)
+	fs.StringVar(&v.logger, "logger", "", "show only logs from loggers whose names start with `prefix`")
+	fs.IntVar(&v.verbosity, "v", -1, "show only Info logs up to this V-`level`, or all if negative")
+	fs.BoolVar(&v.errorsOnly, "errors", false, "show only Error logs")
+	fs.StringVar(&since, "since", "", "show only logs at or after this `time`, or this long ago, e.g. 1h")
+	fs.StringVar(&until, "until", "", "show only logs before this `time`, or this long ago, e.g. 10m")
+	fs.Var(&v.matches, "match", "show only logs with `key=value`; may be repeated")
+	fs.StringVar(&formatName, "format", "pretty", "output `format`: pretty, logfmt, kv, or json")
+	fs.StringVar(&colorMode, "color", "auto", "colorize pretty output: auto, always, or never")
+	fs.StringVar(&v.layout, "timestamp-format", defaultTimestampFormat, "the time.Time.Format `layout` of timestamps")
+	fs.StringVar(&styleName, "timestamp-style", "layout", "how timestamps were logged: layout, unix, unixmilli, unixnano, or elapsed")
+	fs.BoolVar(&utc, "utc", false, "timestamps without a time zone are in UTC rather than local time")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	usage := func(format string, args ...any) int {
+		fmt.Fprintf(stderr, "funcrview: "+format+"\n", args...)
+		fs.Usage()
+		return exitUsage
+	}
+	if utc {
+		v.loc = time.UTC
+	}
+	style, found := timestampStyles[styleName]
+	if !found {
+		return usage("invalid -timestamp-style %q", styleName)
+	}
+	v.opts.TimestampStyle = style
+	if v.format, found = formats[formatName]; !found {
+		return usage("invalid -format %q", formatName)
+	}
+	var err error
+	if v.since, err = v.parseTime(since); err != nil {
+		return usage("invalid -since: %v", err)
+	}
+	if v.until, err = v.parseTime(until); err != nil {
+		return usage("invalid -until: %v", err)
+	}
+	switch colorMode {
+	case "always":
+		v.color = true
+	case "never":
+	case "auto":
+		v.color = isTerminal(stdout) && os.Getenv("NO_COLOR") == ""
+	default:
+		return usage("invalid -color %q", colorMode)
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	code := exitOK
+	view := func(name string, r io.Reader) {
+		if err := v.view(out, r); err != nil {
+			fmt.Fprintf(stderr, "funcrview: %s: %v\n", name, err)
+			code = exitError
+		}
+	}
+	if fs.NArg() == 0 {
+		view("stdin", stdin)
+	}
+	for _, name := range fs.Args() {
+		if name == "-" {
+			view("stdin", stdin)
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "funcrview: %v\n", err)
+			code = exitError
+			continue
+		}
+		view(name, f)
+		f.Close()
+	}
+	return code
+}
+
+// defaultTimestampFormat is funcr's default Options.TimestampFormat.
+const defaultTimestampFormat = "2006-01-02 15:04:05.000000"
+
+var timestampStyles = map[string]funcr.TimestampStyle{
+	"layout":    funcr.TimestampLayout,
+	"unix":      funcr.TimestampUnix,
+	"unixmilli": funcr.TimestampUnixMilli,
+	"unixnano":  funcr.TimestampUnixNano,
+	"elapsed":   funcr.TimestampElapsed,
+}
+
+// isTerminal tells whether w is a terminal, as far as can be told without
+// system calls.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// match is a key=value predicate.
+type match struct {
+	key, value string
+}
+
+// matchList implements flag.Value for repeated -match flags.
+type matchList []match
+
+func (m *matchList) String() string {
+	if m == nil {
+		return ""
+	}
+	var parts []string
+	for _, mt := range *m {
+		parts = append(parts, mt.key+"="+mt.value)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (m *matchList) Set(s string) error {
+	key, value, found := strings.Cut(s, "=")
+	if !found || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	*m = append(*m, match{key, value})
+	return nil
+}
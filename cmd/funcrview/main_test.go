@@ -0,0 +1,242 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testInput = `{"logger":"srv/http","ts":"2026-10-18 10:00:00.000000","caller":{"file":"main.go","line":12},"level":0,"msg":"hello world","user":"alice","n":[1,2]}
+{"logger":"srv","ts":"2026-10-18 10:05:00.000000","caller":{"file":"main.go","line":14},"msg":"failed","error":"boom","user":"bob"}
+panic: oh no
+
+db "level"=2 "msg"="verbose" "x"={"a"=1} "s"="two words"
+`
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name   string
+		args   []string
+		expect string
+	}{{
+		name: "pretty",
+		expect: `2026-10-18 10:00:00.000000 INFO  srv/http: hello world user=alice n=[1,2] (main.go:12)
+2026-10-18 10:05:00.000000 ERROR srv: failed error=boom user=bob (main.go:14)
+panic: oh no
+V2    db: verbose x={"a":1} s="two words"
+`,
+	}, {
+		name: "color",
+		args: []string{"-color", "always", "-errors"},
+		expect: "\x1b[2m2026-10-18 10:05:00.000000\x1b[0m \x1b[31mERROR\x1b[0m \x1b[36msrv:\x1b[0m \x1b[1mfailed\x1b[0m " +
+			"\x1b[31merror=boom\x1b[0m \x1b[2muser=\x1b[0mbob \x1b[2m(main.go:14)\x1b[0m\n",
+	}, {
+		name: "logfmt",
+		args: []string{"-format", "logfmt"},
+		expect: `logger=srv/http ts="2026-10-18 10:00:00.000000" caller=main.go:12 level=0 msg="hello world" user=alice n=[1,2]
+logger=srv ts="2026-10-18 10:05:00.000000" caller=main.go:14 msg=failed error=boom user=bob
+panic: oh no
+logger=db level=2 msg=verbose x="{\"a\":1}" s="two words"
+`,
+	}, {
+		name: "kv",
+		args: []string{"-format", "kv"},
+		expect: `srv/http "ts"="2026-10-18 10:00:00.000000" "caller"={"file"="main.go" "line"=12} "level"=0 "msg"="hello world" "user"="alice" "n"=[1 2]
+srv "ts"="2026-10-18 10:05:00.000000" "caller"={"file"="main.go" "line"=14} "msg"="failed" "error"="boom" "user"="bob"
+panic: oh no
+db "level"=2 "msg"="verbose" "x"={"a"=1} "s"="two words"
+`,
+	}, {
+		name: "json",
+		args: []string{"-format", "json", "-logger", "db"},
+		expect: `{"logger":"db","level":2,"msg":"verbose","x":{"a":1},"s":"two words"}
+`,
+	}, {
+		name: "logger prefix",
+		args: []string{"-logger", "srv"},
+		expect: `2026-10-18 10:00:00.000000 INFO  srv/http: hello world user=alice n=[1,2] (main.go:12)
+2026-10-18 10:05:00.000000 ERROR srv: failed error=boom user=bob (main.go:14)
+`,
+	}, {
+		name: "verbosity",
+		args: []string{"-v", "1", "-format", "logfmt"},
+		expect: `logger=srv/http ts="2026-10-18 10:00:00.000000" caller=main.go:12 level=0 msg="hello world" user=alice n=[1,2]
+logger=srv ts="2026-10-18 10:05:00.000000" caller=main.go:14 msg=failed error=boom user=bob
+`,
+	}, {
+		name: "time range",
+		args: []string{"-utc", "-since", "2026-10-18 10:00:00.000001", "-until", "2026-10-18T10:06:00Z", "-format", "logfmt"},
+		expect: `logger=srv ts="2026-10-18 10:05:00.000000" caller=main.go:14 msg=failed error=boom user=bob
+`,
+	}, {
+		name: "match",
+		args: []string{"-match", "x={\"a\":1}", "-match", "s=two words", "-match", "msg=verbose", "-format", "logfmt"},
+		expect: `logger=db level=2 msg=verbose x="{\"a\":1}" s="two words"
+`,
+	}, {
+		name: "match builtin",
+		args: []string{"-match", "caller=main.go:14", "-format", "logfmt"},
+		expect: `logger=srv ts="2026-10-18 10:05:00.000000" caller=main.go:14 msg=failed error=boom user=bob
+`,
+	}, {
+		name:   "no match",
+		args:   []string{"-match", "user=carol"},
+		expect: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tc.args, strings.NewReader(testInput), &stdout, &stderr); code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr.String())
+			}
+			if got := stdout.String(); got != tc.expect {
+				t.Errorf("\nexpected:\n%s\ngot:\n%s", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestRunTimestampStyles(t *testing.T) {
+	testCases := []struct {
+		style  string
+		input  string
+		expect string
+	}{{
+		style:  "unix",
+		input:  `{"logger":"","ts":1136214245.5,"level":0,"msg":"a"}`,
+		expect: "2006-01-02T15:04:05.5Z INFO  a\n",
+	}, {
+		style:  "unixmilli",
+		input:  `{"logger":"","ts":1136214245500,"level":0,"msg":"a"}`,
+		expect: "2006-01-02T15:04:05.5Z INFO  a\n",
+	}, {
+		style:  "unixnano",
+		input:  `{"logger":"","ts":1136214245500000000,"level":0,"msg":"a"}`,
+		expect: "2006-01-02T15:04:05.5Z INFO  a\n",
+	}, {
+		style:  "elapsed",
+		input:  `{"logger":"","ts":1.25,"level":0,"msg":"a"}`,
+		expect: "1.25 INFO  a\n",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.style, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := []string{"-utc", "-timestamp-style", tc.style, "-timestamp-format", time.RFC3339Nano}
+			if code := run(args, strings.NewReader(tc.input), &stdout, &stderr); code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr.String())
+			}
+			if got := stdout.String(); got != tc.expect {
+				t.Errorf("expected %q, got %q", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestRunEscapes(t *testing.T) {
+	// Logged text must not be able to change the terminal or forge lines.
+	input := `{"logger":"a\rb","ts":"\u001b[2J","caller":{"file":"x\ny.go","line":1},"level":0,` +
+		`"msg":"clear\u001b[2J\nINFO  forged","k\u001b":"\u009b31m","bad":"` + "\x9b" + `","v":["\u001b"]}` + "\n"
+	testCases := []struct {
+		format string
+		expect string
+	}{{
+		format: "pretty",
+		expect: `"\x1b[2J" INFO  "a\rb": "clear\x1b[2J\nINFO  forged" k_="\u009b31m" bad="\x9b" v=["\u001b"] ("x\ny.go:1")` + "\n",
+	}, {
+		format: "logfmt",
+		expect: `logger="a\rb" ts="\x1b[2J" caller="x\ny.go:1" level=0 msg="clear\x1b[2J\nINFO  forged" k_="\u009b31m" bad="\x9b" v="[\"\\u001b\"]"` + "\n",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run([]string{"-format", tc.format}, strings.NewReader(input), &stdout, &stderr); code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr.String())
+			}
+			if got := stdout.String(); got != tc.expect {
+				t.Errorf("\nexpected %s\n     got %s", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(file, []byte(`"level"=0 "msg"="from file"`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := run([]string{file, "-", filepath.Join(dir, "missing.log")},
+		strings.NewReader(`"level"=0 "msg"="from stdin"`), &stdout, &stderr)
+	if code != exitError {
+		t.Errorf("expected exit code %d, got %d", exitError, code)
+	}
+	if expect := "INFO  from file\nINFO  from stdin\n"; stdout.String() != expect {
+		t.Errorf("expected %q, got %q", expect, stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "missing.log") {
+		t.Errorf("expected an error about missing.log, got %q", stderr.String())
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"-format", "xml"},
+		{"-color", "sometimes"},
+		{"-timestamp-style", "iso"},
+		{"-since", "yesterday"},
+		{"-match", "nokey"},
+		{"-no-such-flag"},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, strings.NewReader(""), &stdout, &stderr); code != exitUsage {
+			t.Errorf("%q: expected exit code %d, got %d", args, exitUsage, code)
+		}
+		if !strings.Contains(stderr.String(), "Usage:") {
+			t.Errorf("%q: expected usage, got %q", args, stderr.String())
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+	v := newViewer(func() time.Time { return now })
+	v.layout = defaultTimestampFormat
+	v.loc = time.UTC
+	for arg, expect := range map[string]time.Time{
+		"":                           {},
+		"90m":                        now.Add(-90 * time.Minute),
+		"2026-10-18T11:00:00+01:00":  time.Date(2026, time.October, 18, 10, 0, 0, 0, time.UTC),
+		"2026-10-18 11:00:00.250000": time.Date(2026, time.October, 18, 11, 0, 0, 250000000, time.UTC),
+		"2026-10-18 11:00:00":        time.Date(2026, time.October, 18, 11, 0, 0, 0, time.UTC),
+		"2026-10-17":                 time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC),
+	} {
+		got, err := v.parseTime(arg)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", arg, err)
+		} else if !got.Equal(expect) {
+			t.Errorf("%q: expected %v, got %v", arg, expect, got)
+		}
+	}
+}
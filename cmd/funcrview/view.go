@@ -0,0 +1,424 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/internal/render"
+)
+
+// viewer filters and prints log records.
+type viewer struct {
+	// Filters
+	logger     string
+	verbosity  int // negative for all
+	errorsOnly bool
+	since      time.Time
+	until      time.Time
+	matches    matchList
+
+	// Output
+	format format
+	color  bool
+
+	// Input
+	opts   funcr.Options // for the Parser
+	layout string
+	loc    *time.Location
+	now    func() time.Time
+
+	values funcr.Formatter // renders values as JSON
+}
+
+func newViewer(now func() time.Time) *viewer {
+	return &viewer{
+		loc:    time.Local,
+		now:    now,
+		values: funcr.NewFormatterJSON(render.WithoutBuiltins(funcr.Options{StrictJSON: true})),
+	}
+}
+
+// parseTime parses the argument of -since or -until, which is a time, or a
+// duration before now.  An empty argument is the zero time.
+func (v *viewer) parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return v.now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, v.layout, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, v.loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, strconv.ErrSyntax
+}
+
+// filtered tells whether any filter is set.
+func (v *viewer) filtered() bool {
+	return v.logger != "" || v.verbosity >= 0 || v.errorsOnly ||
+		!v.since.IsZero() || !v.until.IsZero() || len(v.matches) > 0
+}
+
+// view prints the logs read from r.
+func (v *viewer) view(w *bufio.Writer, r io.Reader) error {
+	br := bufio.NewReader(r)
+	p := funcr.NewParser(strings.NewReader(""), v.opts)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			line = strings.TrimRight(line, "\r\n")
+			if rec, ok := parse(p, line); ok {
+				if v.keep(rec) {
+					v.format(v, w, rec)
+				}
+			} else if line != "" && !v.filtered() {
+				w.WriteString(line)
+				w.WriteByte('\n')
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parse parses one line with p.  Lines are parsed one at a time, rather than
+// as the whole input of the Parser, so that lines which are not logs can be
+// skipped.  Lines like "panic: oops" parse as key-value lines with only a
+// prefix, so those are not considered to be logs either.
+func parse(p *funcr.Parser, line string) (funcr.Record, bool) {
+	p.Reset(strings.NewReader(line))
+	if !p.Next() {
+		return funcr.Record{}, false
+	}
+	rec := p.Record()
+	return rec, len(rec.Builtins) > 0 || len(rec.Values) > 0
+}
+
+// keep tells whether a record passes the filters.
+func (v *viewer) keep(rec funcr.Record) bool {
+	if !strings.HasPrefix(rec.Logger, v.logger) {
+		return false
+	}
+	if v.errorsOnly && !rec.IsError() {
+		return false
+	}
+	if level, ok := rec.Builtins[funcr.BuiltinLevel].(int); ok && v.verbosity >= 0 && level > v.verbosity {
+		return false
+	}
+	if !v.since.IsZero() || !v.until.IsZero() {
+		t, ok := v.timestamp(rec)
+		if !ok || (!v.since.IsZero() && t.Before(v.since)) || (!v.until.IsZero() && !t.Before(v.until)) {
+			return false
+		}
+	}
+	for _, m := range v.matches {
+		val, found := lookup(rec, m.key)
+		if !found || v.text(val) != m.value {
+			return false
+		}
+	}
+	return true
+}
+
+// timestamp returns the time of a record.
+func (v *viewer) timestamp(rec funcr.Record) (time.Time, bool) {
+	switch ts := rec.Builtins[funcr.BuiltinTimestamp].(type) {
+	case string:
+		t, err := time.ParseInLocation(v.layout, ts, v.loc)
+		return t, err == nil
+	case float64:
+		if v.opts.TimestampStyle == funcr.TimestampElapsed {
+			return time.Time{}, false // no reference time
+		}
+		sec, frac := math.Modf(ts)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	case int64:
+		if v.opts.TimestampStyle == funcr.TimestampUnixMilli {
+			return time.UnixMilli(ts), true
+		}
+		return time.Unix(0, ts), true
+	}
+	return time.Time{}, false
+}
+
+// builtinKeys are funcr's default keys for builtins, in its default order.
+var builtinKeys = []struct {
+	builtin funcr.Builtin
+	key     string
+	process bool // about the process rather than the log line
+}{
+	{funcr.BuiltinLogger, "logger", false},
+	{funcr.BuiltinTimestamp, "ts", false},
+	{funcr.BuiltinCaller, "caller", false},
+	{funcr.BuiltinLevel, "level", false},
+	{funcr.BuiltinMessage, "msg", false},
+	{funcr.BuiltinError, "error", false},
+	{funcr.BuiltinHostname, "host", true},
+	{funcr.BuiltinPID, "pid", true},
+	{funcr.BuiltinGoroutineID, "goroutine", true},
+	{funcr.BuiltinSequence, "seq", true},
+}
+
+// lookup finds the value of a key in a record, or of a builtin if no value
+// has that key.
+func lookup(rec funcr.Record, key string) (any, bool) {
+	for i := 0; i < len(rec.Values); i += 2 {
+		if rec.Values[i] == key {
+			return rec.Values[i+1], true
+		}
+	}
+	for _, bk := range builtinKeys {
+		if bk.key == key {
+			if bk.builtin == funcr.BuiltinLogger {
+				return rec.Logger, true
+			}
+			val, found := rec.Builtins[bk.builtin]
+			return val, found
+		}
+	}
+	return nil, false
+}
+
+// text renders a value as text: strings as-is, callers as file:line, and
+// anything else as JSON.
+func (v *viewer) text(val any) string {
+	switch val := val.(type) {
+	case string:
+		return val
+	case funcr.Caller:
+		return val.File + ":" + strconv.Itoa(val.Line)
+	}
+	return render.JSON(v.values, val)
+}
+
+// format prints one record.
+type format func(v *viewer, w *bufio.Writer, rec funcr.Record)
+
+var formats = map[string]format{
+	"pretty": formatPretty,
+	"logfmt": formatLogfmt,
+	"kv":     formatFuncr(funcr.NewFormatter),
+	"json":   formatFuncr(funcr.NewFormatterJSON),
+}
+
+// ANSI escape sequences for the pretty format.
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorFaint  = "\x1b[2m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorBlue   = "\x1b[34m"
+	colorCyan   = "\x1b[36m"
+)
+
+// paint writes s in a color, if enabled.
+func (v *viewer) paint(w *bufio.Writer, color, s string) {
+	if v.color {
+		w.WriteString(color)
+	}
+	w.WriteString(s)
+	if v.color {
+		w.WriteString(colorReset)
+	}
+}
+
+// formatPretty prints a record for people to read, like:
+//
+//	2006-01-02 15:04:05.000000 INFO  name: message key=value (file.go:12)
+func formatPretty(v *viewer, w *bufio.Writer, rec funcr.Record) {
+	if ts, found := rec.Builtins[funcr.BuiltinTimestamp]; found {
+		text := v.text(ts)
+		if t, ok := v.timestamp(rec); ok && v.opts.TimestampStyle != funcr.TimestampLayout {
+			text = t.In(v.loc).Format(v.layout)
+		}
+		v.paint(w, colorFaint, printable(text))
+		w.WriteByte(' ')
+	}
+	switch level, isInfo := rec.Builtins[funcr.BuiltinLevel].(int); {
+	case rec.IsError():
+		v.paint(w, colorRed, "ERROR")
+	case isInfo && level == 0:
+		v.paint(w, colorGreen, "INFO ")
+	case isInfo:
+		v.paint(w, colorBlue, padRight("V"+strconv.Itoa(level), 5))
+	default:
+		v.paint(w, colorYellow, "?    ")
+	}
+	w.WriteByte(' ')
+	if rec.Logger != "" {
+		v.paint(w, colorCyan, printable(rec.Logger)+":")
+		w.WriteByte(' ')
+	}
+	if msg, found := rec.Builtins[funcr.BuiltinMessage]; found {
+		v.paint(w, colorBold, printable(v.text(msg)))
+	}
+	if err, found := rec.Builtins[funcr.BuiltinError]; found {
+		w.WriteByte(' ')
+		v.paint(w, colorRed, "error="+logfmtValue(v.text(err)))
+	}
+	for _, bk := range builtinKeys {
+		if !bk.process {
+			continue
+		}
+		if val, found := rec.Builtins[bk.builtin]; found {
+			w.WriteByte(' ')
+			v.paint(w, colorFaint, bk.key+"=")
+			w.WriteString(v.prettyValue(val))
+		}
+	}
+	for i := 0; i < len(rec.Values); i += 2 {
+		w.WriteByte(' ')
+		v.paint(w, colorFaint, logfmtKey(v.text(rec.Values[i]))+"=")
+		w.WriteString(v.prettyValue(rec.Values[i+1]))
+	}
+	if caller, found := rec.Builtins[funcr.BuiltinCaller]; found {
+		w.WriteByte(' ')
+		v.paint(w, colorFaint, "("+printable(v.text(caller))+")")
+	}
+	w.WriteByte('\n')
+}
+
+// prettyValue renders a value for the pretty format: strings as for logfmt,
+// and anything else as JSON.
+func (v *viewer) prettyValue(val any) string {
+	if s, ok := val.(string); ok {
+		return logfmtValue(s)
+	}
+	return printable(v.text(val))
+}
+
+// printable returns s, or s quoted if it has characters which are not
+// printable, so that logged text cannot move the cursor, change colors, or
+// start what looks like another log line.
+func printable(s string) string {
+	for _, r := range s {
+		if !strconv.IsPrint(r) || r == utf8.RuneError {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+func padRight(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+	return s + strings.Repeat(" ", n-len(s))
+}
+
+// formatLogfmt prints a record as logfmt, with funcr's builtin keys.
+func formatLogfmt(v *viewer, w *bufio.Writer, rec funcr.Record) {
+	sep := ""
+	pair := func(key string, val any) {
+		w.WriteString(sep)
+		w.WriteString(logfmtKey(key))
+		w.WriteByte('=')
+		w.WriteString(logfmtValue(v.text(val)))
+		sep = " "
+	}
+	for _, bk := range builtinKeys {
+		if bk.builtin == funcr.BuiltinLogger {
+			if rec.Logger != "" {
+				pair(bk.key, rec.Logger)
+			}
+		} else if val, found := rec.Builtins[bk.builtin]; found {
+			pair(bk.key, val)
+		}
+	}
+	for i := 0; i < len(rec.Values); i += 2 {
+		pair(v.text(rec.Values[i]), rec.Values[i+1])
+	}
+	w.WriteByte('\n')
+}
+
+// logfmtKey replaces the characters which logfmt does not allow in keys.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || !strconv.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue quotes a value if logfmt needs it to be.
+func logfmtValue(val string) string {
+	if val == "" {
+		return `""`
+	}
+	for _, r := range val {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || !strconv.IsPrint(r) || r == utf8.RuneError {
+			return strconv.Quote(val)
+		}
+	}
+	return val
+}
+
+// formatFuncr returns a format which prints records as funcr would, with a
+// Formatter returned by newFormatter.
+func formatFuncr(newFormatter func(funcr.Options) funcr.Formatter) format {
+	// The builtins are rendered as key-value pairs, so that they are logged
+	// as they were parsed.
+	f := newFormatter(render.WithoutBuiltins(funcr.Options{StrictJSON: true}))
+	isJSON := false
+	if _, args := f.FormatInfo(0, "", nil); strings.HasPrefix(args, "{") {
+		isJSON = true
+	}
+	return func(_ *viewer, w *bufio.Writer, rec funcr.Record) {
+		kvList := make([]any, 0, 2*len(builtinKeys)+len(rec.Values))
+		for _, bk := range builtinKeys {
+			if bk.builtin == funcr.BuiltinLogger {
+				if isJSON {
+					kvList = append(kvList, bk.key, rec.Logger)
+				}
+			} else if val, found := rec.Builtins[bk.builtin]; found {
+				kvList = append(kvList, bk.key, val)
+			}
+		}
+		kvList = append(kvList, rec.Values...)
+		g := f
+		if !isJSON && rec.Logger != "" {
+			g.AddName(rec.Logger)
+		}
+		prefix, args := g.FormatInfo(0, "", kvList)
+		if prefix != "" {
+			w.WriteString(prefix)
+			w.WriteByte(' ')
+		}
+		w.WriteString(args)
+		w.WriteByte('\n')
+	}
+}
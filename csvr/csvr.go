@@ -0,0 +1,212 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package csvr implements github.com/go-logr/logr.Logger in terms of CSV (RFC
+// 4180) or TSV output, for loading logs into spreadsheets and similar tools.
+//
+// Each log line is one record, with the columns:
+//
+//	time, level, name, msg, error, <Options.Columns...>, extra
+//
+// The level column holds the V-level of Info logs, or "error" for Error logs.
+// The columns named by Options.Columns hold the values logged with those
+// keys, and the extra column holds all other key-value pairs as a JSON
+// object.  Strings are written as-is, and other values as JSON, as rendered
+// by funcr.
+package csvr
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/internal/render"
+)
+
+// Options carries parameters which influence the way logs are generated.
+type Options struct {
+	// Columns lists the keys whose values are written in their own columns,
+	// in this order, after the fixed columns.  Values with other keys are
+	// written to the extra column.
+	Columns []string
+
+	// TSV tells the logger to separate columns with tabs rather than commas.
+	// Fields are quoted as for CSV when needed, which spreadsheets accept.
+	TSV bool
+
+	// Header tells the logger to write a record with the names of the
+	// columns before the first log line.
+	Header bool
+
+	// TimestampFormat tells the logger how to render the time column.  If not
+	// specified, time.RFC3339Nano is used.
+	TimestampFormat string
+
+	// Clock tells the logger how to get the current time, for the time
+	// column.  If not specified, time.Now is used.
+	Clock func() time.Time
+
+	// Verbosity tells the logger which V logs to write.  Higher values
+	// enable more logs.
+	Verbosity int
+}
+
+// The fixed columns, before Options.Columns.
+const (
+	colTime = iota
+	colLevel
+	colName
+	colMsg
+	colError
+	numFixed
+)
+
+// Names of the columns which are always present.
+var fixedColumns = [numFixed]string{"time", "level", "name", "msg", "error"}
+
+// extraColumn is the name of the last column.
+const extraColumn = "extra"
+
+// New returns a logr.Logger which writes CSV or TSV records to w.  Writes are
+// serialized, so w need not be safe for concurrent use, but it must not be
+// written to by anything else.  Errors from w are ignored.
+func New(w io.Writer, opts Options) logr.Logger {
+	if opts.TimestampFormat == "" {
+		opts.TimestampFormat = time.RFC3339Nano
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	out := &output{w: csv.NewWriter(w)}
+	if opts.Header {
+		out.header = append(fixedColumns[:], opts.Columns...)
+		out.header = append(out.header, extraColumn)
+	}
+	if opts.TSV {
+		out.w.Comma = '\t'
+	}
+	columns := make(map[string]int, len(opts.Columns))
+	for i, key := range opts.Columns {
+		if _, found := columns[key]; !found {
+			columns[key] = numFixed + i
+		}
+	}
+	return logr.New(&sink{
+		opts:    &opts,
+		out:     out,
+		columns: columns,
+		fmtr: funcr.NewFormatterJSON(render.WithoutBuiltins(funcr.Options{
+			DuplicateKeys: funcr.LastKeyWins,
+			StrictJSON:    true,
+		})),
+	})
+}
+
+// output serializes the records of a logger and those derived from it, and
+// writes the header before the first one.
+type output struct {
+	mu     sync.Mutex
+	w      *csv.Writer
+	header []string // nil once written, or if not wanted
+}
+
+func (o *output) write(record []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.header != nil {
+		_ = o.w.Write(o.header)
+		o.header = nil
+	}
+	_ = o.w.Write(record)
+	o.w.Flush()
+}
+
+// sink implements logr.LogSink.
+type sink struct {
+	opts    *Options
+	out     *output
+	columns map[string]int // key -> index in a record
+	name    string
+	values  []any
+	fmtr    funcr.Formatter // renders values, and the extra column
+}
+
+var _ logr.LogSink = &sink{}
+
+func (*sink) Init(logr.RuntimeInfo) {}
+
+func (l *sink) Enabled(level int) bool {
+	return level <= l.opts.Verbosity
+}
+
+func (l *sink) Info(level int, msg string, kvList ...any) {
+	l.log(strconv.Itoa(level), msg, nil, kvList)
+}
+
+func (l *sink) Error(err error, msg string, kvList ...any) {
+	l.log("error", msg, err, kvList)
+}
+
+func (l sink) WithName(name string) logr.LogSink {
+	if l.name != "" {
+		l.name += "/"
+	}
+	l.name += name
+	return &l
+}
+
+func (l sink) WithValues(kvList ...any) logr.LogSink {
+	l.values = render.AppendValues(l.values, kvList)
+	return &l
+}
+
+// log writes one record.
+func (l *sink) log(level, msg string, err error, kvList []any) {
+	record := make([]string, numFixed+len(l.opts.Columns)+1)
+	record[colTime] = l.opts.Clock().Format(l.opts.TimestampFormat)
+	record[colLevel] = level
+	record[colName] = l.name
+	record[colMsg] = msg
+	if err != nil {
+		record[colError] = render.Text(l.fmtr, err)
+	}
+
+	var extra []any
+	for _, kvs := range [][]any{l.values, kvList} {
+		for i := 0; i < len(kvs); i += 2 {
+			if k, ok := kvs[i].(string); ok && i+1 < len(kvs) {
+				if col, found := l.columns[k]; found {
+					record[col] = render.Text(l.fmtr, kvs[i+1])
+					continue
+				}
+			}
+			if i+1 < len(kvs) {
+				extra = append(extra, kvs[i], kvs[i+1])
+			} else {
+				extra = append(extra, kvs[i], render.NoValue)
+			}
+		}
+	}
+	if len(extra) > 0 {
+		_, record[len(record)-1] = l.fmtr.FormatInfo(0, "", extra)
+	}
+
+	l.out.write(record)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package csvr
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type point struct{ X, Y int }
+
+type stringer struct{}
+
+func (stringer) String() string { return "I am a Stringer" }
+
+func TestLogger(t *testing.T) {
+	now := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	buf := &bytes.Buffer{}
+	log := New(buf, Options{
+		Columns: []string{"user", "count"},
+		Header:  true,
+		Clock:   clock,
+	})
+	log = log.WithName("server").WithValues("user", "alice", "saved", 1)
+	log.Info("plain")
+	log.WithName("handler").V(0).Info("columns", "count", 3, "user", "bob")
+	log.Info("extra", "point", point{1, 2}, "str", "a\nb", 42, "bad key", "odd")
+	log.Error(fmt.Errorf("oops, \"it\" broke"), "failed", "count", stringer{})
+	log.V(1).Info("not logged")
+
+	expect := strings.Join([]string{
+		`time,level,name,msg,error,user,count,extra`,
+		`2006-01-02T15:04:05Z,0,server,plain,,alice,,"{""saved"":1}"`,
+		`2006-01-02T15:04:05Z,0,server/handler,columns,,bob,3,"{""saved"":1}"`,
+		`2006-01-02T15:04:05Z,0,server,extra,,alice,,"{""saved"":1,""point"":{""X"":1,""Y"":2},""str"":""a\nb"",""<non-string-key: 42>"":""bad key"",""odd"":""<no-value>""}"`,
+		`2006-01-02T15:04:05Z,error,server,failed,"oops, ""it"" broke",alice,I am a Stringer,"{""saved"":1}"`,
+		``,
+	}, "\n")
+	if got := buf.String(); got != expect {
+		t.Errorf("\nexpected:\n%s\n     got:\n%s", expect, got)
+	}
+}
+
+func TestLoggerTSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log := New(buf, Options{
+		TSV:             true,
+		TimestampFormat: "15:04",
+		Clock:           func() time.Time { return time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC) },
+		Verbosity:       2,
+	})
+	log.V(2).Info("tab\there", "k", []int{1, 2})
+	log.V(3).Info("not logged")
+
+	expect := "15:04\t2\t\t\"tab\there\"\t\t\"{\"\"k\"\":[1,2]}\"\n"
+	if got := buf.String(); got != expect {
+		t.Errorf("\nexpected %q\n     got %q", expect, got)
+	}
+}
+
+func TestLoggerConcurrent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log := New(buf, Options{Columns: []string{"i"}, Header: true})
+
+	const n = 100
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			log.WithValues("i", i).Info("multi\nline")
+		}(i)
+	}
+	wg.Wait()
+
+	records, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != n+1 {
+		t.Fatalf("expected %d records, got %d", n+1, len(records))
+	}
+	if expect := []string{"time", "level", "name", "msg", "error", "i", "extra"}; !reflect.DeepEqual(records[0], expect) {
+		t.Errorf("expected header %q, got %q", expect, records[0])
+	}
+	seen := map[string]bool{}
+	for _, rec := range records[1:] {
+		if rec[3] != "multi\nline" {
+			t.Errorf("wrong msg: %q", rec[3])
+		}
+		seen[rec[5]] = true
+	}
+	if len(seen) != n {
+		t.Errorf("expected %d distinct records, got %d", n, len(seen))
+	}
+}
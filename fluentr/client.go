@@ -0,0 +1,323 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluentr
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/internal/batch"
+	"github.com/go-logr/logr/internal/render"
+)
+
+// Stats describes the health of a Client.
+type Stats struct {
+	// Connected tells whether the client is connected to the server.
+	Connected bool
+	// LastError is the last error from connecting or sending, or nil.
+	LastError error
+	// Connects is the number of times the client connected.
+	Connects uint64
+	// Sent is the number of events sent, and acknowledged with
+	// Options.RequireAck.
+	Sent uint64
+	// Dropped is the number of events which were dropped: because the
+	// queue was full, or because they could not be sent before Close.
+	Dropped uint64
+}
+
+// errBadAck is recorded when the server acknowledges the wrong message.
+var errBadAck = errors.New("unexpected acknowledgement from the server")
+
+// event is a log line waiting to be sent.
+type event struct {
+	tag   string
+	entry []byte // [time, record]
+}
+
+// Client sends events to a Fluentd or Fluent Bit server.  It is safe for
+// concurrent use.
+type Client struct {
+	network string
+	addr    string
+	opts    Options
+
+	batches *batch.Batcher[event]
+	once    sync.Once
+
+	mu    sync.Mutex
+	stats Stats
+
+	// These are only used by send.
+	conn    net.Conn // nil while disconnected
+	r       *bufio.Reader
+	backoff batch.Backoff
+}
+
+// New returns a Client which sends events to the server at addr, over
+// network, which is "tcp", "tcp4", "tcp6" or "unix".  It does not connect
+// yet: if the server can not be reached, the Client keeps trying in the
+// background.
+func New(network, addr string, opts Options) (*Client, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("unsupported network %q", network)
+	}
+	if opts.Tag == "" {
+		opts.Tag = "logr"
+	}
+	if opts.AckTimeout == 0 {
+		opts.AckTimeout = 30 * time.Second
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = opts.MinBackoff
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = 256
+	}
+	if opts.BatchTimeout == 0 {
+		opts.BatchTimeout = time.Second
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = 2048
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	c := &Client{
+		network: network,
+		addr:    addr,
+		opts:    opts,
+		backoff: batch.Backoff{Min: opts.MinBackoff, Max: opts.MaxBackoff},
+	}
+	c.batches = batch.New(batch.Options{
+		Size:      opts.BatchSize,
+		Timeout:   opts.BatchTimeout,
+		QueueSize: opts.QueueSize,
+	}, nil, c.send)
+	return c, nil
+}
+
+// Logger returns a logr.Logger which sends events through c, with records
+// rendered by funcr with opts.  StrictJSON is always set, so that each
+// record can be converted.
+func (c *Client) Logger(opts funcr.Options) logr.Logger {
+	return logr.New(render.NewSink(opts, func(name, obj string) {
+		c.enqueue(c.tag(name), obj)
+	}))
+}
+
+func (c *Client) enqueue(tag, obj string) {
+	entry := appendArrayHeader(nil, 2)
+	entry = appendEventTime(entry, c.opts.Clock())
+	entry, err := appendJSON(entry, obj)
+	if err != nil {
+		// Not expected with StrictJSON.
+		c.updateStats(func(s *Stats) {
+			s.Dropped++
+			s.LastError = err
+		})
+		return
+	}
+	if !c.batches.Add(event{tag, entry}) {
+		c.updateStats(func(s *Stats) { s.Dropped++ })
+	}
+}
+
+// Stats returns the current health of c.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *Client) updateStats(fn func(*Stats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.stats)
+}
+
+// Flush sends the queued events, and waits until they were sent or dropped.
+// While the server can not be reached, that may take long.
+func (c *Client) Flush() {
+	c.batches.Flush()
+}
+
+// Close sends the queued events, and closes the connection.  Messages which
+// fail are not sent again anymore.  Events logged after Close are dropped.
+func (c *Client) Close() error {
+	c.once.Do(func() {
+		c.batches.Close()
+		// Nothing is sent anymore, so the connection is free.
+		c.disconnect(nil)
+	})
+	return nil
+}
+
+// send sends a batch as one message per tag, in the order in which each tag
+// first appears.
+func (c *Client) send(events []event) {
+	var tags []string
+	entries := map[string][][]byte{}
+	for _, ev := range events {
+		if _, found := entries[ev.tag]; !found {
+			tags = append(tags, ev.tag)
+		}
+		entries[ev.tag] = append(entries[ev.tag], ev.entry)
+	}
+	for _, tag := range tags {
+		c.deliver(tag, entries[tag])
+	}
+}
+
+// deliver sends one message until it succeeds, or until Close.
+func (c *Client) deliver(tag string, entries [][]byte) {
+	var chunk string
+	if c.opts.RequireAck {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			c.updateStats(func(s *Stats) {
+				s.Dropped += uint64(len(entries))
+				s.LastError = err
+			})
+			return
+		}
+		chunk = base64.StdEncoding.EncodeToString(id)
+	}
+	msg := c.message(tag, entries, chunk)
+	for {
+		err := c.connect()
+		if err == nil {
+			if err = c.write(msg, chunk); err == nil {
+				c.backoff.Reset()
+				c.updateStats(func(s *Stats) { s.Sent += uint64(len(entries)) })
+				return
+			}
+			c.disconnect(err)
+		}
+		if !c.batches.Wait(c.backoff.Next()) {
+			c.updateStats(func(s *Stats) { s.Dropped += uint64(len(entries)) })
+			return
+		}
+	}
+}
+
+// message encodes the entries of one tag, in Forward or PackedForward mode,
+// with the chunk ID to acknowledge, if any.
+func (c *Client) message(tag string, entries [][]byte, chunk string) []byte {
+	buf := appendArrayHeader(nil, 3)
+	buf = appendString(buf, tag)
+	if c.opts.PackedForward {
+		var packed []byte
+		for _, e := range entries {
+			packed = append(packed, e...)
+		}
+		buf = appendBin(buf, packed)
+	} else {
+		buf = appendArrayHeader(buf, len(entries))
+		for _, e := range entries {
+			buf = append(buf, e...)
+		}
+	}
+	if chunk == "" {
+		buf = appendMapHeader(buf, 1)
+	} else {
+		buf = appendMapHeader(buf, 2)
+		buf = appendString(buf, "chunk")
+		buf = appendString(buf, chunk)
+	}
+	buf = appendString(buf, "size")
+	return appendInt(buf, int64(len(entries)))
+}
+
+// connect connects to the server, unless the client is connected already.
+func (c *Client) connect() error {
+	if c.conn != nil {
+		return nil
+	}
+	conn, err := net.DialTimeout(c.network, c.addr, c.opts.DialTimeout)
+	if err != nil {
+		c.updateStats(func(s *Stats) { s.LastError = err })
+		return err
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	c.updateStats(func(s *Stats) {
+		s.Connected = true
+		s.Connects++
+	})
+	return nil
+}
+
+// disconnect closes the connection, if any, and records err if it is not
+// nil.
+func (c *Client) disconnect(err error) {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn, c.r = nil, nil
+	}
+	c.updateStats(func(s *Stats) {
+		s.Connected = false
+		if err != nil {
+			s.LastError = err
+		}
+	})
+}
+
+// write writes a message, and waits for its acknowledgement if chunk is set.
+func (c *Client) write(msg []byte, chunk string) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout)); err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(msg); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.opts.AckTimeout)); err != nil {
+		return err
+	}
+	ack, err := readAck(c.r)
+	if err != nil {
+		return err
+	}
+	if ack != chunk {
+		return errBadAck
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluentr_test
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr/fluentr"
+	"github.com/go-logr/logr/funcr"
+)
+
+func ExampleNew() {
+	// Send to the forward input of the local Fluent Bit, or to a Unix
+	// socket with fluentr.New("unix", "/var/run/fluent.sock", ...).
+	client, err := fluentr.New("tcp", "127.0.0.1:24224", fluentr.Options{
+		Tag:        "app",
+		RequireAck: true,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer client.Close()
+
+	log := client.Logger(funcr.Options{Verbosity: 1})
+	// Tagged "app.server".
+	log.WithName("server").Info("started", "port", 8080)
+}
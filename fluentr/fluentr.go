@@ -0,0 +1,101 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fluentr implements github.com/go-logr/logr.Logger in terms of the
+// Forward protocol of Fluentd and Fluent Bit, which is MessagePack over TCP
+// or a Unix socket.  See
+// https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1.
+//
+// Each log line is one event.  Its record is the JSON object rendered by
+// funcr, converted to MessagePack, and its time is an EventTime, with
+// nanoseconds.  Its tag is Options.Tag, followed by the logger name with '/'
+// replaced by '.', like "app.server.http" for a logger named "server/http".
+//
+// A Client batches events and sends them in the background, one message per
+// tag and batch, in Forward mode, or in PackedForward mode if
+// Options.PackedForward is set.  With Options.RequireAck, the server
+// acknowledges each message, and messages which were not acknowledged are
+// sent again over a new connection, so that each event is delivered at least
+// once.  Without it, events written to a connection which broke may be lost.
+//
+// Logging never blocks on the network: events which do not fit in the queue,
+// for example while the server can not be reached, are dropped and counted
+// in Stats.
+package fluentr
+
+import (
+	"strings"
+	"time"
+)
+
+// Options carries parameters which influence the way events are sent.
+type Options struct {
+	// Tag is the tag of events from loggers without a name, and the prefix
+	// of the tags of loggers with one.  If not specified, "logr" is used.
+	Tag string
+
+	// PackedForward tells the client to send events in PackedForward mode,
+	// as one MessagePack binary per message, which servers can pass on
+	// without decoding each event.
+	PackedForward bool
+
+	// RequireAck tells the client to ask the server to acknowledge each
+	// message, and to send it again if it does not.
+	RequireAck bool
+
+	// AckTimeout limits how long the client waits for an acknowledgement.
+	// If not specified, 30 seconds is used.
+	AckTimeout time.Duration
+
+	// DialTimeout limits how long connecting may take.  If not specified,
+	// 10 seconds is used.
+	DialTimeout time.Duration
+
+	// WriteTimeout limits how long writing a message may take.  If not
+	// specified, 10 seconds is used.
+	WriteTimeout time.Duration
+
+	// MinBackoff is how long the client waits before it tries again after
+	// connecting or sending failed, and MaxBackoff is the longest it waits,
+	// doubling the wait after each failed attempt.  If not specified, 100
+	// milliseconds and 30 seconds are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// BatchSize is the most events sent in one batch.  If not specified,
+	// 256 is used.
+	BatchSize int
+
+	// BatchTimeout is how long events may wait for a batch to fill up.  If
+	// not specified, 1 second is used.
+	BatchTimeout time.Duration
+
+	// QueueSize is the number of events which are queued in memory while a
+	// batch is sent.  If not specified, 2048 is used.
+	QueueSize int
+
+	// Clock tells the client how to get the current time, for the time of
+	// events.  If not specified, time.Now is used.
+	Clock func() time.Time
+}
+
+// tag returns the tag of the events of a logger.
+func (c *Client) tag(name string) string {
+	if name == "" {
+		return c.opts.Tag
+	}
+	return c.opts.Tag + "." + strings.ReplaceAll(name, "/", ".")
+}
@@ -0,0 +1,432 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluentr
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+)
+
+// message is a message received by a server.
+type message struct {
+	tag     string
+	packed  bool
+	entries []entry
+	option  map[string]any
+}
+
+// entry is an event in a message.
+type entry struct {
+	time   time.Time
+	record map[string]any
+}
+
+// server is a fake Fluentd, which decodes the messages it receives.
+type server struct {
+	t        *testing.T
+	ln       net.Listener
+	messages chan message
+
+	// ack returns the acknowledgement of the i-th message with an ack
+	// chunk, or "" to close the connection instead.  If nil, each message
+	// is acknowledged.
+	ack func(i int, chunk string) string
+
+	mu    sync.Mutex
+	acked int
+}
+
+func listen(t *testing.T, addr string, ack func(i int, chunk string) string) *server {
+	t.Helper()
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	var ln net.Listener
+	var err error
+	// The address of a stopped server may take a moment to be free.
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if ln, err = net.Listen("tcp", addr); err == nil || time.Now().After(deadline) {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{t: t, ln: ln, messages: make(chan message, 100), ack: ack}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *server) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		v, err := readValue(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.t.Errorf("read: %v", err)
+			}
+			return
+		}
+		msg, err := decodeMessage(v)
+		if err != nil {
+			s.t.Errorf("decode: %v", err)
+			return
+		}
+		s.messages <- msg
+		chunk, _ := msg.option["chunk"].(string)
+		if chunk == "" {
+			continue
+		}
+		ack := chunk
+		if s.ack != nil {
+			s.mu.Lock()
+			ack = s.ack(s.acked, chunk)
+			s.acked++
+			s.mu.Unlock()
+		}
+		if ack == "" {
+			return
+		}
+		if _, err := conn.Write(appendString(appendString(appendMapHeader(nil, 1), "ack"), ack)); err != nil {
+			return
+		}
+	}
+}
+
+// decodeMessage decodes a message in Forward or PackedForward mode.
+func decodeMessage(v any) (message, error) {
+	arr, ok := v.([]any)
+	if !ok || len(arr) != 3 {
+		return message{}, errors.New("message is not an array of 3")
+	}
+	var msg message
+	if msg.tag, ok = arr[0].(string); !ok {
+		return message{}, errors.New("tag is not a string")
+	}
+	if msg.option, ok = arr[2].(map[string]any); !ok {
+		return message{}, errors.New("option is not a map")
+	}
+	var entries []any
+	switch e := arr[1].(type) {
+	case []any:
+		entries = e
+	case []byte:
+		msg.packed = true
+		r := bufio.NewReader(bytes.NewReader(e))
+		for {
+			v, err := readValue(r)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return message{}, err
+			}
+			entries = append(entries, v)
+		}
+	default:
+		return message{}, errors.New("entries are neither an array nor a binary")
+	}
+	for _, e := range entries {
+		pair, ok := e.([]any)
+		if !ok || len(pair) != 2 {
+			return message{}, errors.New("entry is not an array of 2")
+		}
+		var ent entry
+		if ent.time, ok = pair[0].(time.Time); !ok {
+			return message{}, errors.New("entry time is not an EventTime")
+		}
+		if ent.record, ok = pair[1].(map[string]any); !ok {
+			return message{}, errors.New("entry record is not a map")
+		}
+		msg.entries = append(msg.entries, ent)
+	}
+	return msg, nil
+}
+
+func (s *server) receive() message {
+	s.t.Helper()
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(10 * time.Second):
+		s.t.Fatal("timed out waiting for a message")
+		return message{}
+	}
+}
+
+var testTime = time.Date(2006, time.January, 2, 15, 4, 5, 123456789, time.UTC)
+
+func newClient(t *testing.T, addr string, opts Options) *Client {
+	t.Helper()
+	opts.Clock = func() time.Time { return testTime }
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = 10 * time.Millisecond
+	}
+	c, err := New("tcp", addr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestLogger(t *testing.T) {
+	for _, packed := range []bool{false, true} {
+		name := "forward"
+		if packed {
+			name = "packed forward"
+		}
+		t.Run(name, func(t *testing.T) {
+			s := listen(t, "", nil)
+			c := newClient(t, s.ln.Addr().String(), Options{Tag: "app", PackedForward: packed})
+			log := c.Logger(funcr.Options{Verbosity: 1})
+			log.Info("root", "k", "v")
+			log = log.WithName("server").WithValues("port", 8080)
+			log.V(1).Info("listening", "big", uint64(1<<63), "pi", 3.5, "list", []int{1, 2})
+			log.WithName("http").Error(os.ErrNotExist, "failed", "ok", false)
+			log.V(2).Info("not logged")
+			c.Flush()
+
+			expect := []message{{
+				tag: "app",
+				entries: []entry{
+					{testTime, map[string]any{"logger": "", "level": int64(0), "msg": "root", "k": "v"}},
+				},
+				option: map[string]any{"size": int64(1)},
+			}, {
+				tag: "app.server",
+				entries: []entry{
+					{testTime, map[string]any{"logger": "server", "level": int64(1), "msg": "listening", "port": int64(8080), "big": uint64(1 << 63), "pi": 3.5, "list": []any{int64(1), int64(2)}}},
+				},
+				option: map[string]any{"size": int64(1)},
+			}, {
+				tag: "app.server.http",
+				entries: []entry{
+					{testTime, map[string]any{"logger": "server/http", "msg": "failed", "error": "file does not exist", "port": int64(8080), "ok": false}},
+				},
+				option: map[string]any{"size": int64(1)},
+			}}
+			for _, e := range expect {
+				e.packed = packed
+				got := s.receive()
+				for i := range got.entries {
+					got.entries[i].time = got.entries[i].time.UTC()
+				}
+				if !reflect.DeepEqual(got, e) {
+					t.Errorf("expected %+v, got %+v", e, got)
+				}
+			}
+			if st := c.Stats(); st.Sent != 3 || st.Dropped != 0 || !st.Connected {
+				t.Errorf("unexpected stats %+v", st)
+			}
+		})
+	}
+}
+
+func TestLoggerCaller(t *testing.T) {
+	s := listen(t, "", nil)
+	c := newClient(t, s.ln.Addr().String(), Options{Tag: "app"})
+	log := c.Logger(funcr.Options{LogCaller: funcr.All})
+	log.Info("msg")
+	c.Flush()
+	got := s.receive()
+	caller, _ := got.entries[0].record["caller"].(map[string]any)
+	if file := caller["file"]; file != "fluentr_test.go" {
+		t.Errorf("expected the caller in fluentr_test.go, got %v", got.entries[0].record["caller"])
+	}
+}
+
+func TestBatching(t *testing.T) {
+	s := listen(t, "", nil)
+	c := newClient(t, s.ln.Addr().String(), Options{BatchSize: 3, BatchTimeout: time.Hour})
+	log := c.Logger(funcr.Options{})
+	a, b := log.WithName("a"), log.WithName("b")
+	// The first batch is full after three events, with one message per
+	// tag.
+	a.Info("1")
+	b.Info("2")
+	a.Info("3")
+	// The second is sent by Flush.
+	b.Info("4")
+	c.Flush()
+
+	for _, e := range []struct {
+		tag  string
+		msgs []string
+	}{{"logr.a", []string{"1", "3"}}, {"logr.b", []string{"2"}}, {"logr.b", []string{"4"}}} {
+		got := s.receive()
+		var msgs []string
+		for _, ent := range got.entries {
+			msgs = append(msgs, ent.record["msg"].(string))
+		}
+		if got.tag != e.tag || !reflect.DeepEqual(msgs, e.msgs) {
+			t.Errorf("expected %s %v, got %s %v", e.tag, e.msgs, got.tag, msgs)
+		}
+		if size := got.option["size"]; size != int64(len(e.msgs)) {
+			t.Errorf("expected size %d, got %v", len(e.msgs), size)
+		}
+	}
+}
+
+func TestAck(t *testing.T) {
+	testCases := []struct {
+		name string
+		ack  func(i int, chunk string) string
+	}{{
+		name: "dropped",
+		ack: func(i int, chunk string) string {
+			if i == 0 {
+				return ""
+			}
+			return chunk
+		},
+	}, {
+		name: "wrong",
+		ack: func(i int, chunk string) string {
+			if i == 0 {
+				return "wrong"
+			}
+			return chunk
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := listen(t, "", tc.ack)
+			c := newClient(t, s.ln.Addr().String(), Options{RequireAck: true})
+			c.Logger(funcr.Options{}).Info("hello")
+			c.Flush()
+
+			// The message arrives twice, with the same chunk.
+			first, second := s.receive(), s.receive()
+			if first.option["chunk"] == nil || first.option["chunk"] != second.option["chunk"] {
+				t.Errorf("expected the same chunk, got %v and %v", first.option["chunk"], second.option["chunk"])
+			}
+			if msg := second.entries[0].record["msg"]; msg != "hello" {
+				t.Errorf("expected hello, got %v", msg)
+			}
+			if st := c.Stats(); st.Sent != 1 || st.Connects != 2 || st.LastError == nil {
+				t.Errorf("unexpected stats %+v", st)
+			}
+		})
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	// Find a free address, and start the server only after logging.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := newClient(t, addr, Options{RequireAck: true, BatchTimeout: time.Millisecond})
+	c.Logger(funcr.Options{}).Info("early")
+	deadline := time.Now().Add(10 * time.Second)
+	for c.Stats().LastError == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for a failed connect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	s := listen(t, addr, nil)
+	if msg := s.receive().entries[0].record["msg"]; msg != "early" {
+		t.Errorf("expected early, got %v", msg)
+	}
+	c.Flush()
+	if st := c.Stats(); st.Sent != 1 || st.Connects != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestClose(t *testing.T) {
+	// Nothing listens at the address, so Close drops the queued events
+	// after one attempt.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := newClient(t, addr, Options{BatchTimeout: time.Hour, MinBackoff: time.Hour})
+	log := c.Logger(funcr.Options{})
+	log.Info("one")
+	log.Info("two")
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close did not return")
+	}
+	log.Info("late")
+	if st := c.Stats(); st.Dropped != 3 || st.Sent != 0 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := newClient(t, addr, Options{QueueSize: 2, BatchSize: 1, MinBackoff: time.Hour})
+	log := c.Logger(funcr.Options{})
+	// The first event is stuck being retried, two are queued, and the rest
+	// are dropped.
+	for i := 0; i < 10; i++ {
+		log.Info("hello")
+		time.Sleep(time.Millisecond)
+	}
+	if dropped := c.Stats().Dropped; dropped < 7 {
+		t.Errorf("expected at least 7 dropped events, got %d", dropped)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New("udp", "127.0.0.1:24224", Options{}); err == nil {
+		t.Error("expected an error for UDP")
+	}
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluentr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The functions below implement the parts of MessagePack which the Forward
+// protocol needs.  See https://github.com/msgpack/msgpack/blob/master/spec.md.
+
+// appendBigEndian appends the size lower bytes of u, most significant first.
+func appendBigEndian(buf []byte, u uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		buf = append(buf, byte(u>>(8*i)))
+	}
+	return buf
+}
+
+func appendNil(buf []byte) []byte {
+	return append(buf, 0xc0)
+}
+
+func appendBool(buf []byte, b bool) []byte {
+	if b {
+		return append(buf, 0xc3)
+	}
+	return append(buf, 0xc2)
+}
+
+func appendInt(buf []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		return append(buf, byte(i))
+	case i < 0 && i >= -32:
+		return append(buf, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(buf, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return appendBigEndian(append(buf, 0xd1), uint64(i), 2)
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return appendBigEndian(append(buf, 0xd2), uint64(i), 4)
+	}
+	return appendBigEndian(append(buf, 0xd3), uint64(i), 8)
+}
+
+func appendUint(buf []byte, u uint64) []byte {
+	if u <= math.MaxInt64 {
+		return appendInt(buf, int64(u))
+	}
+	return appendBigEndian(append(buf, 0xcf), u, 8)
+}
+
+func appendFloat(buf []byte, f float64) []byte {
+	return appendBigEndian(append(buf, 0xcb), math.Float64bits(f), 8)
+}
+
+func appendString(buf []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = appendBigEndian(append(buf, 0xda), uint64(n), 2)
+	default:
+		buf = appendBigEndian(append(buf, 0xdb), uint64(n), 4)
+	}
+	return append(buf, s...)
+}
+
+func appendBin(buf []byte, b []byte) []byte {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		buf = appendBigEndian(append(buf, 0xc5), uint64(n), 2)
+	default:
+		buf = appendBigEndian(append(buf, 0xc6), uint64(n), 4)
+	}
+	return append(buf, b...)
+}
+
+func appendArrayHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendBigEndian(append(buf, 0xdc), uint64(n), 2)
+	}
+	return appendBigEndian(append(buf, 0xdd), uint64(n), 4)
+}
+
+func appendMapHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendBigEndian(append(buf, 0xde), uint64(n), 2)
+	}
+	return appendBigEndian(append(buf, 0xdf), uint64(n), 4)
+}
+
+// eventTimeType is the extension type of the EventTime of the Forward
+// protocol.
+const eventTimeType = 0
+
+// appendEventTime appends t as an EventTime: seconds and nanoseconds since
+// the epoch, as a fixext 8.
+func appendEventTime(buf []byte, t time.Time) []byte {
+	buf = append(buf, 0xd7, eventTimeType)
+	buf = appendBigEndian(buf, uint64(t.Unix()), 4)
+	return appendBigEndian(buf, uint64(t.Nanosecond()), 4)
+}
+
+// appendJSON appends a JSON value, as rendered by funcr, as MessagePack.
+// Numbers become integers if they fit into an int64 or a uint64.
+func appendJSON(buf []byte, js string) ([]byte, error) {
+	dec := json.NewDecoder(strings.NewReader(js))
+	dec.UseNumber()
+	return appendDecoded(buf, dec)
+}
+
+func appendDecoded(buf []byte, dec *json.Decoder) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case nil:
+		return appendNil(buf), nil
+	case bool:
+		return appendBool(buf, tok), nil
+	case string:
+		return appendString(buf, tok), nil
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return appendInt(buf, i), nil
+		}
+		if u, err := strconv.ParseUint(string(tok), 10, 64); err == nil {
+			return appendUint(buf, u), nil
+		}
+		f, err := tok.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return appendFloat(buf, f), nil
+	case json.Delim:
+		// The number of elements is only known at the end, so they are
+		// encoded separately first.
+		var elems []byte
+		n := 0
+		for dec.More() {
+			if tok == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				elems = appendString(elems, fmt.Sprint(key))
+			}
+			if elems, err = appendDecoded(elems, dec); err != nil {
+				return nil, err
+			}
+			n++
+		}
+		if _, err := dec.Token(); err != nil { // ] or }
+			return nil, err
+		}
+		if tok == '{' {
+			buf = appendMapHeader(buf, n)
+		} else {
+			buf = appendArrayHeader(buf, n)
+		}
+		return append(buf, elems...), nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
+
+// readLen reads a length of 1, 2 or 4 bytes, for class 0, 1 or 2.
+func readLen(r *bufio.Reader, class byte) (int, error) {
+	u, err := readUint(r, 1<<class)
+	return int(u), err
+}
+
+func readUint(r *bufio.Reader, size int) (uint64, error) {
+	data, err := readN(r, size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, b := range data {
+		u = u<<8 | uint64(b)
+	}
+	return u, nil
+}
+
+func readN(r *bufio.Reader, n int) ([]byte, error) {
+	data := make([]byte, n)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+// Limits of the acknowledgements which readAck accepts.
+const (
+	maxAckEntries = 4
+	maxAckString  = 64
+)
+
+// readAck decodes an acknowledgement, a map with the chunk ID of a message
+// under "ack", and returns the ID.  Only small maps of short strings are
+// accepted, so that a misbehaving server can not make the client allocate
+// much.
+func readAck(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if b&0xf0 != 0x80 || int(b&0x0f) > maxAckEntries {
+		return "", errBadAck
+	}
+	var ack string
+	for i := 0; i < int(b&0x0f); i++ {
+		k, err := readAckString(r)
+		if err != nil {
+			return "", err
+		}
+		v, err := readAckString(r)
+		if err != nil {
+			return "", err
+		}
+		if k == "ack" {
+			ack = v
+		}
+	}
+	return ack, nil
+}
+
+// readAckString decodes a string of at most maxAckString bytes.
+func readAckString(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	var n int
+	switch {
+	case b&0xe0 == 0xa0:
+		n = int(b & 0x1f)
+	case b == 0xd9:
+		if n, err = readLen(r, 0); err != nil {
+			return "", err
+		}
+	default:
+		return "", errBadAck
+	}
+	if n > maxAckString {
+		return "", errBadAck
+	}
+	data, err := readN(r, n)
+	return string(data), err
+}
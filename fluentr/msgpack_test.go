@@ -0,0 +1,268 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluentr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendJSON(t *testing.T) {
+	testCases := []struct {
+		json   string
+		expect any
+		hex    string // the encoding, if checked
+	}{
+		{`null`, nil, "c0"},
+		{`true`, true, "c3"},
+		{`0`, int64(0), "00"},
+		{`127`, int64(127), "7f"},
+		{`128`, int64(128), "d10080"},
+		{`-32`, int64(-32), "e0"},
+		{`-33`, int64(-33), "d0df"},
+		{`-129`, int64(-129), "d1ff7f"},
+		{`65536`, int64(65536), "d200010000"},
+		{`-2147483649`, int64(math.MinInt32 - 1), "d3ffffffff7fffffff"},
+		{`18446744073709551615`, uint64(math.MaxUint64), "cfffffffffffffffff"},
+		{`1.5`, 1.5, "cb3ff8000000000000"},
+		{`"abc"`, "abc", "a3616263"},
+		{`"` + strings.Repeat("x", 32) + `"`, strings.Repeat("x", 32), "d920" + strings.Repeat("78", 32)},
+		{`"` + strings.Repeat("x", 256) + `"`, strings.Repeat("x", 256), ""},
+		{`"` + strings.Repeat("x", 70000) + `"`, strings.Repeat("x", 70000), ""},
+		{`[1,"a",[]]`, []any{int64(1), "a", []any{}}, "9301a16190"},
+		{`{"a":{"b":null}}`, map[string]any{"a": map[string]any{"b": nil}}, "81a16181a162c0"},
+		{`[` + strings.Repeat(`0,`, 16) + `0]`, zeros(17), "dc0011" + strings.Repeat("00", 17)},
+	}
+	for _, tc := range testCases {
+		name := tc.json
+		if len(name) > 40 {
+			name = name[:40]
+		}
+		t.Run(name, func(t *testing.T) {
+			buf, err := appendJSON(nil, tc.json)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.hex != "" && hex.EncodeToString(buf) != tc.hex {
+				t.Errorf("expected %s, got %x", tc.hex, buf)
+			}
+			got, err := readValue(bufio.NewReader(bytes.NewReader(buf)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("expected %#v, got %#v", tc.expect, got)
+			}
+		})
+	}
+}
+
+func zeros(n int) []any {
+	zeros := make([]any, n)
+	for i := range zeros {
+		zeros[i] = int64(0)
+	}
+	return zeros
+}
+
+func TestEventTime(t *testing.T) {
+	when := time.Date(2006, time.January, 2, 15, 4, 5, 123456789, time.UTC)
+	buf := appendEventTime(nil, when)
+	if expect := "d70043b940e5075bcd15"; hex.EncodeToString(buf) != expect {
+		t.Errorf("expected %s, got %x", expect, buf)
+	}
+	got, err := readValue(bufio.NewReader(bytes.NewReader(buf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm, ok := got.(time.Time); !ok || !tm.Equal(when) {
+		t.Errorf("expected %v, got %v", when, got)
+	}
+}
+
+func TestAppendBin(t *testing.T) {
+	for _, n := range []int{0, 255, 256, 65536} {
+		data := bytes.Repeat([]byte{7}, n)
+		got, err := readValue(bufio.NewReader(bytes.NewReader(appendBin(nil, data))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.([]byte), data) {
+			t.Errorf("binary of %d bytes did not round-trip", n)
+		}
+	}
+}
+
+func TestReadAck(t *testing.T) {
+	str := func(s string) []byte { return appendString(nil, s) }
+	cat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	long := strings.Repeat("x", maxAckString+1)
+	testCases := []struct {
+		name   string
+		data   []byte
+		expect string
+		err    bool
+	}{
+		{"ack", cat(appendMapHeader(nil, 1), str("ack"), str("id")), "id", false},
+		{"other entries", cat(appendMapHeader(nil, 2), str("x"), str("y"), str("ack"), str("id")), "id", false},
+		{"str8", cat(appendMapHeader(nil, 1), str("ack"), str(strings.Repeat("i", maxAckString))), strings.Repeat("i", maxAckString), false},
+		{"no ack", cat(appendMapHeader(nil, 0)), "", false},
+		{"too many entries", cat(appendMapHeader(nil, maxAckEntries+1)), "", true},
+		{"map16", cat(appendMapHeader(nil, 16)), "", true},
+		{"long string", cat(appendMapHeader(nil, 1), str("ack"), str(long)), "", true},
+		{"str16", cat(appendMapHeader(nil, 1), str("ack"), str(strings.Repeat("x", 256))), "", true},
+		{"not a string", cat(appendMapHeader(nil, 1), str("ack"), appendMapHeader(nil, 1)), "", true},
+		{"array", cat(appendArrayHeader(nil, 1), str("ack")), "", true},
+		{"truncated", cat(appendMapHeader(nil, 1), str("ack"))[:3], "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readAck(bufio.NewReader(bytes.NewReader(tc.data)))
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil || got != tc.expect {
+				t.Errorf("expected %q, got %q, %v", tc.expect, got, err)
+			}
+		})
+	}
+}
+
+// readValue decodes one MessagePack value: nil, bool, int64, uint64,
+// float64, string, []byte, []any, map[string]any with the keys formatted by
+// fmt.Sprint, or a time.Time for an EventTime.  Other extension types are
+// not supported.  Only tests use it, to check what the client sends, so it
+// has no limits.
+func readValue(r *bufio.Reader) (any, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return readMap(r, int(b&0x0f))
+	case b&0xf0 == 0x90:
+		return readArray(r, int(b&0x0f))
+	case b&0xe0 == 0xa0:
+		data, err := readN(r, int(b&0x1f))
+		return string(data), err
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readLen(r, b-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return readN(r, n)
+	case 0xca:
+		u, err := readUint(r, 4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := readUint(r, 8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := readUint(r, 1<<(b-0xcc))
+		if err != nil || u > math.MaxInt64 {
+			return u, err
+		}
+		return int64(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		u, err := readUint(r, size)
+		// Shift the sign bit to the top and back to extend it.
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, err
+	case 0xd7:
+		data, err := readN(r, 9)
+		if err != nil {
+			return nil, err
+		}
+		if data[0] != eventTimeType {
+			return nil, fmt.Errorf("unsupported extension type %d", int8(data[0]))
+		}
+		return time.Unix(int64(binary.BigEndian.Uint32(data[1:])), int64(binary.BigEndian.Uint32(data[5:]))), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := readLen(r, b-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readN(r, n)
+		return string(data), err
+	case 0xdc, 0xdd:
+		n, err := readLen(r, b-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return readArray(r, n)
+	case 0xde, 0xdf:
+		n, err := readLen(r, b-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return readMap(r, n)
+	}
+	return nil, fmt.Errorf("unsupported MessagePack type 0x%02x", b)
+}
+
+func readArray(r *bufio.Reader, n int) ([]any, error) {
+	arr := make([]any, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+func readMap(r *bufio.Reader, n int) (map[string]any, error) {
+	m := make(map[string]any, n)
+	for i := 0; i < n; i++ {
+		k, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(k)] = v
+	}
+	return m, nil
+}
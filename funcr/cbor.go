@@ -0,0 +1,475 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package funcr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// CBOR major types, which are the top 3 bits of the first byte of an item.
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+	cborSimple = 7 << 5
+
+	cborMajorMask = 7 << 5
+)
+
+// Single-byte CBOR items.
+const (
+	cborFalse      = cborSimple | 20
+	cborTrue       = cborSimple | 21
+	cborNull       = cborSimple | 22
+	cborFloat32    = cborSimple | 26
+	cborFloat64    = cborSimple | 27
+	cborBreak      = cborSimple | 31
+	cborArrayStart = cborArray | 31 // indefinite length
+	cborMapStart   = cborMap | 31   // indefinite length
+)
+
+// appendCBORHead renders the first bytes of a CBOR item: the major type and
+// its argument, which is a length or an integer value.
+func appendCBORHead(buf []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(buf, major|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(buf, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return appendBigEndian(append(buf, major|25), arg, 2)
+	case arg <= math.MaxUint32:
+		return appendBigEndian(append(buf, major|26), arg, 4)
+	}
+	return appendBigEndian(append(buf, major|27), arg, 8)
+}
+
+// appendBigEndian renders the low n bytes of v, most significant first.
+func appendBigEndian(buf []byte, v uint64, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*i)))
+	}
+	return buf
+}
+
+func appendCBORInt(buf []byte, v int64) []byte {
+	if v < 0 {
+		return appendCBORHead(buf, cborNegInt, uint64(^v)) // -1 - v
+	}
+	return appendCBORHead(buf, cborUint, uint64(v))
+}
+
+// appendCBORFloat renders a float with the precision it came from, so that
+// converting it back to JSON formats it the same way as JSON output would.
+func appendCBORFloat(buf []byte, v float64, bitSize int) []byte {
+	if bitSize == 32 {
+		return appendBigEndian(append(buf, cborFloat32), uint64(math.Float32bits(float32(v))), 4)
+	}
+	return appendBigEndian(append(buf, cborFloat64), math.Float64bits(v), 8)
+}
+
+// appendCBORString renders a CBOR text string.  These must be valid UTF-8,
+// so invalid bytes are replaced with U+FFFD, as with Options.StrictJSON.
+func appendCBORString(buf []byte, s string) []byte {
+	if !utf8.ValidString(s) {
+		s = toValidUTF8(s)
+	}
+	buf = appendCBORHead(buf, cborText, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendCBORText is like appendCBORString, for text which is already in a
+// byte slice.
+func appendCBORText(buf []byte, txt []byte) []byte {
+	if !utf8.Valid(txt) {
+		return appendCBORString(buf, string(txt))
+	}
+	buf = appendCBORHead(buf, cborText, uint64(len(txt)))
+	return append(buf, txt...)
+}
+
+// cborTextPayload returns the contents of a rendered CBOR text string.
+func cborTextPayload(item string) string {
+	if item == "" || item[0]&cborMajorMask != cborText {
+		return item
+	}
+	switch item[0] &^ cborMajorMask {
+	case 24:
+		return item[2:]
+	case 25:
+		return item[3:]
+	case 26:
+		return item[5:]
+	case 27:
+		return item[9:]
+	}
+	return item[1:]
+}
+
+// toValidUTF8 replaces each invalid byte in s with U+FFFD.  Unlike
+// strings.ToValidUTF8, this does not merge runs of invalid bytes.
+func toValidUTF8(s string) string {
+	buf := make([]byte, 0, len(s)+8)
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, "\ufffd"...)
+		} else {
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return string(buf)
+}
+
+// textFormatter returns a Formatter which renders text, for values which are
+// needed as text, like map keys.  For CBOR output, it renders JSON.
+func (f Formatter) textFormatter() Formatter {
+	if f.outputFormat == outputCBOR {
+		f.outputFormat = outputJSON
+	}
+	return f
+}
+
+// appendJSONAsCBOR renders JSON, e.g. from MarshalJSON, as the equivalent
+// CBOR.  Numbers become integers if they fit in 64 bits, and floats
+// otherwise.  Invalid JSON is rendered as a string describing why it is not
+// valid, naming where it came from.
+func (f Formatter) appendJSONAsCBOR(buf []byte, js []byte, source string) []byte {
+	if !json.Valid(js) {
+		err := json.Compact(&bytes.Buffer{}, js) // to explain why
+		return f.appendString(buf, fmt.Sprintf("<invalid-%s: %s>", source, err.Error()))
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return buf // io.EOF, since js is valid
+		}
+		switch tok := tok.(type) {
+		case json.Delim:
+			buf = f.appendDelim(buf, byte(tok))
+		case bool:
+			buf = f.appendBool(buf, tok)
+		case string:
+			buf = f.appendString(buf, tok)
+		case json.Number:
+			if i, err := tok.Int64(); err == nil {
+				buf = appendCBORInt(buf, i)
+			} else if u, err := strconv.ParseUint(string(tok), 10, 64); err == nil {
+				buf = appendCBORHead(buf, cborUint, u)
+			} else {
+				fl, _ := tok.Float64() // out of range is ±Inf, which is fine
+				buf = appendCBORFloat(buf, fl, 64)
+			}
+		case nil:
+			buf = append(buf, cborNull)
+		}
+	}
+}
+
+// CBORToJSON converts a log line produced by a Formatter from
+// NewFormatterCBOR to JSON, for inspection by people and by tools which don't
+// understand CBOR.  The result is what NewFormatterJSON would have produced
+// with Options.StrictJSON set, except that numbers from MarshalJSON and
+// json.RawMessage may be formatted differently, and invalid UTF-8 has been
+// replaced by U+FFFD itself rather than by its escape.
+//
+// The data may hold several log lines, one after another.  CBORToJSON
+// converts the first and also returns how many bytes of data it used.  Any
+// well-formed CBOR item can be converted: byte strings become base64
+// strings, tags are ignored, and map keys which are not text become the text
+// of their JSON form.  Such keys may not contain other keys which are not
+// text, which funcr never writes.
+func CBORToJSON(data []byte) (string, int, error) {
+	d := cborDecoder{data: data}
+	buf, err := d.appendItem(nil)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(buf), d.pos, nil
+}
+
+// maxCBORDepth limits how deeply CBORToJSON will follow nested items, so that
+// malicious input can't exhaust the stack.
+const maxCBORDepth = 1000
+
+// cborDecoder converts CBOR to JSON.
+type cborDecoder struct {
+	data  []byte
+	pos   int
+	depth int
+	inKey bool // converting a map key which is not text
+}
+
+func (d *cborDecoder) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid CBOR at offset %d: %s", d.pos, fmt.Sprintf(format, args...))
+}
+
+// next consumes n bytes.
+func (d *cborDecoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, fmt.Errorf("truncated CBOR at offset %d: %w", d.pos, io.ErrUnexpectedEOF)
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// head consumes the first bytes of an item, returning its major type, its
+// additional information (the low 5 bits of the first byte), and its
+// argument.  Indefinite lengths and breaks have info 31 and argument 0.
+func (d *cborDecoder) head() (major, info byte, arg uint64, err error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]&cborMajorMask, b[0]&^cborMajorMask
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 31:
+		if major == cborUint || major == cborNegInt || major == cborTag {
+			d.pos--
+			return 0, 0, 0, d.errorf("indefinite length for major type %d", major>>5)
+		}
+		return major, info, 0, nil
+	case info > 27:
+		d.pos--
+		return 0, 0, 0, d.errorf("reserved additional information %d", info)
+	}
+	n := uint64(1) << (info - 24)
+	if b, err = d.next(n); err != nil {
+		return 0, 0, 0, err
+	}
+	for _, c := range b {
+		arg = arg<<8 | uint64(c)
+	}
+	return major, info, arg, nil
+}
+
+// isBreak determines whether the next byte ends an indefinite-length item,
+// and consumes it if so.
+func (d *cborDecoder) isBreak() (bool, error) {
+	if d.pos >= len(d.data) {
+		return false, fmt.Errorf("truncated CBOR at offset %d: %w", d.pos, io.ErrUnexpectedEOF)
+	}
+	if d.data[d.pos] == cborBreak {
+		d.pos++
+		return true, nil
+	}
+	return false, nil
+}
+
+// appendItem converts one item to JSON.
+func (d *cborDecoder) appendItem(buf []byte) ([]byte, error) {
+	start := d.pos
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		return strconv.AppendUint(buf, arg, 10), nil
+	case cborNegInt:
+		if arg == math.MaxUint64 {
+			return append(buf, "-18446744073709551616"...), nil
+		}
+		return strconv.AppendUint(append(buf, '-'), arg+1, 10), nil
+	case cborBytes, cborText:
+		s, err := d.str(major, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborBytes {
+			buf = append(buf, '"')
+			buf = append(buf, base64.StdEncoding.EncodeToString(s)...)
+			return append(buf, '"'), nil
+		}
+		return appendJSONString(buf, string(s)), nil
+	case cborArray, cborMap:
+		if d.depth++; d.depth > maxCBORDepth {
+			d.pos = start
+			return nil, d.errorf("nested too deeply")
+		}
+		defer func() { d.depth-- }()
+		if major == cborArray {
+			return d.appendArray(buf, info, arg)
+		}
+		return d.appendMap(buf, info, arg)
+	case cborTag:
+		return d.appendItem(buf)
+	}
+	switch info {
+	case 20:
+		return append(buf, "false"...), nil
+	case 21:
+		return append(buf, "true"...), nil
+	case 22, 23: // null, undefined
+		return append(buf, "null"...), nil
+	case 25:
+		return appendJSONFloat(buf, float64(float16ToFloat32(uint16(arg))), 32), nil
+	case 26:
+		return appendJSONFloat(buf, float64(math.Float32frombits(uint32(arg))), 32), nil
+	case 27:
+		return appendJSONFloat(buf, math.Float64frombits(arg), 64), nil
+	}
+	d.pos = start
+	if info == 31 {
+		return nil, d.errorf("unexpected break")
+	}
+	return nil, d.errorf("unsupported simple value %d", arg)
+}
+
+// str consumes the contents of a byte or text string, whose head has been
+// consumed.
+func (d *cborDecoder) str(major, info byte, arg uint64) ([]byte, error) {
+	if info != 31 {
+		return d.next(arg)
+	}
+	// An indefinite-length string is a series of definite-length chunks.
+	var s []byte
+	for {
+		if brk, err := d.isBreak(); err != nil || brk {
+			return s, err
+		}
+		start := d.pos
+		m, i, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || i == 31 {
+			d.pos = start
+			return nil, d.errorf("invalid chunk in indefinite-length string")
+		}
+		chunk, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, chunk...)
+	}
+}
+
+// more determines whether another element of an array or map follows, given
+// the number of elements so far and the head of the container.
+func (d *cborDecoder) more(i uint64, info byte, arg uint64) (bool, error) {
+	if info == 31 {
+		brk, err := d.isBreak()
+		return !brk, err
+	}
+	return i < arg, nil
+}
+
+func (d *cborDecoder) appendArray(buf []byte, info byte, arg uint64) ([]byte, error) {
+	buf = append(buf, '[')
+	for i := uint64(0); ; i++ {
+		more, err := d.more(i, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return append(buf, ']'), nil
+		}
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if buf, err = d.appendItem(buf); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *cborDecoder) appendMap(buf []byte, info byte, arg uint64) ([]byte, error) {
+	buf = append(buf, '{')
+	for i := uint64(0); ; i++ {
+		more, err := d.more(i, info, arg)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return append(buf, '}'), nil
+		}
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		// JSON only does string keys, so other keys become the text of
+		// their JSON form, as funcr does for map keys.
+		if d.pos < len(d.data) && d.data[d.pos]&cborMajorMask == cborText {
+			buf, err = d.appendItem(buf)
+		} else if d.inKey {
+			// Each level would double the escapes, so the size of the
+			// JSON would be exponential in the depth.
+			err = d.errorf("map key which is not text inside another")
+		} else {
+			var key []byte
+			d.inKey = true
+			key, err = d.appendItem(nil)
+			d.inKey = false
+			if err == nil {
+				buf = appendJSONString(buf, string(key))
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, ':')
+		if buf, err = d.appendItem(buf); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// appendJSONFloat renders a float as JSON, as a string if it is not finite,
+// like Options.StrictJSON.
+func appendJSONFloat(buf []byte, v float64, bitSize int) []byte {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		buf = append(buf, '"')
+		buf = strconv.AppendFloat(buf, v, 'f', -1, bitSize)
+		return append(buf, '"')
+	}
+	return strconv.AppendFloat(buf, v, 'f', -1, bitSize)
+}
+
+// float16ToFloat32 converts an IEEE 754 half-precision float.
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0: // zero or subnormal
+		v := float32(math.Ldexp(float64(frac), -24))
+		if sign != 0 {
+			v = -v
+		}
+		return v
+	case 0x1f: // infinity or NaN
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
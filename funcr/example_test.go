@@ -17,7 +17,11 @@ limitations under the License.
 package funcr_test
 
 import (
+	"encoding/base64"
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr/funcr"
 )
@@ -67,8 +71,8 @@ func ExampleOptions() {
 	log.V(1).Info("V(1) message", "key", "value")
 	log.V(2).Info("V(2) message", "key", "value")
 	// Output:
-	// {"logger":"","caller":{"file":"example_test.go","line":66},"level":0,"msg":"V(0) message","key":"value"}
-	// {"logger":"","caller":{"file":"example_test.go","line":67},"level":1,"msg":"V(1) message","key":"value"}
+	// {"logger":"","caller":{"file":"example_test.go","line":70},"level":0,"msg":"V(0) message","key":"value"}
+	// {"logger":"","caller":{"file":"example_test.go","line":71},"level":1,"msg":"V(1) message","key":"value"}
 }
 
 func ExampleOptions_renderHooks() {
@@ -124,3 +128,61 @@ func ExampleOptions_maxLogDepth() {
 	log.Info("recursive", "list", l)
 	// Output: {"logger":"","level":0,"msg":"recursive","list":{"Next":{"Next":{"Next":{"Next":{"Next":"<max-log-depth-exceeded>"}}}}}}
 }
+
+func ExampleOptions_typeEncoders() {
+	log := funcr.NewJSON(func(obj string) {
+		fmt.Println(obj)
+	}, funcr.Options{
+		TypeEncoders: []funcr.TypeEncoder{{
+			Type: reflect.TypeOf(time.Time{}),
+			Encode: func(v any) any {
+				return v.(time.Time).Format(time.RFC3339) //nolint:forcetypeassert
+			},
+		}, {
+			Type: reflect.TypeOf(time.Duration(0)),
+			Encode: func(v any) any {
+				return v.(time.Duration).String() //nolint:forcetypeassert
+			},
+		}, {
+			Type: reflect.TypeOf([]byte(nil)),
+			Encode: func(v any) any {
+				return base64.StdEncoding.EncodeToString(v.([]byte)) //nolint:forcetypeassert
+			},
+		}},
+	})
+	start := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	log.Info("done", "start", start, "took", 1500*time.Millisecond, "data", []byte("hi"))
+	// Output: {"logger":"","level":0,"msg":"done","start":"2006-01-02T15:04:05Z","took":"1.5s","data":"aGk="}
+}
+
+func ExampleNewCBOR() {
+	log := funcr.NewCBOR(func(obj []byte) {
+		js, _, err := funcr.CBORToJSON(obj)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%d bytes: %s\n", len(obj), js)
+	}, funcr.Options{})
+
+	log = log.WithName("MyLogger")
+	log = log.WithValues("savedKey", "savedValue")
+	log.Info("the message", "key", "value", "list", []int{1, 2, 3})
+	// Output: 81 bytes: {"logger":"MyLogger","level":0,"msg":"the message","savedKey":"savedValue","key":"value","list":[1,2,3]}
+}
+
+func ExampleNewParser() {
+	input := `MyLogger "level"=1 "msg"="the message" "key"={"nested"=[1 2]}
+{"logger":"MyLogger","msg":"failed","error":"boom"}
+`
+	p := funcr.NewParser(strings.NewReader(input), funcr.Options{})
+	for p.Next() {
+		rec := p.Record()
+		fmt.Println(rec.Logger, rec.IsError(), rec.Builtins[funcr.BuiltinMessage], rec.Values)
+	}
+	if err := p.Err(); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// MyLogger false the message [key [nested [1 2]]]
+	// MyLogger true failed []
+}
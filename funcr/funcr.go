@@ -19,19 +19,28 @@ limitations under the License.
 //
 // The simplest way to use it is via its implementation of a
 // github.com/go-logr/logr.LogSink with output through an arbitrary
-// "write" function.  See New and NewJSON for details.
+// "write" function.  See New, NewJSON, and NewCBOR for details.
 //
 // # Custom LogSinks
 //
 // For users who need more control, a funcr.Formatter can be embedded inside
 // your own custom LogSink implementation. This is useful when the LogSink
-// needs to implement additional methods, for example.
+// needs to implement additional methods, for example.  Such a LogSink can use
+// Formatter.FormatInfoTo and Formatter.FormatErrorTo to render log lines into
+// its own buffers and avoid allocations.
+//
+// # Parsing
+//
+// A Parser reads key-value and JSON log lines back into Records, for tools
+// and tests which need to process what funcr logged.
 //
 // # Formatting
 //
 // This will respect logr.Marshaler, fmt.Stringer, and error interfaces for
-// values which are being logged.  When rendering a struct, funcr will use Go's
-// standard JSON tags (all except "string").
+// values which are being logged, unless Options.TypeEncoders says otherwise.
+// Options.UseEncodingMarshalers adds json.Marshaler and
+// encoding.TextMarshaler to that list.  When rendering a struct, funcr will
+// use Go's standard JSON tags (all except "string").
 package funcr
 
 import (
@@ -39,12 +48,19 @@ import (
 	"encoding"
 	"encoding/json"
 	"fmt"
+	"math"
+	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-logr/logr"
 )
@@ -63,6 +79,16 @@ func NewJSON(fn func(obj string), opts Options) logr.Logger {
 	return logr.New(newSink(fnWrapper, NewFormatterJSON(opts)))
 }
 
+// NewCBOR returns a logr.Logger which is implemented by an arbitrary function
+// and produces CBOR output.  Each call to fn receives one complete log line,
+// which fn may retain.
+func NewCBOR(fn func(obj []byte), opts Options) logr.Logger {
+	fnWrapper := func(_, obj string) {
+		fn([]byte(obj))
+	}
+	return logr.New(newSink(fnWrapper, NewFormatterCBOR(opts)))
+}
+
 // Underlier exposes access to the underlying logging function. Since
 // callers only have a logr.Logger, they have to know which
 // implementation is in use, so this interface is less of an
@@ -91,6 +117,21 @@ type Options struct {
 	// has no effect if caller logging is not enabled (see Options.LogCaller).
 	LogCallerFunc bool
 
+	// LogCallerShortFunc tells funcr to log the calling function name
+	// without the leading directories of its package's import path, e.g.
+	// "funcr.TestFoo" rather than "github.com/go-logr/logr/funcr.TestFoo".
+	// This has no effect if LogCallerFunc is not enabled.
+	LogCallerShortFunc bool
+
+	// CallerPath tells funcr how to log the calling file.  If not specified,
+	// only the file's base name is logged.
+	CallerPath CallerPath
+
+	// CallerTrimPrefix is removed from the start of the calling file's path
+	// when CallerPath is CallerPathFull, for example to remove the
+	// directory in which a binary was built.
+	CallerTrimPrefix string
+
 	// LogTimestamp tells funcr to add a "ts" key to log lines.  This has some
 	// overhead, so some users might not want it.
 	LogTimestamp bool
@@ -100,11 +141,56 @@ type Options struct {
 	// details, see docs for Go's time.Layout.
 	TimestampFormat string
 
+	// TimestampStyle tells funcr how to represent timestamps when
+	// LogTimestamp is enabled.  If not specified, timestamps are strings
+	// formatted according to TimestampFormat.
+	TimestampStyle TimestampStyle
+
+	// TimestampUTC tells funcr to convert timestamps to UTC before
+	// formatting them.  If not specified, timestamps are in the local time
+	// zone.
+	TimestampUTC bool
+
+	// LogHostname tells funcr to add a "host" key to log lines, with the
+	// name of the host as reported by os.Hostname when first needed.
+	LogHostname bool
+
+	// LogPID tells funcr to add a "pid" key to log lines, with the ID of
+	// this process.
+	LogPID bool
+
+	// LogGoroutineID tells funcr to add a "goroutine" key to log lines, with
+	// the ID of the calling goroutine.  This has some overhead, so it is
+	// mostly useful for debugging.
+	LogGoroutineID bool
+
+	// LogSequence tells funcr to add a "seq" key to log lines, with a number
+	// which increases by one for each line logged with this option by any
+	// Formatter in this process.  This can be used to restore the order of
+	// lines which have the same timestamp, or to detect dropped lines.
+	LogSequence bool
+
+	// Clock tells funcr how to get the current time, for timestamps.  If not
+	// specified, time.Now is used.  This is mostly useful for tests.
+	Clock func() time.Time
+
 	// LogInfoLevel tells funcr what key to use to log the info level.
 	// If not specified, the info level will be logged as "level".
 	// If this is set to "", the info level will not be logged at all.
 	LogInfoLevel *string
 
+	// BuiltinKeys tells funcr what keys to use for builtins, overriding the
+	// defaults and LogInfoLevel.  If the key for a builtin is "", that
+	// builtin will not be logged at all.
+	BuiltinKeys map[Builtin]string
+
+	// BuiltinOrder tells funcr in what order to log builtins.  Builtins
+	// which are not listed are logged after those which are, in the default
+	// order: logger, ts, caller, level, msg, error, host, pid, goroutine,
+	// seq.  Listing a builtin does not enable it if it would not otherwise
+	// be logged.
+	BuiltinOrder []Builtin
+
 	// Verbosity tells funcr which V logs to produce.  Higher values enable
 	// more logs.  Info logs at or below this level will be written, while logs
 	// above this level will be discarded.
@@ -140,6 +226,113 @@ type Options struct {
 	// depth has been exceeded.  If this field is not specified, a default
 	// value will be used.
 	MaxLogDepth int
+
+	// SortKeys tells funcr to render the keys of maps, and of key-value pairs
+	// saved via logr.Logger.WithValues or Formatter.AddValues, in sorted
+	// order.  By default map keys are rendered in Go's (randomized) iteration
+	// order, which is faster.  Map keys of the predeclared integer and
+	// floating-point types are compared numerically, and other keys by their
+	// rendered string form, so that non-string keys sort the same way they
+	// are displayed.  Saved values with duplicate keys keep their relative
+	// order.
+	SortKeys bool
+
+	// TypeEncoders allows users to control how values of particular types
+	// are rendered, for example to log time.Time values in RFC 3339 format.
+	// They are consulted, in order, for every value logged, at any depth,
+	// before considering logr.Marshaler, fmt.Stringer, or error.  The first
+	// TypeEncoder which applies to a value's type is used.  Pointers to types
+	// with a TypeEncoder are dereferenced and then encoded.
+	TypeEncoders []TypeEncoder
+
+	// UseEncodingMarshalers tells funcr to render values which implement
+	// json.Marshaler or encoding.TextMarshaler by calling those methods,
+	// after checking for logr.Marshaler but before fmt.Stringer or error.
+	// The output of MarshalJSON is validated, compacted, and embedded
+	// as-is in JSON mode, or as a string in key-value mode.  The output of
+	// MarshalText is rendered as a string.
+	UseEncodingMarshalers bool
+
+	// StrictJSON tells funcr to guarantee that, in JSON mode, every line is
+	// valid JSON as defined by RFC 8259, even if that means logging values
+	// differently than they would otherwise be.  Strings are escaped per
+	// JSON rather than Go rules, with invalid UTF-8 replaced by U+FFFD;
+	// non-finite floats (NaN, +Inf, and -Inf) are logged as strings; all
+	// keys are escaped; and json.RawMessage values are validated and
+	// compacted.  This has no effect in key-value mode.
+	StrictJSON bool
+
+	// SafeText tells funcr to guarantee that, in key-value mode, output
+	// contains no line breaks, terminal control sequences, or invalid UTF-8,
+	// so a logged message or value can't forge extra log lines or change
+	// how a terminal displays them.  Names from AddName, which are otherwise
+	// returned verbatim as the prefix, have control characters escaped Go
+	// style (e.g. "\n" or "\x1b"); strings always escape invalid UTF-8;
+	// and string output from MarshalJSON is re-escaped if needed.  Messages
+	// and string values are already escaped Go style, so are otherwise
+	// unchanged.  This has no effect in JSON mode.
+	SafeText bool
+
+	// MaxStringLength tells funcr to truncate string values, including the
+	// message and error, which are longer than this many bytes.  Truncated
+	// strings end with a marker like "…(+1234 bytes)".  Keys are never
+	// truncated.  If this is zero or negative, strings are not truncated.
+	MaxStringLength int
+
+	// MaxCollectionItems tells funcr to render at most this many elements of
+	// slices, arrays, and maps.  Truncated slices and arrays end with an
+	// element like "<+57 more items>", and truncated maps end with a
+	// "<truncated>" key whose value is like that.  If SortKeys is not set,
+	// which map entries are rendered is arbitrary.  If this is zero or
+	// negative, collections are not truncated.
+	MaxCollectionItems int
+
+	// MaxLineBytes tells funcr to limit each rendered line to approximately
+	// this many bytes, not counting the logger name in key-value mode.  Lines
+	// which are too long have trailing key-value pairs from the call site
+	// dropped, and then saved values if needed, and end with a
+	// "<truncated>" key whose value is like "<+1234 bytes>".  Builtins are
+	// always rendered, so this is a best effort (see also MaxStringLength).
+	// The render hooks may be called more than once for truncated lines.
+	// If this is zero or negative, lines are not truncated.
+	MaxLineBytes int
+
+	// DuplicateKeys tells funcr what to do when a log line would have more
+	// than one value for the same key at the same level, for example when
+	// a call site passes a key which was also passed to WithValues, or one
+	// which is used by a builtin like "msg".  The default is to log all of
+	// them, which is the fastest option.
+	DuplicateKeys DuplicateKeyPolicy
+
+	// DuplicateKeyPrefix is added to keys which are renamed when
+	// DuplicateKeys is RenameDuplicateKeys.  The default is "fields.".
+	DuplicateKeyPrefix string
+}
+
+// TypeEncoder tells funcr how to render values of some type.  See
+// Options.TypeEncoders.
+type TypeEncoder struct {
+	// Type is the type that this encoder applies to.  It is ignored if Match
+	// is set.
+	Type reflect.Type
+
+	// Match is called to determine whether this encoder applies to a type.
+	// Results are cached, so it should depend only on its argument.
+	Match func(t reflect.Type) bool
+
+	// Encode returns what should be logged in place of v, similar to
+	// logr.Marshaler.  The result is then rendered normally, but if it is of
+	// a type to which this encoder applies, it must not contain itself
+	// through a pointer.
+	Encode func(v any) any
+}
+
+// applies determines whether this encoder applies to a type.
+func (te TypeEncoder) applies(t reflect.Type) bool {
+	if te.Match != nil {
+		return te.Match(t)
+	}
+	return te.Type == t
 }
 
 // MessageClass indicates which category or categories of messages to consider.
@@ -156,6 +349,108 @@ const (
 	Error
 )
 
+// DuplicateKeyPolicy indicates what to do with duplicate keys.  Keys are
+// compared within a single level of a log line: the top level, which
+// includes builtins, or a single group (see logr.Logger.WithGroup).  Keys of
+// struct fields and maps are not considered.
+type DuplicateKeyPolicy int
+
+const (
+	// AllowDuplicateKeys logs every key and value, even if a key is repeated.
+	AllowDuplicateKeys DuplicateKeyPolicy = iota
+	// LastKeyWins logs only the last value for each key.  This includes
+	// builtins, so a call site which passes "msg" replaces the message.
+	LastKeyWins
+	// FirstKeyWins logs only the first value for each key.  Builtins are
+	// always first.
+	FirstKeyWins
+	// RenameDuplicateKeys logs every value, but renames repeated keys by
+	// adding Options.DuplicateKeyPrefix, as many times as needed to make
+	// them unique.  Builtin keys are never renamed.
+	RenameDuplicateKeys
+)
+
+// TimestampStyle indicates how to represent timestamps.
+type TimestampStyle int
+
+const (
+	// TimestampLayout represents timestamps as strings formatted according
+	// to Options.TimestampFormat.
+	TimestampLayout TimestampStyle = iota
+	// TimestampUnix represents timestamps as the number of seconds since the
+	// Unix epoch, with a fractional part.
+	TimestampUnix
+	// TimestampUnixMilli represents timestamps as the integer number of
+	// milliseconds since the Unix epoch.
+	TimestampUnixMilli
+	// TimestampUnixNano represents timestamps as the integer number of
+	// nanoseconds since the Unix epoch.
+	TimestampUnixNano
+	// TimestampElapsed represents timestamps as the number of seconds since
+	// the Formatter was created, with a fractional part.  Formatters derived
+	// from it, e.g. by logr.Logger.WithValues, share its start time.
+	TimestampElapsed
+)
+
+// CallerPath indicates how to log the path of the calling file.
+type CallerPath int
+
+const (
+	// CallerPathBase logs the file's base name, e.g. "file.go".
+	CallerPathBase CallerPath = iota
+	// CallerPathFull logs the file's full path, as recorded when the binary
+	// was built, minus Options.CallerTrimPrefix.
+	CallerPathFull
+	// CallerPathPackage logs the file's base name and the name of the
+	// directory which contains it, e.g. "pkg/file.go".
+	CallerPathPackage
+	// CallerPathModule logs the file's path relative to the root of the
+	// module which contains it, e.g. "internal/pkg/file.go", as determined
+	// from the binary's build info.  Files from packages which are not in a
+	// known module, such as the standard library, are logged as their
+	// package's import path plus the base name, e.g. "net/http/server.go".
+	CallerPathModule
+)
+
+// Builtin identifies one of the key-value pairs which funcr adds to log lines
+// itself, as opposed to those provided by the user.
+type Builtin int
+
+const (
+	// BuiltinLogger is the logger's name, which is only logged as a
+	// key-value pair in JSON mode.  The default key is "logger".
+	BuiltinLogger Builtin = iota
+	// BuiltinTimestamp is the time of the log call, if Options.LogTimestamp
+	// is set.  The default key is "ts".
+	BuiltinTimestamp
+	// BuiltinCaller is the call site (see Caller), if Options.LogCaller is
+	// set.  The default key is "caller".
+	BuiltinCaller
+	// BuiltinLevel is the V-level of info logs.  The default key is
+	// "level", or Options.LogInfoLevel if set.
+	BuiltinLevel
+	// BuiltinMessage is the message.  The default key is "msg".
+	BuiltinMessage
+	// BuiltinError is the error of error logs.  The default key is "error".
+	BuiltinError
+	// BuiltinHostname is the name of the host, if Options.LogHostname is
+	// set.  The default key is "host".
+	BuiltinHostname
+	// BuiltinPID is the ID of this process, if Options.LogPID is set.  The
+	// default key is "pid".
+	BuiltinPID
+	// BuiltinGoroutineID is the ID of the calling goroutine, if
+	// Options.LogGoroutineID is set.  The default key is "goroutine".
+	BuiltinGoroutineID
+	// BuiltinSequence is the sequence number of the log line, if
+	// Options.LogSequence is set.  The default key is "seq".
+	BuiltinSequence
+)
+
+func (b Builtin) valid() bool {
+	return b >= 0 && int(b) < maxBuiltins
+}
+
 // fnlogger inherits some of its LogSink implementation from Formatter
 // and just needs to add some glue code.
 type fnlogger struct {
@@ -207,9 +502,18 @@ func NewFormatterJSON(opts Options) Formatter {
 	return newFormatter(opts, outputJSON)
 }
 
+// NewFormatterCBOR constructs a Formatter which emits CBOR (RFC 8949).  Each
+// log line is a single CBOR map with the same structure as the JSON object
+// NewFormatterJSON would produce, and can be converted to that JSON with
+// CBORToJSON.
+func NewFormatterCBOR(opts Options) Formatter {
+	return newFormatter(opts, outputCBOR)
+}
+
 // Defaults for Options.
 const defaultTimestampFormat = "2006-01-02 15:04:05.000000"
 const defaultMaxLogDepth = 16
+const defaultDuplicateKeyPrefix = "fields."
 
 func newFormatter(opts Options, outfmt outputFormat) Formatter {
 	if opts.TimestampFormat == "" {
@@ -222,6 +526,12 @@ func newFormatter(opts Options, outfmt outputFormat) Formatter {
 		opts.LogInfoLevel = new(string)
 		*opts.LogInfoLevel = "level"
 	}
+	if opts.DuplicateKeyPrefix == "" {
+		opts.DuplicateKeyPrefix = defaultDuplicateKeyPrefix
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
 	f := Formatter{
 		outputFormat: outfmt,
 		prefix:       "",
@@ -229,6 +539,10 @@ func newFormatter(opts Options, outfmt outputFormat) Formatter {
 		depth:        0,
 		opts:         &opts,
 	}
+	if len(opts.TypeEncoders) > 0 {
+		f.encoders = &typeEncoders{list: opts.TypeEncoders}
+	}
+	f.builtinCfg = newBuiltinConfig(&opts)
 	return f
 }
 
@@ -240,10 +554,40 @@ type Formatter struct {
 	prefix       string
 	values       []any
 	valuesStr    string
+	valuesKVs    []renderedKV // only if Options.DuplicateKeys is set
 	depth        int
 	opts         *Options
 	groupName    string // for slog groups
 	groups       []groupDef
+	encoders     *typeEncoders // nil if there are no Options.TypeEncoders
+	builtinCfg   *builtinConfig
+}
+
+// typeEncoders finds the TypeEncoder for a type, if any, and remembers the
+// result.
+type typeEncoders struct {
+	list  []TypeEncoder
+	cache sync.Map // reflect.Type -> *TypeEncoder, or nil if none applies
+}
+
+func (te *typeEncoders) lookup(t reflect.Type) *TypeEncoder {
+	if enc, ok := te.cache.Load(t); ok {
+		return enc.(*TypeEncoder) //nolint:forcetypeassert // only *TypeEncoder is stored
+	}
+	var found *TypeEncoder
+	for i := range te.list {
+		if te.list[i].applies(t) {
+			found = &te.list[i]
+			break
+		}
+	}
+	te.cache.Store(t, found)
+	return found
+}
+
+// hasEncoder determines whether a TypeEncoder applies to a type.
+func (f Formatter) hasEncoder(t reflect.Type) bool {
+	return f.encoders != nil && f.encoders.lookup(t) != nil
 }
 
 // outputFormat indicates which outputFormat to use.
@@ -254,6 +598,8 @@ const (
 	outputKeyValue outputFormat = iota
 	// outputJSON emits strict JSON.
 	outputJSON
+	// outputCBOR emits CBOR, with the same structure as outputJSON.
+	outputCBOR
 )
 
 // groupDef represents a saved group.  The values may be empty, but we don't
@@ -261,178 +607,742 @@ const (
 type groupDef struct {
 	name   string
 	values string
+	kvs    []renderedKV // only if Options.DuplicateKeys is set
+}
+
+// renderedKV is a key-value pair with the value already rendered.
+type renderedKV struct {
+	key   string
+	value string
 }
 
 // PseudoStruct is a list of key-value pairs that gets logged as a struct.
 type PseudoStruct []any
 
-// render produces a log line, ready to use.
-func (f Formatter) render(builtins, args []any) string {
-	// Empirically bytes.Buffer is faster than strings.Builder for this.
-	buf := bytes.NewBuffer(make([]byte, 0, 1024))
+// Buffers are pooled to avoid allocating one for every log line.  Buffers
+// which grew unusually large are dropped rather than pinned in the pool.
+const maxPooledBufferSize = 64 * 1024
 
-	if f.outputFormat == outputJSON {
-		buf.WriteByte('{') // for the whole record
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
+
+func getBuffer() *[]byte {
+	buf, _ := bufferPool.Get().(*[]byte) // New always returns *[]byte
+	*buf = (*buf)[:0]
+	return buf
+}
+
+func putBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledBufferSize {
+		return
 	}
+	bufferPool.Put(buf)
+}
 
-	// Render builtins
-	vals := builtins
-	if hook := f.opts.RenderBuiltinsHook; hook != nil {
-		vals = hook(f.sanitize(vals))
+// maxBuiltins is the number of builtin values.
+const maxBuiltins = int(BuiltinSequence) + 1
+
+// defaultBuiltinKeys are the keys used for builtins unless Options says
+// otherwise.
+var defaultBuiltinKeys = [maxBuiltins]string{
+	BuiltinLogger:      "logger",
+	BuiltinTimestamp:   "ts",
+	BuiltinCaller:      "caller",
+	BuiltinLevel:       "level",
+	BuiltinMessage:     "msg",
+	BuiltinError:       "error",
+	BuiltinHostname:    "host",
+	BuiltinPID:         "pid",
+	BuiltinGoroutineID: "goroutine",
+	BuiltinSequence:    "seq",
+}
+
+// builtinConfig describes which builtins to log, and how, after considering
+// Options.  It is computed once per call to NewFormatter.
+type builtinConfig struct {
+	keys  [maxBuiltins]string // "" if disabled
+	order []Builtin
+	start time.Time // for TimestampElapsed
+}
+
+func newBuiltinConfig(opts *Options) *builtinConfig {
+	cfg := &builtinConfig{keys: defaultBuiltinKeys}
+	if opts.TimestampStyle == TimestampElapsed {
+		cfg.start = opts.Clock()
+	}
+	cfg.keys[BuiltinLevel] = *opts.LogInfoLevel
+	for b, key := range opts.BuiltinKeys {
+		if b.valid() {
+			cfg.keys[b] = key
+		}
+	}
+	var seen [maxBuiltins]bool
+	for _, b := range opts.BuiltinOrder {
+		if b.valid() && !seen[b] {
+			seen[b] = true
+			cfg.order = append(cfg.order, b)
+		}
+	}
+	for b := Builtin(0); b.valid(); b++ {
+		if !seen[b] {
+			cfg.order = append(cfg.order, b)
+		}
 	}
-	f.flatten(buf, vals, false) // keys are ours, no need to escape
-	continuing := len(builtins) > 0
+	return cfg
+}
+
+// record holds the values from which the builtins of a log line are rendered.
+type record struct {
+	logger    string
+	ts        time.Time
+	caller    Caller
+	level     int
+	msg       string
+	err       error
+	goroutine uint64
+	seq       uint64
+}
 
-	// Turn the inner-most group into a string
-	argsStr := func() string {
-		buf := bytes.NewBuffer(make([]byte, 0, 1024))
+// builtinKey returns the key under which a builtin is logged.
+func (f Formatter) builtinKey(b Builtin) string {
+	return f.builtinCfg.keys[b]
+}
 
-		vals = args
-		if hook := f.opts.RenderArgsHook; hook != nil {
-			vals = hook(f.sanitize(vals))
+// builtinValue returns the value of a builtin, for use with render hooks.
+func (f Formatter) builtinValue(b Builtin, rec *record) any {
+	switch b {
+	case BuiltinLogger:
+		return rec.logger
+	case BuiltinTimestamp:
+		switch f.opts.TimestampStyle {
+		case TimestampUnix:
+			return unixSeconds(rec.ts)
+		case TimestampUnixMilli:
+			return rec.ts.UnixMilli()
+		case TimestampUnixNano:
+			return rec.ts.UnixNano()
+		case TimestampElapsed:
+			return rec.ts.Sub(f.builtinCfg.start).Seconds()
+		}
+		return rec.ts.Format(f.opts.TimestampFormat)
+	case BuiltinCaller:
+		return rec.caller
+	case BuiltinLevel:
+		return rec.level
+	case BuiltinMessage:
+		return rec.msg
+	case BuiltinError:
+		if rec.err != nil {
+			return rec.err.Error()
 		}
-		f.flatten(buf, vals, true) // escape user-provided keys
+	case BuiltinHostname:
+		return hostname()
+	case BuiltinPID:
+		return pid
+	case BuiltinGoroutineID:
+		return rec.goroutine
+	case BuiltinSequence:
+		return rec.seq
+	}
+	return nil
+}
 
-		return buf.String()
-	}()
+// appendBuiltinValue renders the value of a builtin into buf.  This is
+// equivalent to rendering the result of builtinValue, but avoids the
+// allocations of converting it to an interface.
+func (f Formatter) appendBuiltinValue(buf []byte, b Builtin, rec *record) []byte {
+	switch b {
+	case BuiltinLogger:
+		return f.appendString(buf, rec.logger)
+	case BuiltinTimestamp:
+		switch f.opts.TimestampStyle {
+		case TimestampUnix:
+			return f.appendFloat(buf, unixSeconds(rec.ts), 64)
+		case TimestampUnixMilli:
+			return f.appendInt(buf, rec.ts.UnixMilli())
+		case TimestampUnixNano:
+			return f.appendInt(buf, rec.ts.UnixNano())
+		case TimestampElapsed:
+			return f.appendFloat(buf, rec.ts.Sub(f.builtinCfg.start).Seconds(), 64)
+		}
+		if f.outputFormat == outputCBOR {
+			var tmp [64]byte
+			return appendCBORText(buf, rec.ts.AppendFormat(tmp[:0], f.opts.TimestampFormat))
+		}
+		start := len(buf)
+		buf = append(buf, '"')
+		buf = rec.ts.AppendFormat(buf, f.opts.TimestampFormat)
+		if ts := buf[start+1:]; !needsEscape(string(ts)) && (utf8.Valid(ts) || !f.strictJSON()) {
+			return append(buf, '"')
+		}
+		return f.appendString(buf[:start], string(buf[start+1:]))
+	case BuiltinCaller:
+		return f.appendCaller(buf, rec.caller)
+	case BuiltinLevel:
+		return f.appendInt(buf, int64(rec.level))
+	case BuiltinMessage:
+		return f.appendStringValue(buf, rec.msg, 0)
+	case BuiltinError:
+		if rec.err != nil {
+			return f.appendStringValue(buf, rec.err.Error(), 0)
+		}
+	case BuiltinHostname:
+		return f.appendString(buf, hostname())
+	case BuiltinPID:
+		return f.appendInt(buf, int64(pid))
+	case BuiltinGoroutineID:
+		return f.appendUint(buf, rec.goroutine)
+	case BuiltinSequence:
+		return f.appendUint(buf, rec.seq)
+	}
+	return f.appendNull(buf)
+}
 
-	// Render the stack of groups from the inside out.
-	bodyStr := f.renderGroup(f.groupName, f.valuesStr, argsStr)
-	for i := len(f.groups) - 1; i >= 0; i-- {
-		grp := &f.groups[i]
-		if grp.values == "" && bodyStr == "" {
-			// no contents, so we must elide the whole group
+// pid is the ID of this process.
+var pid = os.Getpid()
+
+var (
+	hostnameOnce sync.Once
+	hostnameStr  string
+)
+
+// hostname returns the name of this host, or "<unknown>" if it can't be
+// determined.
+func hostname() string {
+	hostnameOnce.Do(func() {
+		name, err := os.Hostname()
+		if err != nil || name == "" {
+			name = "<unknown>"
+		}
+		hostnameStr = name
+	})
+	return hostnameStr
+}
+
+// goroutineID returns the ID of the calling goroutine, or 0 if it can't be
+// determined.  Go deliberately does not expose this, so it is parsed from the
+// first line of a stack trace, e.g. "goroutine 42 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = b[len("goroutine "):]
+	var id uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + uint64(c-'0')
+	}
+	return id
+}
+
+// sequence is the number of log lines logged with Options.LogSequence.
+var sequence uint64
+
+// unixSeconds converts a time to fractional seconds since the Unix epoch.
+func unixSeconds(t time.Time) float64 {
+	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
+}
+
+// appendCaller renders a Caller exactly as the generic struct rendering
+// would, without the reflection.
+func (f Formatter) appendCaller(buf []byte, c Caller) []byte {
+	buf = f.appendDelim(buf, '{')
+	buf = f.appendKey(buf, "file", false)
+	buf = f.appendColon(buf)
+	buf = f.appendString(buf, c.File)
+	buf = f.appendComma(buf)
+	buf = f.appendKey(buf, "line", false)
+	buf = f.appendColon(buf)
+	buf = f.appendInt(buf, int64(c.Line))
+	if c.Func != "" {
+		buf = f.appendComma(buf)
+		buf = f.appendKey(buf, "function", false)
+		buf = f.appendColon(buf)
+		buf = f.appendString(buf, c.Func)
+	}
+	return f.appendDelim(buf, '}')
+}
+
+// builtins fills in the list of builtins to log for a record, in order.
+func (f Formatter) builtins(list []Builtin, isError bool) []Builtin {
+	for _, b := range f.builtinCfg.order {
+		if f.builtinCfg.keys[b] == "" {
 			continue
 		}
-		bodyStr = f.renderGroup(grp.name, grp.values, bodyStr)
+		var enabled bool
+		switch b {
+		case BuiltinLogger:
+			// In key-value mode, the name is passed separately.
+			enabled = f.outputFormat != outputKeyValue
+		case BuiltinTimestamp:
+			enabled = f.opts.LogTimestamp
+		case BuiltinCaller:
+			policy := f.opts.LogCaller
+			enabled = policy == All || (isError && policy == Error) || (!isError && policy == Info)
+		case BuiltinLevel:
+			enabled = !isError
+		case BuiltinMessage:
+			enabled = true
+		case BuiltinError:
+			enabled = isError
+		case BuiltinHostname:
+			enabled = f.opts.LogHostname
+		case BuiltinPID:
+			enabled = f.opts.LogPID
+		case BuiltinGoroutineID:
+			enabled = f.opts.LogGoroutineID
+		case BuiltinSequence:
+			enabled = f.opts.LogSequence
+		}
+		if enabled {
+			list = append(list, b)
+		}
+	}
+	return list
+}
+
+// appendRecord renders a complete log line into buf.
+func (f Formatter) appendRecord(buf []byte, list []Builtin, rec *record, args []any) []byte {
+	start := len(buf)
+	buf = f.appendFullRecord(buf, list, rec, args)
+	if max := f.opts.MaxLineBytes; max > 0 && len(buf)-start > max {
+		buf = f.appendTruncatedRecord(buf[:start], list, rec, args, len(buf)-start)
 	}
+	return buf
+}
 
-	if bodyStr != "" {
-		if continuing {
-			buf.WriteByte(f.comma())
+// truncatedKey is the key used to mark truncated maps and lines.
+const truncatedKey = "<truncated>"
+
+// appendTruncatedRecord renders a log line like appendRecord, but drops as
+// few call-site and saved key-value pairs as possible to keep it under
+// Options.MaxLineBytes.  The full line was fullLen bytes long.
+func (f Formatter) appendTruncatedRecord(buf []byte, list []Builtin, rec *record, args []any, fullLen int) []byte {
+	start := len(buf)
+	// Leave room for the marker, which reports fewer than fullLen bytes.
+	limit := f.opts.MaxLineBytes - len(`,"`+truncatedKey+`":"<+ bytes>"`) - len(strconv.Itoa(fullLen))
+	pairs := (len(args) + 1) / 2
+	argsN := func(n int) []any {
+		if 2*n > len(args) {
+			return args
 		}
-		buf.WriteString(bodyStr)
+		return args[:2*n]
+	}
+	fits := func(g Formatter, n int) bool {
+		buf = g.appendFullRecord(buf[:start], list, rec, argsN(n))
+		return len(buf)-start <= limit
 	}
 
-	if f.outputFormat == outputJSON {
-		buf.WriteByte('}') // for the whole record
+	// Drop saved values only if dropping all of the args is not enough.
+	g := f
+	if !fits(g, 0) {
+		g.valuesStr = ""
+		g.valuesKVs = nil
+		g.groups = make([]groupDef, len(f.groups))
+		for i := range f.groups {
+			g.groups[i].name = f.groups[i].name
+		}
 	}
 
-	return buf.String()
+	// Find the most args that fit.
+	lo, hi := 0, pairs-1 // args[:2*lo] fits, unless nothing does
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		if fits(g, mid) {
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+	n := hi
+	if n < 0 {
+		n = 0
+	}
+	buf = g.appendFullRecord(buf[:start], list, rec, argsN(n))
+	dropped := fullLen - (len(buf) - start)
+
+	kvList := make([]any, 0, 2*n+2)
+	kvList = append(kvList, argsN(n)...)
+	kvList = append(kvList, truncatedKey, "<+"+strconv.Itoa(dropped)+" bytes>")
+	return g.appendFullRecord(buf[:start], list, rec, kvList)
 }
 
-// renderGroup returns a string representation of the named group with rendered
-// values and args.  If the name is empty, this will return the values and args,
-// joined.  If the name is not empty, this will return a single key-value pair,
-// where the value is a grouping of the values and args.  If the values and
-// args are both empty, this will return an empty string, even if the name was
-// specified.
-func (f Formatter) renderGroup(name string, values string, args string) string {
-	buf := bytes.NewBuffer(make([]byte, 0, 1024))
+// appendFullRecord renders a complete log line, ignoring
+// Options.MaxLineBytes.
+func (f Formatter) appendFullRecord(buf []byte, list []Builtin, rec *record, args []any) []byte {
+	if f.opts.DuplicateKeys != AllowDuplicateKeys {
+		return f.appendDedupedRecord(buf, list, rec, args)
+	}
 
-	needClosingBrace := false
-	if name != "" && (values != "" || args != "") {
-		buf.WriteString(f.quoted(name, true)) // escape user-provided keys
-		buf.WriteByte(f.colon())
-		buf.WriteByte('{')
-		needClosingBrace = true
+	if f.outputFormat != outputKeyValue {
+		buf = f.appendDelim(buf, '{') // for the whole record
 	}
 
-	continuing := false
-	if values != "" {
-		buf.WriteString(values)
-		continuing = true
+	// Render builtins
+	if hook := f.opts.RenderBuiltinsHook; hook != nil {
+		vals := make([]any, 0, 2*len(list))
+		for _, b := range list {
+			vals = append(vals, f.builtinKey(b), f.builtinValue(b, rec))
+		}
+		buf = f.flatten(buf, hook(f.sanitize(vals)), false) // keys are ours, no need to escape
+	} else {
+		for i, b := range list {
+			if i > 0 {
+				buf = f.appendComma(buf)
+			}
+			buf = f.appendKey(buf, f.builtinKey(b), false) // keys are ours, no need to escape
+			buf = f.appendColon(buf)
+			buf = f.appendBuiltinValue(buf, b, rec)
+		}
+	}
+
+	buf = f.appendBody(buf, len(list) > 0, args)
+
+	if f.outputFormat != outputKeyValue {
+		buf = f.appendDelim(buf, '}') // for the whole record
+	}
+	return buf
+}
+
+// appendBody renders the saved values, the stack of groups, and the args into
+// buf.  If continuing is true, something has already been rendered and a
+// separator is needed before anything else is added.
+//
+// Each group is rendered as a single key-value pair, where the value is a
+// grouping of the group's values and everything nested inside it.  Groups
+// with no contents are elided, even if they are named.
+func (f Formatter) appendBody(buf []byte, continuing bool, args []any) []byte {
+	vals := args
+	if hook := f.opts.RenderArgsHook; hook != nil {
+		vals = hook(f.sanitize(vals))
+	}
+
+	// Find the inner-most level with anything to render.  Levels are the
+	// saved groups, from the outside in, followed by the current group.
+	n := len(f.groups)
+	deepest := -1
+	if f.valuesStr != "" || len(vals) > 0 {
+		deepest = n
+	} else {
+		for i := n - 1; i >= 0; i-- {
+			if f.groups[i].values != "" {
+				deepest = i
+				break
+			}
+		}
+	}
+	if deepest < 0 {
+		return buf
+	}
+
+	if continuing {
+		buf = f.appendComma(buf)
+	}
+	for i := 0; i <= deepest; i++ {
+		name, values := f.groupName, f.valuesStr
+		if i < n {
+			name, values = f.groups[i].name, f.groups[i].values
+		}
+		if name != "" {
+			buf = f.appendKey(buf, name, true) // escape user-provided keys
+			buf = f.appendColon(buf)
+			buf = f.appendDelim(buf, '{')
+		}
+		buf = append(buf, values...)
+		if i < deepest {
+			if values != "" {
+				buf = f.appendComma(buf)
+			}
+		} else if len(vals) > 0 {
+			if values != "" {
+				buf = f.appendComma(buf)
+			}
+			buf = f.flatten(buf, vals, true) // escape user-provided keys
+		}
+	}
+	for i := deepest; i >= 0; i-- {
+		name := f.groupName
+		if i < n {
+			name = f.groups[i].name
+		}
+		if name != "" {
+			buf = f.appendDelim(buf, '}')
+		}
+	}
+	return buf
+}
+
+// entryKind says where the value of an entry comes from.
+type entryKind int
+
+const (
+	entryBuiltin  entryKind = iota // a builtin, rendered from the record
+	entryRendered                  // a saved value, already rendered
+	entryValue                     // a value to render
+	entryGroup                     // the next group
+)
+
+// entry is one key-value pair of a level of a log line, which is used when
+// Options.DuplicateKeys is set.
+type entry struct {
+	key   string
+	kind  entryKind
+	user  bool // the key came from the user, so it can be renamed
+	b     Builtin
+	text  string
+	value any
+}
+
+// appendDedupedRecord renders a complete log line like appendFullRecord, but
+// applies Options.DuplicateKeys.  This is slower, because it must collect
+// each level's keys before rendering any of them.
+func (f Formatter) appendDedupedRecord(buf []byte, list []Builtin, rec *record, args []any) []byte {
+	var top []entry
+	if hook := f.opts.RenderBuiltinsHook; hook != nil {
+		vals := make([]any, 0, 2*len(list))
+		for _, b := range list {
+			vals = append(vals, f.builtinKey(b), f.builtinValue(b, rec))
+		}
+		top = f.appendEntries(top, hook(f.sanitize(vals)), false)
+	} else {
+		for _, b := range list {
+			top = append(top, entry{key: f.builtinKey(b), kind: entryBuiltin, b: b})
+		}
+	}
+
+	vals := args
+	if hook := f.opts.RenderArgsHook; hook != nil {
+		vals = hook(f.sanitize(vals))
+	}
+
+	// Find the inner-most level with anything to render, as in appendBody.
+	n := len(f.groups)
+	deepest := 0
+	if len(f.valuesKVs) > 0 || len(vals) > 0 {
+		deepest = n
+	} else {
+		for i := n - 1; i > 0; i-- {
+			if len(f.groups[i].kvs) > 0 {
+				deepest = i
+				break
+			}
+		}
+	}
+
+	if f.outputFormat != outputKeyValue {
+		buf = f.appendDelim(buf, '{') // for the whole record
+	}
+	buf = f.appendLevel(buf, rec, top, 0, deepest, vals)
+	if f.outputFormat != outputKeyValue {
+		buf = f.appendDelim(buf, '}') // for the whole record
+	}
+	return buf
+}
+
+// appendLevel renders one level of a log line: the given entries, which are
+// builtins for the top level, followed by the saved values for that level,
+// and then either the next level or the call-site args if this is the
+// deepest level.
+func (f Formatter) appendLevel(buf []byte, rec *record, entries []entry, level, deepest int, args []any) []byte {
+	kvs := f.valuesKVs
+	if level < len(f.groups) {
+		kvs = f.groups[level].kvs
+	}
+	for _, kv := range kvs {
+		entries = append(entries, entry{key: kv.key, kind: entryRendered, user: true, text: kv.value})
+	}
+	if level < deepest {
+		name := f.groupName
+		if level+1 < len(f.groups) {
+			name = f.groups[level+1].name
+		}
+		entries = append(entries, entry{key: name, kind: entryGroup, user: true})
+	} else {
+		entries = f.appendEntries(entries, args, true)
+	}
+
+	for i, e := range f.dedupe(entries) {
+		if i > 0 {
+			buf = f.appendComma(buf)
+		}
+		buf = f.appendKey(buf, e.key, e.user)
+		buf = f.appendColon(buf)
+		switch e.kind {
+		case entryBuiltin:
+			buf = f.appendBuiltinValue(buf, e.b, rec)
+		case entryRendered:
+			buf = append(buf, e.text...)
+		case entryValue:
+			buf = f.appendPretty(buf, e.value, 0, 0, 0, nil)
+		case entryGroup:
+			buf = f.appendDelim(buf, '{')
+			buf = f.appendLevel(buf, rec, nil, level+1, deepest, args)
+			buf = f.appendDelim(buf, '}')
+		}
 	}
+	return buf
+}
 
-	if args != "" {
-		if continuing {
-			buf.WriteByte(f.comma())
+// appendEntries adds a list of key-value pairs to a list of entries, handling
+// a missing value for the last key and keys which are not strings, like
+// flatten.
+func (f Formatter) appendEntries(entries []entry, kvList []any, user bool) []entry {
+	for i := 0; i < len(kvList); i += 2 {
+		k, ok := kvList[i].(string)
+		if !ok {
+			k = f.nonStringKey(kvList[i])
+		}
+		var v any = noValue
+		if i+1 < len(kvList) {
+			v = kvList[i+1]
 		}
-		buf.WriteString(args)
+		entries = append(entries, entry{key: k, kind: entryValue, user: user, value: v})
 	}
+	return entries
+}
 
-	if needClosingBrace {
-		buf.WriteByte('}')
+// dedupe applies Options.DuplicateKeys to a list of entries, in place.
+func (f Formatter) dedupe(entries []entry) []entry {
+	last := make(map[string]int, len(entries)) // key -> index of last use
+	for i := range entries {
+		last[entries[i].key] = i
+	}
+	if len(last) == len(entries) {
+		return entries // no duplicates
 	}
 
-	return buf.String()
+	out := entries[:0]
+	switch f.opts.DuplicateKeys {
+	case LastKeyWins:
+		for i, e := range entries {
+			if last[e.key] == i {
+				out = append(out, e)
+			}
+		}
+	case FirstKeyWins:
+		for _, e := range entries {
+			if last[e.key] >= 0 {
+				last[e.key] = -1 // seen
+				out = append(out, e)
+			}
+		}
+	case RenameDuplicateKeys:
+		used := make(map[string]bool, len(entries))
+		for _, e := range entries {
+			for used[e.key] && e.user {
+				e.key = f.opts.DuplicateKeyPrefix + e.key
+			}
+			used[e.key] = true
+			out = append(out, e)
+		}
+	}
+	return out
 }
 
 // flatten renders a list of key-value pairs into a buffer.  If escapeKeys is
 // true, the keys are assumed to have non-JSON-compatible characters in them
 // and must be evaluated for escapes.
 //
-// This function returns a potentially modified version of kvList, which
-// ensures that there is a value for every key (adding a value if needed) and
-// that each key is a string (substituting a key if needed).
-func (f Formatter) flatten(buf *bytes.Buffer, kvList []any, escapeKeys bool) []any {
+// This function handles a missing value for the last key (adding a value) and
+// keys which are not strings (substituting a key), without modifying kvList.
+func (f Formatter) flatten(buf []byte, kvList []any, escapeKeys bool) []byte {
 	// This logic overlaps with sanitize() but saves one type-cast per key,
 	// which can be measurable.
-	if len(kvList)%2 != 0 {
-		kvList = append(kvList, noValue)
-	}
-	copied := false
 	for i := 0; i < len(kvList); i += 2 {
 		k, ok := kvList[i].(string)
 		if !ok {
-			if !copied {
-				newList := make([]any, len(kvList))
-				copy(newList, kvList)
-				kvList = newList
-				copied = true
-			}
 			k = f.nonStringKey(kvList[i])
-			kvList[i] = k
 		}
-		v := kvList[i+1]
+		var v any = noValue
+		if i+1 < len(kvList) {
+			v = kvList[i+1]
+		}
 
 		if i > 0 {
-			if f.outputFormat == outputJSON {
-				buf.WriteByte(f.comma())
-			} else {
-				// In theory the format could be something we don't understand.  In
-				// practice, we control it, so it won't be.
-				buf.WriteByte(' ')
-			}
+			buf = f.appendComma(buf)
 		}
 
-		buf.WriteString(f.quoted(k, escapeKeys))
-		buf.WriteByte(f.colon())
-		buf.WriteString(f.pretty(v))
+		buf = f.appendKey(buf, k, escapeKeys)
+		buf = f.appendColon(buf)
+		buf = f.appendPretty(buf, v, 0, 0, 0, nil)
 	}
-	return kvList
+	return buf
 }
 
-func (f Formatter) quoted(str string, escape bool) string {
-	if escape {
-		return prettyString(str)
+func (f Formatter) appendKey(buf []byte, str string, escape bool) []byte {
+	if escape || f.strictJSON() || f.outputFormat == outputCBOR {
+		return f.appendString(buf, str)
 	}
 	// this is faster
-	return `"` + str + `"`
+	buf = append(buf, '"')
+	buf = append(buf, str...)
+	return append(buf, '"')
+}
+
+// strictJSON determines whether Options.StrictJSON applies.
+func (f Formatter) strictJSON() bool {
+	return f.opts.StrictJSON && f.outputFormat == outputJSON
+}
+
+// safeText determines whether Options.SafeText applies.
+func (f Formatter) safeText() bool {
+	return f.opts.SafeText && f.outputFormat == outputKeyValue
+}
+
+func (f Formatter) appendComma(buf []byte) []byte {
+	switch f.outputFormat {
+	case outputJSON:
+		return append(buf, ',')
+	case outputCBOR:
+		return buf // items are self-delimiting
+	}
+	return append(buf, ' ')
 }
 
-func (f Formatter) comma() byte {
-	if f.outputFormat == outputJSON {
-		return ','
+func (f Formatter) appendColon(buf []byte) []byte {
+	switch f.outputFormat {
+	case outputJSON:
+		return append(buf, ':')
+	case outputCBOR:
+		return buf // items are self-delimiting
 	}
-	return ' '
+	return append(buf, '=')
 }
 
-func (f Formatter) colon() byte {
-	if f.outputFormat == outputJSON {
-		return ':'
+// appendDelim renders the start or end of an object ('{' or '}') or array
+// ('[' or ']').  CBOR uses indefinite-length maps and arrays, so they can be
+// rendered without knowing how many items they will hold.
+func (f Formatter) appendDelim(buf []byte, delim byte) []byte {
+	if f.outputFormat != outputCBOR {
+		return append(buf, delim)
 	}
-	return '='
+	switch delim {
+	case '{':
+		return append(buf, cborMapStart)
+	case '[':
+		return append(buf, cborArrayStart)
+	}
+	return append(buf, cborBreak)
 }
 
 func (f Formatter) pretty(value any) string {
-	return f.prettyWithFlags(value, 0, 0, 0, nil)
+	bufp := getBuffer()
+	defer putBuffer(bufp)
+	*bufp = f.appendPretty(*bufp, value, 0, 0, 0, nil)
+	return string(*bufp)
 }
 
 const (
 	flagRawStruct = 0x1 // do not print braces on structs
+	flagMapKey    = 0x2 // do not truncate strings
 )
 
+// appendPretty renders an arbitrary value into buf.
 // TODO: This is not fast. Most of the overhead goes here.
 // value: The value to render
 // flags: Bitmask of flags (see above)
@@ -440,9 +1350,27 @@ const (
 // ptrDepth: The current depth of including pointer dereferences
 // ptrMap: A map of pointers already seen, to avoid infinite recursion (usually
 // nil unless ptrDepth is large)
-func (f Formatter) prettyWithFlags(value any, flags uint32, depth int, ptrDepth int, ptrMap map[uintptr]bool) string {
+func (f Formatter) appendPretty(buf []byte, value any, flags uint32, depth int, ptrDepth int, ptrMap map[uintptr]bool) []byte {
 	if depth > f.opts.MaxLogDepth {
-		return `"<max-log-depth-exceeded>"`
+		return f.appendString(buf, "<max-log-depth-exceeded>")
+	}
+
+	// Handle types for which the user wants to take control of logging.
+	if f.encoders != nil && value != nil {
+		t := reflect.TypeOf(value)
+		if enc := f.encoders.lookup(t); enc != nil {
+			value = invokeTypeEncoder(enc.Encode, value)
+		} else if t.Kind() == reflect.Ptr {
+			// Pointers would otherwise be caught by the method set checks
+			// below before being dereferenced.
+			if enc := f.encoders.lookup(t.Elem()); enc != nil {
+				v := reflect.ValueOf(value)
+				if v.IsNil() {
+					return f.appendNull(buf)
+				}
+				value = invokeTypeEncoder(enc.Encode, v.Elem().Interface())
+			}
+		}
 	}
 
 	// Handle types that take full control of logging.
@@ -452,8 +1380,25 @@ func (f Formatter) prettyWithFlags(value any, flags uint32, depth int, ptrDepth
 		value = invokeMarshaler(v)
 	}
 
+	// Handle types that know how to encode themselves, if enabled.
+	if f.opts.UseEncodingMarshalers {
+		switch v := value.(type) {
+		case json.RawMessage:
+			// This is handled below, like other byte slices, even though
+			// it is a json.Marshaler too.
+		case json.Marshaler:
+			return f.appendMarshalJSON(buf, v)
+		case encoding.TextMarshaler:
+			value = invokeMarshalText(v)
+		}
+	}
+
 	// Handle types that want to format themselves.
 	switch v := value.(type) {
+	case json.RawMessage:
+		// Since Go 1.27, RawMessage is an alias of jsontext.Value, whose
+		// String method would render it as a quoted string.  It is handled
+		// below instead, to keep the rendering of Go 1.26 and before.
 	case fmt.Stringer:
 		value = invokeStringer(v)
 	case error:
@@ -463,204 +1408,178 @@ func (f Formatter) prettyWithFlags(value any, flags uint32, depth int, ptrDepth
 	// Handling the most common types without reflect is a small perf win.
 	switch v := value.(type) {
 	case bool:
-		return strconv.FormatBool(v)
+		return f.appendBool(buf, v)
 	case string:
-		return prettyString(v)
+		return f.appendStringValue(buf, v, flags)
 	case int:
-		return strconv.FormatInt(int64(v), 10)
+		return f.appendInt(buf, int64(v))
 	case int8:
-		return strconv.FormatInt(int64(v), 10)
+		return f.appendInt(buf, int64(v))
 	case int16:
-		return strconv.FormatInt(int64(v), 10)
+		return f.appendInt(buf, int64(v))
 	case int32:
-		return strconv.FormatInt(int64(v), 10)
+		return f.appendInt(buf, int64(v))
 	case int64:
-		return strconv.FormatInt(int64(v), 10)
+		return f.appendInt(buf, int64(v))
 	case uint:
-		return strconv.FormatUint(uint64(v), 10)
+		return f.appendUint(buf, uint64(v))
 	case uint8:
-		return strconv.FormatUint(uint64(v), 10)
+		return f.appendUint(buf, uint64(v))
 	case uint16:
-		return strconv.FormatUint(uint64(v), 10)
+		return f.appendUint(buf, uint64(v))
 	case uint32:
-		return strconv.FormatUint(uint64(v), 10)
+		return f.appendUint(buf, uint64(v))
 	case uint64:
-		return strconv.FormatUint(v, 10)
+		return f.appendUint(buf, v)
 	case uintptr:
-		return strconv.FormatUint(uint64(v), 10)
+		return f.appendUint(buf, uint64(v))
 	case float32:
-		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+		return f.appendFloat(buf, float64(v), 32)
 	case float64:
-		return strconv.FormatFloat(v, 'f', -1, 64)
+		return f.appendFloat(buf, v, 64)
 	case complex64:
-		return `"` + strconv.FormatComplex(complex128(v), 'f', -1, 64) + `"`
+		return f.appendComplex(buf, complex128(v), 64)
 	case complex128:
-		return `"` + strconv.FormatComplex(v, 'f', -1, 128) + `"`
+		return f.appendComplex(buf, v, 128)
 	case PseudoStruct:
-		buf := bytes.NewBuffer(make([]byte, 0, 1024))
 		v = f.sanitize(v)
 		if flags&flagRawStruct == 0 {
-			buf.WriteByte('{')
+			buf = f.appendDelim(buf, '{')
 		}
 		for i := 0; i < len(v); i += 2 {
 			if i > 0 {
-				buf.WriteByte(f.comma())
+				buf = f.appendComma(buf)
 			}
 			k, _ := v[i].(string) // sanitize() above means no need to check success
 			// arbitrary keys might need escaping
-			buf.WriteString(prettyString(k))
-			buf.WriteByte(f.colon())
-			buf.WriteString(f.prettyWithFlags(v[i+1], 0, depth+1, ptrDepth+1, ptrMap))
+			buf = f.appendString(buf, k)
+			buf = f.appendColon(buf)
+			buf = f.appendPretty(buf, v[i+1], 0, depth+1, ptrDepth+1, ptrMap)
 		}
 		if flags&flagRawStruct == 0 {
-			buf.WriteByte('}')
+			buf = f.appendDelim(buf, '}')
 		}
-		return buf.String()
+		return buf
+	}
+
+	if value == nil {
+		return f.appendNull(buf)
 	}
+	return f.appendValue(buf, reflect.ValueOf(value), flags, depth, ptrDepth, ptrMap)
+}
 
-	buf := bytes.NewBuffer(make([]byte, 0, 256))
-	t := reflect.TypeOf(value)
-	if t == nil {
-		return "null"
+// appendValue renders a value via reflection.  The value must not be one that
+// needs special handling by appendPretty, such as a type which implements
+// logr.Marshaler (see typeInfo.plain).  The arguments are the same as for
+// appendPretty.
+func (f Formatter) appendValue(buf []byte, v reflect.Value, flags uint32, depth int, ptrDepth int, ptrMap map[uintptr]bool) []byte {
+	if depth > f.opts.MaxLogDepth {
+		return f.appendString(buf, "<max-log-depth-exceeded>")
 	}
-	v := reflect.ValueOf(value)
+
+	t := v.Type()
 	switch t.Kind() {
 	case reflect.Bool:
-		return strconv.FormatBool(v.Bool())
+		return f.appendBool(buf, v.Bool())
 	case reflect.String:
-		return prettyString(v.String())
+		return f.appendStringValue(buf, v.String(), flags)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return strconv.FormatInt(int64(v.Int()), 10)
+		return f.appendInt(buf, v.Int())
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return strconv.FormatUint(uint64(v.Uint()), 10)
+		return f.appendUint(buf, v.Uint())
 	case reflect.Float32:
-		return strconv.FormatFloat(float64(v.Float()), 'f', -1, 32)
+		return f.appendFloat(buf, v.Float(), 32)
 	case reflect.Float64:
-		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		return f.appendFloat(buf, v.Float(), 64)
 	case reflect.Complex64:
-		return `"` + strconv.FormatComplex(complex128(v.Complex()), 'f', -1, 64) + `"`
+		return f.appendComplex(buf, v.Complex(), 64)
 	case reflect.Complex128:
-		return `"` + strconv.FormatComplex(v.Complex(), 'f', -1, 128) + `"`
+		return f.appendComplex(buf, v.Complex(), 128)
 	case reflect.Struct:
-		if flags&flagRawStruct == 0 {
-			buf.WriteByte('{')
-		}
-		printComma := false // testing i>0 is not enough because of JSON omitted fields
-		for i := 0; i < t.NumField(); i++ {
-			fld := t.Field(i)
-			if fld.PkgPath != "" {
-				// reflect says this field is only defined for non-exported fields.
-				continue
-			}
-			if !v.Field(i).CanInterface() {
-				// reflect isn't clear exactly what this means, but we can't use it.
-				continue
-			}
-			name := ""
-			omitempty := false
-			if tag, found := fld.Tag.Lookup("json"); found {
-				if tag == "-" {
-					continue
-				}
-				if comma := strings.Index(tag, ","); comma != -1 {
-					if n := tag[:comma]; n != "" {
-						name = n
-					}
-					rest := tag[comma:]
-					if strings.Contains(rest, ",omitempty,") || strings.HasSuffix(rest, ",omitempty") {
-						omitempty = true
-					}
-				} else {
-					name = tag
-				}
-			}
-			if omitempty && isEmpty(v.Field(i)) {
-				continue
-			}
-			if printComma {
-				buf.WriteByte(f.comma())
-			}
-			printComma = true // if we got here, we are rendering a field
-			if fld.Anonymous && fld.Type.Kind() == reflect.Struct && name == "" {
-				buf.WriteString(f.prettyWithFlags(v.Field(i).Interface(), flags|flagRawStruct, depth+1, ptrDepth+1, ptrMap))
-				continue
-			}
-			if name == "" {
-				name = fld.Name
-			}
-			// field names can't contain characters which need escaping
-			buf.WriteString(f.quoted(name, false))
-			buf.WriteByte(f.colon())
-			buf.WriteString(f.prettyWithFlags(v.Field(i).Interface(), 0, depth+1, ptrDepth+1, ptrMap))
-		}
-		if flags&flagRawStruct == 0 {
-			buf.WriteByte('}')
-		}
-		return buf.String()
+		return f.appendStruct(buf, v, flags, depth, ptrDepth, ptrMap)
 	case reflect.Slice, reflect.Array:
 		// If this is outputing as JSON make sure this isn't really a json.RawMessage.
 		// If so just emit "as-is" and don't pretty it as that will just print
 		// it as [X,Y,Z,...] which isn't terribly useful vs the string form you really want.
-		if f.outputFormat == outputJSON {
-			if rm, ok := value.(json.RawMessage); ok {
-				// If it's empty make sure we emit an empty value as the array style would below.
-				if len(rm) > 0 {
-					buf.Write(rm)
-				} else {
-					buf.WriteString("null")
+		if f.outputFormat != outputKeyValue && t == rawMessageType {
+			// If it's empty make sure we emit an empty value as the array style would below.
+			if rm := v.Bytes(); len(rm) > 0 {
+				if f.outputFormat == outputCBOR {
+					return f.appendJSONAsCBOR(buf, rm, "RawMessage")
+				}
+				if f.strictJSON() {
+					return f.appendCompactJSON(buf, rm, "RawMessage")
 				}
-				return buf.String()
+				return append(buf, rm...)
 			}
+			return f.appendNull(buf)
 		}
-		buf.WriteByte('[')
-		for i := 0; i < v.Len(); i++ {
+		plain := f.renderDirect(t.Elem())
+		n := f.collectionLimit(v.Len())
+		buf = f.appendDelim(buf, '[')
+		for i := 0; i < n; i++ {
 			if i > 0 {
-				buf.WriteByte(f.comma())
+				buf = f.appendComma(buf)
 			}
 			e := v.Index(i)
-			buf.WriteString(f.prettyWithFlags(e.Interface(), 0, depth+1, ptrDepth+1, ptrMap))
+			if plain {
+				buf = f.appendValue(buf, e, 0, depth+1, ptrDepth+1, ptrMap)
+				continue
+			}
+			buf = f.appendPretty(buf, e.Interface(), 0, depth+1, ptrDepth+1, ptrMap)
+		}
+		if n < v.Len() {
+			if n > 0 {
+				buf = f.appendComma(buf)
+			}
+			buf = f.appendMoreItems(buf, v.Len()-n)
 		}
-		buf.WriteByte(']')
-		return buf.String()
+		return f.appendDelim(buf, ']')
 	case reflect.Map:
-		buf.WriteByte('{')
+		buf = f.appendDelim(buf, '{')
+		if f.opts.SortKeys {
+			buf = f.appendSortedMap(buf, v, depth, ptrDepth, ptrMap)
+			return f.appendDelim(buf, '}')
+		}
 		// This does not sort the map keys, for best perf.
+		plain := f.renderDirect(t.Elem())
+		simpleKey := getTypeInfo(t.Key()).simpleKey && !f.hasEncoder(t.Key())
+		// Simple keys and plain values are copied into reusable Values,
+		// which avoids an allocation per entry.
+		var key, val reflect.Value
+		if simpleKey {
+			key = reflect.New(t.Key()).Elem()
+		}
+		if plain {
+			val = reflect.New(t.Elem()).Elem()
+		}
+		n := f.collectionLimit(v.Len())
 		it := v.MapRange()
 		i := 0
-		for it.Next() {
+		for i < n && it.Next() {
 			if i > 0 {
-				buf.WriteByte(f.comma())
+				buf = f.appendComma(buf)
 			}
-			// If a map key supports TextMarshaler, use it.
-			keystr := ""
-			if m, ok := it.Key().Interface().(encoding.TextMarshaler); ok {
-				txt, err := m.MarshalText()
-				if err != nil {
-					keystr = fmt.Sprintf("<error-MarshalText: %s>", err.Error())
-				} else {
-					keystr = string(txt)
-				}
-				keystr = prettyString(keystr)
+			if simpleKey {
+				key.SetIterKey(it)
+				buf = f.appendSimpleMapKey(buf, key)
 			} else {
-				// prettyWithFlags will produce already-escaped values
-				// key depth is unrelated to overall depth
-				keystr = f.prettyWithFlags(it.Key().Interface(), 0, 0, ptrDepth, ptrMap)
-				if t.Key().Kind() != reflect.String {
-					// JSON only does string keys.  Unlike Go's standard JSON, we'll
-					// convert just about anything to a string.
-					keystr = prettyString(keystr)
-				}
+				buf = f.appendMapKey(buf, it.Key(), ptrDepth, ptrMap)
+			}
+			buf = f.appendColon(buf)
+			if plain {
+				val.SetIterValue(it)
+				buf = f.appendValue(buf, val, 0, depth+1, ptrDepth+1, ptrMap)
+			} else {
+				buf = f.appendPretty(buf, it.Value().Interface(), 0, depth+1, ptrDepth+1, ptrMap)
 			}
-			buf.WriteString(keystr)
-			buf.WriteByte(f.colon())
-			buf.WriteString(f.prettyWithFlags(it.Value().Interface(), 0, depth+1, ptrDepth+1, ptrMap))
 			i++
 		}
-		buf.WriteByte('}')
-		return buf.String()
+		buf = f.appendMoreEntries(buf, n, v.Len())
+		return f.appendDelim(buf, '}')
 	case reflect.Pointer, reflect.Interface:
 		if v.IsNil() {
-			return "null"
+			return f.appendNull(buf)
 		}
 		// Special case: recursive pointers.  For normal use we do not want to
 		// count pointer dereferences as depth, but if we see the same pointer
@@ -680,21 +1599,504 @@ func (f Formatter) prettyWithFlags(value any, flags uint32, depth int, ptrDepth
 		if ptrMap != nil {
 			ptrMap[(uintptr)(v.Pointer())] = true
 		}
-		return f.prettyWithFlags(v.Elem().Interface(), 0, depth, ptrDepth+1, ptrMap)
+		return f.appendPretty(buf, v.Elem().Interface(), 0, depth, ptrDepth+1, ptrMap)
+	}
+	return f.appendString(buf, "<unhandled-"+t.Kind().String()+">")
+}
+
+// typeInfo describes how to render values of a given type.  It depends only
+// on the type, so it is computed once per type and cached.
+type typeInfo struct {
+	// plain is true for types which can be rendered directly from a
+	// reflect.Value by appendValue, because they do not need any special
+	// handling by appendPretty (e.g. they do not implement logr.Marshaler).
+	// Callers must also check renderDirect.
+	plain bool
+	// marshals is true for types which implement json.Marshaler or
+	// encoding.TextMarshaler, and so are not plain if
+	// Options.UseEncodingMarshalers is set.
+	marshals bool
+	// simpleKey is true for plain booleans, numbers, and strings which do
+	// not implement encoding.TextMarshaler, and so can be rendered as map
+	// keys by appendSimpleMapKey.
+	simpleKey bool
+	// fields lists the fields to render, for struct types.
+	fields []fieldInfo
+}
+
+// fieldInfo describes how to render one field of a struct.
+type fieldInfo struct {
+	index     int
+	name      string // the key to use, after considering JSON tags
+	omitempty bool
+	inline    bool // an embedded struct, rendered as if its fields were ours
+	plain     bool // the field's type is plain (see typeInfo)
+	marshals  bool // the field's type is a marshaler (see typeInfo)
+	typ       reflect.Type
+}
+
+// typeInfoCache holds a *typeInfo for each reflect.Type seen so far.
+var typeInfoCache sync.Map
+
+var (
+	marshalerType     = reflect.TypeOf((*logr.Marshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	pseudoStructType  = reflect.TypeOf(PseudoStruct(nil))
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+)
+
+func getTypeInfo(t reflect.Type) *typeInfo {
+	if ti, ok := typeInfoCache.Load(t); ok {
+		return ti.(*typeInfo) //nolint:forcetypeassert // only *typeInfo is stored
+	}
+	ti, _ := typeInfoCache.LoadOrStore(t, newTypeInfo(t))
+	return ti.(*typeInfo) //nolint:forcetypeassert // only *typeInfo is stored
+}
+
+func newTypeInfo(t reflect.Type) *typeInfo {
+	ti := &typeInfo{plain: isPlain(t), marshals: isMarshaler(t)}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		ti.simpleKey = ti.plain && !t.Implements(textMarshalerType)
+	case reflect.Struct:
+		ti.fields = structFields(t)
+	}
+	return ti
+}
+
+// isPlain determines whether values of a type can be rendered directly from
+// a reflect.Value (see typeInfo.plain).
+func isPlain(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface || t == pseudoStructType {
+		return false
+	}
+	return !t.Implements(marshalerType) && !t.Implements(stringerType) && !t.Implements(errorType)
+}
+
+// isMarshaler determines whether a type implements json.Marshaler or
+// encoding.TextMarshaler (see typeInfo.marshals).
+func isMarshaler(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType)
+}
+
+// renderDirect determines whether values of a type can be rendered by
+// appendValue without going through appendPretty, considering both the type
+// and the Formatter's options.
+func (f Formatter) renderDirect(t reflect.Type) bool {
+	ti := getTypeInfo(t)
+	return f.fieldDirect(ti.plain, ti.marshals, t)
+}
+
+// fieldDirect is like renderDirect, for a struct field.
+func (f Formatter) fieldDirect(plain, marshals bool, t reflect.Type) bool {
+	return plain && !(marshals && f.opts.UseEncodingMarshalers) && !f.hasEncoder(t)
+}
+
+// structFields determines which fields of a struct type to render, and how.
+func structFields(t reflect.Type) []fieldInfo {
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		fld := t.Field(i)
+		if fld.PkgPath != "" {
+			// reflect says this field is only defined for non-exported fields.
+			continue
+		}
+		name := ""
+		omitempty := false
+		if tag, found := fld.Tag.Lookup("json"); found {
+			if tag == "-" {
+				continue
+			}
+			if comma := strings.Index(tag, ","); comma != -1 {
+				if n := tag[:comma]; n != "" {
+					name = n
+				}
+				rest := tag[comma:]
+				if strings.Contains(rest, ",omitempty,") || strings.HasSuffix(rest, ",omitempty") {
+					omitempty = true
+				}
+			} else {
+				name = tag
+			}
+		}
+		inline := fld.Anonymous && fld.Type.Kind() == reflect.Struct && name == ""
+		if name == "" {
+			name = fld.Name
+		}
+		fields = append(fields, fieldInfo{
+			index:     i,
+			name:      name,
+			omitempty: omitempty,
+			inline:    inline,
+			plain:     isPlain(fld.Type),
+			marshals:  isMarshaler(fld.Type),
+			typ:       fld.Type,
+		})
+	}
+	return fields
+}
+
+// appendStruct renders a struct, using the cached description of its type.
+func (f Formatter) appendStruct(buf []byte, v reflect.Value, flags uint32, depth int, ptrDepth int, ptrMap map[uintptr]bool) []byte {
+	ti := getTypeInfo(v.Type())
+	if flags&flagRawStruct == 0 {
+		buf = f.appendDelim(buf, '{')
+	}
+	printComma := false // testing i>0 is not enough because of JSON omitted fields
+	for i := range ti.fields {
+		fi := &ti.fields[i]
+		fv := v.Field(fi.index)
+		if !fv.CanInterface() {
+			// reflect isn't clear exactly what this means, but we can't use it.
+			continue
+		}
+		if fi.omitempty && isEmpty(fv) {
+			continue
+		}
+		if printComma {
+			buf = f.appendComma(buf)
+		}
+		printComma = true // if we got here, we are rendering a field
+		fieldFlags := uint32(0)
+		if fi.inline {
+			fieldFlags = flags | flagRawStruct
+		} else {
+			// field names can't contain characters which need escaping
+			buf = f.appendKey(buf, fi.name, false)
+			buf = f.appendColon(buf)
+		}
+		if f.fieldDirect(fi.plain, fi.marshals, fi.typ) {
+			buf = f.appendValue(buf, fv, fieldFlags, depth+1, ptrDepth+1, ptrMap)
+			continue
+		}
+		buf = f.appendPretty(buf, fv.Interface(), fieldFlags, depth+1, ptrDepth+1, ptrMap)
+	}
+	if flags&flagRawStruct == 0 {
+		buf = f.appendDelim(buf, '}')
+	}
+	return buf
+}
+
+// appendSimpleMapKey renders a map key whose type has typeInfo.simpleKey set.
+// This produces the same result as appendMapKey.
+func (f Formatter) appendSimpleMapKey(buf []byte, key reflect.Value) []byte {
+	if key.Kind() == reflect.String {
+		return f.appendValue(buf, key, flagMapKey, 0, 0, nil)
+	}
+	if f.outputFormat == outputCBOR {
+		var tmp [32]byte
+		return appendCBORText(buf, f.textFormatter().appendValue(tmp[:0], key, 0, 0, 0, nil))
+	}
+	// Booleans and numbers never need escaping, so they just need quotes.
+	buf = append(buf, '"')
+	buf = f.appendValue(buf, key, 0, 0, 0, nil)
+	return append(buf, '"')
+}
+
+// appendMapKey renders a map key, which is always rendered as a string.
+func (f Formatter) appendMapKey(buf []byte, key reflect.Value, ptrDepth int, ptrMap map[uintptr]bool) []byte {
+	// If a map key supports TextMarshaler, use it.
+	if m, ok := key.Interface().(encoding.TextMarshaler); ok {
+		txt, err := m.MarshalText()
+		if err != nil {
+			return f.appendString(buf, fmt.Sprintf("<error-MarshalText: %s>", err.Error()))
+		}
+		return f.appendString(buf, string(txt))
+	}
+	// appendPretty will produce already-escaped values
+	// key depth is unrelated to overall depth
+	start := len(buf)
+	buf = f.appendPretty(buf, key.Interface(), flagMapKey, 0, ptrDepth, ptrMap)
+	if f.outputFormat == outputCBOR {
+		if key.Kind() == reflect.String && buf[start]&cborMajorMask == cborText {
+			return buf
+		}
+		txt := f.textFormatter().appendPretty(nil, key.Interface(), flagMapKey, 0, ptrDepth, ptrMap)
+		return appendCBORText(buf[:start], txt)
+	}
+	if key.Kind() == reflect.String && buf[start] == '"' {
+		return buf
+	}
+	// JSON only does string keys.  Unlike Go's standard JSON, we'll
+	// convert just about anything to a string.
+	return f.appendString(buf[:start], string(buf[start:]))
+}
+
+// appendSortedMap renders the entries of a map, sorted by their rendered keys,
+// or numerically for keys of the predeclared numeric types.
+func (f Formatter) appendSortedMap(buf []byte, v reflect.Value, depth int, ptrDepth int, ptrMap map[uintptr]bool) []byte {
+	type entry struct {
+		key    string
+		rawKey reflect.Value
+		val    reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	it := v.MapRange()
+	for it.Next() {
+		start := len(buf)
+		buf = f.appendMapKey(buf, it.Key(), ptrDepth, ptrMap)
+		entries = append(entries, entry{string(buf[start:]), it.Key(), it.Value()})
+		buf = buf[:start]
+	}
+	// Types with methods, or with a TypeEncoder, may be rendered in any
+	// way, so only predeclared ones are compared by value.
+	kt := v.Type().Key()
+	numeric := kt.PkgPath() == "" && kt.Name() != "" && !f.hasEncoder(kt)
+	sort.Slice(entries, func(i, j int) bool {
+		if numeric {
+			a, b := entries[i].rawKey, entries[j].rawKey
+			switch kt.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return a.Int() < b.Int()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				return a.Uint() < b.Uint()
+			case reflect.Float32, reflect.Float64:
+				// NaNs first, as they compare false to everything.
+				fa, fb := a.Float(), b.Float()
+				return fa < fb || math.IsNaN(fa) && !math.IsNaN(fb)
+			}
+		}
+		if f.outputFormat == outputCBOR {
+			// Sort by the text, not its encoded length.
+			return cborTextPayload(entries[i].key) < cborTextPayload(entries[j].key)
+		}
+		return entries[i].key < entries[j].key
+	})
+	n := f.collectionLimit(len(entries))
+	for i := range entries[:n] {
+		if i > 0 {
+			buf = f.appendComma(buf)
+		}
+		buf = append(buf, entries[i].key...)
+		buf = f.appendColon(buf)
+		buf = f.appendPretty(buf, entries[i].val.Interface(), 0, depth+1, ptrDepth+1, ptrMap)
 	}
-	return fmt.Sprintf(`"<unhandled-%s>"`, t.Kind().String())
+	return f.appendMoreEntries(buf, n, len(entries))
 }
 
-func prettyString(s string) string {
-	// Avoid escaping (which does allocations) if we can.
+// collectionLimit returns how many of n elements of a collection to render,
+// considering Options.MaxCollectionItems.
+func (f Formatter) collectionLimit(n int) int {
+	if max := f.opts.MaxCollectionItems; max > 0 && n > max {
+		return max
+	}
+	return n
+}
+
+// appendMoreEntries marks a map of total entries as truncated after the
+// first n, if n is less than total.
+func (f Formatter) appendMoreEntries(buf []byte, n, total int) []byte {
+	if n == total {
+		return buf
+	}
+	if n > 0 {
+		buf = f.appendComma(buf)
+	}
+	buf = f.appendKey(buf, truncatedKey, false)
+	buf = f.appendColon(buf)
+	return f.appendMoreItems(buf, total-n)
+}
+
+// appendMoreItems renders a marker for n elements which were not rendered.
+func (f Formatter) appendMoreItems(buf []byte, n int) []byte {
+	if f.outputFormat == outputCBOR {
+		return f.appendString(buf, "<+"+strconv.Itoa(n)+" more items>")
+	}
+	buf = append(buf, `"<+`...)
+	buf = strconv.AppendInt(buf, int64(n), 10)
+	return append(buf, ` more items>"`...)
+}
+
+// appendStringValue renders a string value, truncating it if it is longer
+// than Options.MaxStringLength, unless flags includes flagMapKey.
+func (f Formatter) appendStringValue(buf []byte, s string, flags uint32) []byte {
+	max := f.opts.MaxStringLength
+	if max <= 0 || len(s) <= max || flags&flagMapKey != 0 {
+		return f.appendString(buf, s)
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if f.outputFormat == outputCBOR {
+		return f.appendString(buf, s[:cut]+"…(+"+strconv.Itoa(len(s)-cut)+" bytes)")
+	}
+	buf = f.appendString(buf, s[:cut])
+	buf = append(buf[:len(buf)-1], "…(+"...) // replace the closing quote
+	buf = strconv.AppendInt(buf, int64(len(s)-cut), 10)
+	return append(buf, ` bytes)"`...)
+}
+
+// appendString renders a string as a quoted string, which is also a valid JSON
+// string if Options.StrictJSON applies, or as a CBOR text string.
+func (f Formatter) appendString(buf []byte, s string) []byte {
+	if f.outputFormat == outputCBOR {
+		return appendCBORString(buf, s)
+	}
+	if f.strictJSON() {
+		return appendJSONString(buf, s)
+	}
+	if f.safeText() && !utf8.ValidString(s) {
+		// needsEscape misses invalid bytes, which could be C1 controls.
+		return strconv.AppendQuote(buf, s)
+	}
+	return appendQuoted(buf, s)
+}
+
+// appendQuoted renders a string as a Go-style quoted string.
+func appendQuoted(buf []byte, s string) []byte {
+	// Avoid escaping (which is slower) if we can.
 	if needsEscape(s) {
-		return strconv.Quote(s)
+		return strconv.AppendQuote(buf, s)
+	}
+	buf = append(buf, '"')
+	buf = append(buf, s...)
+	return append(buf, '"')
+}
+
+// appendFloat renders a float, as a string if it is not finite and
+// Options.StrictJSON applies.
+func (f Formatter) appendFloat(buf []byte, v float64, bitSize int) []byte {
+	if f.outputFormat == outputCBOR {
+		return appendCBORFloat(buf, v, bitSize)
+	}
+	if f.strictJSON() && (math.IsNaN(v) || math.IsInf(v, 0)) {
+		buf = append(buf, '"')
+		buf = strconv.AppendFloat(buf, v, 'f', -1, bitSize)
+		return append(buf, '"')
+	}
+	return strconv.AppendFloat(buf, v, 'f', -1, bitSize)
+}
+
+func (f Formatter) appendComplex(buf []byte, c complex128, bitSize int) []byte {
+	if f.outputFormat == outputCBOR {
+		return f.appendString(buf, strconv.FormatComplex(c, 'f', -1, bitSize))
+	}
+	buf = append(buf, '"')
+	buf = append(buf, strconv.FormatComplex(c, 'f', -1, bitSize)...)
+	return append(buf, '"')
+}
+
+func (f Formatter) appendInt(buf []byte, v int64) []byte {
+	if f.outputFormat == outputCBOR {
+		return appendCBORInt(buf, v)
 	}
-	b := bytes.NewBuffer(make([]byte, 0, 1024))
-	b.WriteByte('"')
-	b.WriteString(s)
-	b.WriteByte('"')
-	return b.String()
+	return strconv.AppendInt(buf, v, 10)
+}
+
+func (f Formatter) appendUint(buf []byte, v uint64) []byte {
+	if f.outputFormat == outputCBOR {
+		return appendCBORHead(buf, cborUint, v)
+	}
+	return strconv.AppendUint(buf, v, 10)
+}
+
+func (f Formatter) appendBool(buf []byte, v bool) []byte {
+	if f.outputFormat == outputCBOR {
+		if v {
+			return append(buf, cborTrue)
+		}
+		return append(buf, cborFalse)
+	}
+	return strconv.AppendBool(buf, v)
+}
+
+func (f Formatter) appendNull(buf []byte) []byte {
+	if f.outputFormat == outputCBOR {
+		return append(buf, cborNull)
+	}
+	return append(buf, "null"...)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString renders a string as a quoted JSON string, replacing
+// invalid UTF-8 with U+FFFD.  U+2028 and U+2029 are escaped, as they are by
+// encoding/json, so the result is also valid JavaScript.
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0 // the first byte not yet copied to buf
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				buf = append(buf, '\\', c)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\ufffd`...)
+			i++
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
+
+// isSafeText determines whether the input is valid UTF-8 with only printable
+// characters, as defined by strconv.IsPrint.
+func isSafeText(b []byte) bool {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if (r == utf8.RuneError && size == 1) || !strconv.IsPrint(r) {
+			return false
+		}
+		b = b[size:]
+	}
+	return true
+}
+
+// appendEscapedName appends a name, escaping invalid UTF-8 and non-printable
+// characters as strconv.Quote would, but without adding quotes.
+func appendEscapedName(buf []byte, s string) []byte {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf = append(buf, '\\', 'x', hexDigits[s[i]>>4], hexDigits[s[i]&0xf])
+		case !strconv.IsPrint(r):
+			n := len(buf)
+			buf = strconv.AppendQuoteRune(buf, r)
+			buf = append(buf[:n], buf[n+1:len(buf)-1]...) // drop the quotes
+		default:
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return buf
 }
 
 // needsEscape determines whether the input string needs to be escaped or not,
@@ -737,6 +2139,73 @@ func invokeMarshaler(m logr.Marshaler) (ret any) {
 	return m.MarshalLog()
 }
 
+func invokeTypeEncoder(encode func(any) any, v any) (ret any) {
+	defer func() {
+		if r := recover(); r != nil {
+			ret = fmt.Sprintf("<panic: %s>", r)
+		}
+	}()
+	return encode(v)
+}
+
+// appendMarshalJSON appends the compacted output of m.MarshalJSON, or a
+// string describing why that failed.  In key-value mode, output which is not
+// already a string is rendered as a string.
+func (f Formatter) appendMarshalJSON(buf []byte, m json.Marshaler) []byte {
+	js, err := invokeMarshalJSON(m)
+	if err != nil {
+		return f.appendString(buf, fmt.Sprintf("<error-MarshalJSON: %s>", err.Error()))
+	}
+	if f.outputFormat == outputCBOR {
+		return f.appendJSONAsCBOR(buf, js, "MarshalJSON")
+	}
+	start := len(buf)
+	buf = f.appendCompactJSON(buf, js, "MarshalJSON")
+	if f.outputFormat == outputKeyValue && buf[start] != '"' {
+		return f.appendString(buf[:start], string(buf[start:]))
+	}
+	if f.safeText() && !isSafeText(buf[start:]) {
+		// JSON strings may hold DEL and C1 controls as-is.
+		var str string
+		if err := json.Unmarshal(buf[start:], &str); err == nil {
+			return f.appendString(buf[:start], str)
+		}
+	}
+	return buf
+}
+
+// appendCompactJSON appends js with insignificant whitespace removed, or a
+// string describing why it is not valid JSON, naming where it came from.
+func (f Formatter) appendCompactJSON(buf []byte, js []byte, source string) []byte {
+	out := bytes.NewBuffer(buf)
+	if err := json.Compact(out, js); err != nil {
+		return f.appendString(buf, fmt.Sprintf("<invalid-%s: %s>", source, err.Error()))
+	}
+	return out.Bytes()
+}
+
+func invokeMarshalJSON(m json.Marshaler) (ret []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ret, err = strconv.AppendQuote(nil, fmt.Sprintf("<panic: %s>", r)), nil
+		}
+	}()
+	return m.MarshalJSON()
+}
+
+func invokeMarshalText(m encoding.TextMarshaler) (ret string) {
+	defer func() {
+		if r := recover(); r != nil {
+			ret = fmt.Sprintf("<panic: %s>", r)
+		}
+	}()
+	txt, err := m.MarshalText()
+	if err != nil {
+		return fmt.Sprintf("<error-MarshalText: %s>", err.Error())
+	}
+	return string(txt)
+}
+
 func invokeStringer(s fmt.Stringer) (ret string) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -762,7 +2231,8 @@ func invokeError(e error) (ret string) {
 // pairs, one of which will be {"caller", Caller} if the Options.LogCaller
 // field is enabled for the given MessageClass.
 type Caller struct {
-	// File is the basename of the file for this call site.
+	// File is the file for this call site, formatted according to
+	// Options.CallerPath, which defaults to the base name.
 	File string `json:"file"`
 	// Line is the line number in the file for this call site.
 	Line int `json:"line"`
@@ -772,8 +2242,8 @@ type Caller struct {
 }
 
 func (f Formatter) caller() Caller {
-	// +1 for this frame, +1 for Info/Error.
-	pc, file, line, ok := runtime.Caller(f.depth + 2)
+	// +1 for this frame, +1 for format, +1 for Info/Error.
+	pc, file, line, ok := runtime.Caller(f.depth + 3)
 	if !ok {
 		return Caller{"<unknown>", 0, ""}
 	}
@@ -781,10 +2251,114 @@ func (f Formatter) caller() Caller {
 	if f.opts.LogCallerFunc {
 		if fp := runtime.FuncForPC(pc); fp != nil {
 			fn = fp.Name()
+			if f.opts.LogCallerShortFunc {
+				fn = fn[strings.LastIndexByte(fn, '/')+1:]
+			}
+		}
+	}
+
+	return Caller{f.callerFile(file, pc), line, fn}
+}
+
+// callerFile formats the path of a calling file according to
+// Options.CallerPath.
+func (f Formatter) callerFile(file string, pc uintptr) string {
+	switch f.opts.CallerPath {
+	case CallerPathFull:
+		return strings.TrimPrefix(file, f.opts.CallerTrimPrefix)
+	case CallerPathPackage:
+		return lastPathElems(file, 2)
+	case CallerPathModule:
+		if rel, ok := moduleFileCache.Load(file); ok {
+			return rel.(string) //nolint:forcetypeassert // only strings are stored
+		}
+		rel := moduleFile(file, pc)
+		moduleFileCache.Store(file, rel)
+		return rel
+	}
+	return filepath.Base(file)
+}
+
+// lastPathElems returns the last n elements of a slash-separated path, as
+// reported by runtime.Caller on all platforms.
+func lastPathElems(p string, n int) string {
+	i := len(p)
+	for ; n > 0 && i > 0; n-- {
+		i = strings.LastIndexByte(p[:i], '/')
+	}
+	return p[i+1:]
+}
+
+// moduleFileCache holds the result of moduleFile for each file seen so far.
+var moduleFileCache sync.Map
+
+// moduleFile determines the path of a file relative to the root of the
+// module which contains it (see CallerPathModule).  pc is used to find the
+// file's package.
+func moduleFile(file string, pc uintptr) string {
+	base := lastPathElems(file, 1)
+	fp := runtime.FuncForPC(pc)
+	if fp == nil {
+		return base
+	}
+	// Function names are the package's import path, with any dots in the
+	// last element escaped, followed by a dot and the function's name.
+	name := fp.Name()
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot < 0 {
+		return base
+	}
+	pkg := strings.ReplaceAll(name[:slash+1+dot], "%2e", ".")
+
+	mods := getModules()
+	if pkg == "main" {
+		pkg = mods.mainPkg
+	}
+	for _, mod := range mods.paths {
+		if pkg == mod || strings.HasPrefix(pkg, mod+"/") {
+			// The file's directory has as many elements as the package's
+			// path within the module.  Using the file's own directory
+			// handles external test packages, whose names end in "_test".
+			rel := strings.TrimPrefix(pkg[len(mod):], "/")
+			if rel == "" {
+				return base
+			}
+			return lastPathElems(file, strings.Count(rel, "/")+2)
 		}
 	}
+	return strings.TrimSuffix(pkg, "_test") + "/" + base
+}
+
+// modules describes the modules which were built into this binary.
+type modules struct {
+	mainPkg string   // the import path of the main package
+	paths   []string // module paths, longest first
+}
 
-	return Caller{filepath.Base(file), line, fn}
+var (
+	modulesOnce sync.Once
+	modulesInfo modules
+)
+
+func getModules() *modules {
+	modulesOnce.Do(func() {
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		modulesInfo.mainPkg = bi.Path
+		if bi.Main.Path != "" {
+			modulesInfo.paths = append(modulesInfo.paths, bi.Main.Path)
+		}
+		for _, dep := range bi.Deps {
+			modulesInfo.paths = append(modulesInfo.paths, dep.Path)
+		}
+		sort.Slice(modulesInfo.paths, func(i, j int) bool {
+			return len(modulesInfo.paths[i]) > len(modulesInfo.paths[j])
+		})
+	})
+	return &modulesInfo
 }
 
 const noValue = "<no-value>"
@@ -797,7 +2371,7 @@ func (f Formatter) nonStringKey(v any) string {
 func (f Formatter) snippet(v any) string {
 	const snipLen = 16
 
-	snip := f.pretty(v)
+	snip := f.textFormatter().pretty(v)
 	if len(snip) > snipLen {
 		snip = snip[:snipLen]
 	}
@@ -820,6 +2394,25 @@ func (f Formatter) sanitize(kvList []any) []any {
 	return kvList
 }
 
+// sortKVs returns a copy of a sanitized list of key-value pairs, stably sorted
+// by key.
+func sortKVs(kvList []any) []any {
+	idx := make([]int, 0, len(kvList)/2)
+	for i := 0; i < len(kvList); i += 2 {
+		idx = append(idx, i)
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		ka, _ := kvList[idx[a]].(string) // sanitize() means no need to check success
+		kb, _ := kvList[idx[b]].(string)
+		return ka < kb
+	})
+	sorted := make([]any, 0, len(kvList))
+	for _, i := range idx {
+		sorted = append(sorted, kvList[i], kvList[i+1])
+	}
+	return sorted
+}
+
 // startGroup opens a new group scope (basically a sub-struct), which locks all
 // the current saved values and starts them anew.  This is needed to satisfy
 // slog.
@@ -830,11 +2423,12 @@ func (f *Formatter) startGroup(name string) {
 	}
 
 	n := len(f.groups)
-	f.groups = append(f.groups[:n:n], groupDef{f.groupName, f.valuesStr})
+	f.groups = append(f.groups[:n:n], groupDef{f.groupName, f.valuesStr, f.valuesKVs})
 
 	// Start collecting new values.
 	f.groupName = name
 	f.valuesStr = ""
+	f.valuesKVs = nil
 	f.values = nil
 }
 
@@ -858,50 +2452,71 @@ func (f Formatter) GetDepth() int {
 
 // FormatInfo renders an Info log message into strings.  The prefix will be
 // empty when no names were set (via AddNames), or when the output is
-// configured for JSON.
+// configured for JSON or CBOR.
 func (f Formatter) FormatInfo(level int, msg string, kvList []any) (prefix, argsStr string) {
-	args := make([]any, 0, 64) // using a constant here impacts perf
-	prefix = f.prefix
-	if f.outputFormat == outputJSON {
-		args = append(args, "logger", prefix)
-		prefix = ""
-	}
-	if f.opts.LogTimestamp {
-		args = append(args, "ts", time.Now().Format(f.opts.TimestampFormat))
-	}
-	if policy := f.opts.LogCaller; policy == All || policy == Info {
-		args = append(args, "caller", f.caller())
-	}
-	if key := *f.opts.LogInfoLevel; key != "" {
-		args = append(args, key, level)
-	}
-	args = append(args, "msg", msg)
-	return prefix, f.render(args, kvList)
+	bufp := getBuffer()
+	defer putBuffer(bufp)
+	prefix, *bufp = f.format(*bufp, false, level, msg, nil, kvList)
+	return prefix, string(*bufp)
+}
+
+// FormatInfoTo is like FormatInfo, except that it appends the rendered log
+// line to buf and returns the extended buffer.  This allows callers to reuse
+// buffers and avoid allocations.
+func (f Formatter) FormatInfoTo(buf []byte, level int, msg string, kvList []any) (prefix string, out []byte) {
+	return f.format(buf, false, level, msg, nil, kvList)
 }
 
 // FormatError renders an Error log message into strings.  The prefix will be
 // empty when no names were set (via AddNames), or when the output is
-// configured for JSON.
+// configured for JSON or CBOR.
 func (f Formatter) FormatError(err error, msg string, kvList []any) (prefix, argsStr string) {
-	args := make([]any, 0, 64) // using a constant here impacts perf
-	prefix = f.prefix
-	if f.outputFormat == outputJSON {
-		args = append(args, "logger", prefix)
+	bufp := getBuffer()
+	defer putBuffer(bufp)
+	prefix, *bufp = f.format(*bufp, true, 0, msg, err, kvList)
+	return prefix, string(*bufp)
+}
+
+// FormatErrorTo is like FormatError, except that it appends the rendered log
+// line to buf and returns the extended buffer.  This allows callers to reuse
+// buffers and avoid allocations.
+func (f Formatter) FormatErrorTo(buf []byte, err error, msg string, kvList []any) (prefix string, out []byte) {
+	return f.format(buf, true, 0, msg, err, kvList)
+}
+
+// format renders an Info (isError is false) or Error log message into buf.
+// It must be called directly from the exported Format methods, so that the
+// caller can be found.
+func (f Formatter) format(buf []byte, isError bool, level int, msg string, err error, kvList []any) (string, []byte) {
+	var list [maxBuiltins]Builtin
+	builtins := f.builtins(list[:0], isError)
+
+	rec := record{
+		logger: f.prefix,
+		level:  level,
+		msg:    msg,
+		err:    err,
+	}
+	prefix := f.prefix
+	if f.outputFormat != outputKeyValue {
 		prefix = ""
 	}
-	if f.opts.LogTimestamp {
-		args = append(args, "ts", time.Now().Format(f.opts.TimestampFormat))
+	if f.opts.LogGoroutineID {
+		rec.goroutine = goroutineID()
 	}
-	if policy := f.opts.LogCaller; policy == All || policy == Error {
-		args = append(args, "caller", f.caller())
+	if f.opts.LogSequence {
+		rec.seq = atomic.AddUint64(&sequence, 1)
 	}
-	args = append(args, "msg", msg)
-	var loggableErr any
-	if err != nil {
-		loggableErr = err.Error()
+	if f.opts.LogTimestamp {
+		rec.ts = f.opts.Clock()
+		if f.opts.TimestampUTC {
+			rec.ts = rec.ts.UTC()
+		}
+	}
+	if policy := f.opts.LogCaller; policy == All || (isError && policy == Error) || (!isError && policy == Info) {
+		rec.caller = f.caller()
 	}
-	args = append(args, "error", loggableErr)
-	return prefix, f.render(args, kvList)
+	return prefix, f.appendRecord(buf, builtins, &rec, kvList)
 }
 
 // AddName appends the specified name.  funcr uses '/' characters to separate
@@ -911,6 +2526,10 @@ func (f *Formatter) AddName(name string) {
 	if len(f.prefix) > 0 {
 		f.prefix += "/"
 	}
+	if f.safeText() {
+		f.prefix = string(appendEscapedName([]byte(f.prefix), name))
+		return
+	}
 	f.prefix += name
 }
 
@@ -925,11 +2544,26 @@ func (f *Formatter) AddValues(kvList []any) {
 	if hook := f.opts.RenderValuesHook; hook != nil {
 		vals = hook(f.sanitize(vals))
 	}
+	if f.opts.SortKeys {
+		vals = sortKVs(f.sanitize(vals))
+	}
 
 	// Pre-render values, so we don't have to do it on each Info/Error call.
-	buf := bytes.NewBuffer(make([]byte, 0, 1024))
-	f.flatten(buf, vals, true) // escape user-provided keys
-	f.valuesStr = buf.String()
+	bufp := getBuffer()
+	defer putBuffer(bufp)
+	*bufp = f.flatten(*bufp, vals, true) // escape user-provided keys
+	f.valuesStr = string(*bufp)
+
+	// Duplicate keys can only be resolved at render time, so also keep
+	// each value separately.
+	if f.opts.DuplicateKeys != AllowDuplicateKeys {
+		entries := f.appendEntries(nil, vals, true)
+		f.valuesKVs = make([]renderedKV, 0, len(entries))
+		for _, e := range entries {
+			*bufp = f.appendPretty((*bufp)[:0], e.value, 0, 0, 0, nil)
+			f.valuesKVs = append(f.valuesKVs, renderedKV{e.key, string(*bufp)})
+		}
+	}
 }
 
 // AddCallDepth increases the number of stack-frames to skip when attributing
@@ -0,0 +1,160 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package funcr
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func FuzzStrictJSON(f *testing.F) {
+	f.Add("key", "value", 1.5, []byte(`{"a":1}`), int64(1))
+	f.Add("", "", math.NaN(), []byte(nil), int64(0))
+	f.Add("\"\\", "\x00\x1b[31m\n\r\t ", math.Inf(1), []byte("not json"), int64(-1))
+	f.Add("\xff", "\xc3\x28 \xed\xa0\x80", math.Inf(-1), []byte("[1,\n2]"), int64(math.MaxInt64))
+
+	fmtr := NewFormatterJSON(Options{
+		StrictJSON:         true,
+		LogCaller:          All,
+		LogCallerFunc:      true,
+		LogTimestamp:       true,
+		MaxStringLength:    64,
+		MaxCollectionItems: 4,
+	})
+	fmtr.AddName("name")
+	fmtr.AddValues([]any{"saved", 1})
+	fmtr.startGroup("group")
+
+	f.Fuzz(func(t *testing.T, key, str string, fl float64, raw []byte, n int64) {
+		fmtr := fmtr // a copy, so values don't accumulate
+		fmtr.AddName(key)
+		fmtr.AddValues([]any{key, str})
+		kvList := []any{
+			key, str,
+			str, key,
+			"float", fl,
+			"float32", float32(fl),
+			"complex", complex(fl, fl),
+			"raw", json.RawMessage(raw),
+			"bytes", raw,
+			"int", n,
+			"map", map[string]float64{key: fl, str: fl},
+			"intmap", map[int64]string{n: str},
+			"struct", struct {
+				S string
+				F float64
+				P PseudoStruct
+			}{str, fl, PseudoStruct{key, str}},
+			n, // non-string key, missing value
+		}
+
+		_, line := fmtr.FormatInfo(int(n&0x7f), str, kvList)
+		checkJSON(t, line, str)
+		_, line = fmtr.FormatError(errString(str), str, kvList)
+		checkJSON(t, line, str)
+	})
+}
+
+type errString string
+
+func (e errString) Error() string { return string(e) }
+
+// checkJSON verifies that line is valid JSON and that it has the expected
+// message, as encoding/json would represent it.
+func checkJSON(t *testing.T, line string, msg string) {
+	t.Helper()
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(line), &obj); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, line)
+	}
+	if len(msg) > 64 {
+		return // truncated
+	}
+	want, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("failed to marshal %q: %v", msg, err)
+	}
+	var wantMsg string
+	if err := json.Unmarshal(want, &wantMsg); err != nil {
+		t.Fatalf("failed to unmarshal %s: %v", want, err)
+	}
+	if got := obj["msg"]; got != wantMsg {
+		t.Errorf("wrong msg: expected %q, got %q\n%s", wantMsg, got, line)
+	}
+}
+
+// maxFuzzInput limits the size of the inputs of the decoding fuzz tests.
+// Larger inputs find nothing new, but make each run slow.
+const maxFuzzInput = 1 << 10
+
+func FuzzCBORToJSON(f *testing.F) {
+	_, rec := NewFormatterCBOR(Options{LogCaller: All}).FormatInfo(0, "msg", []any{
+		"int", -1, "float", 1.5, "map", map[int]string{1: "a"}, "nil", nil,
+	})
+	f.Add([]byte(rec))
+	f.Add([]byte("\x5f\x42hi\xff\xc1\xf9\x7c\x00"))
+	f.Add([]byte("\x9f\x9f\xff"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) > maxFuzzInput {
+			return
+		}
+		js, n, err := CBORToJSON(data)
+		if err != nil {
+			return
+		}
+		if n <= 0 || n > len(data) {
+			t.Fatalf("used %d bytes of %d", n, len(data))
+		}
+		if !json.Valid([]byte(js)) {
+			t.Fatalf("invalid JSON: %s", js)
+		}
+	})
+}
+
+func FuzzParser(f *testing.F) {
+	fmtr := NewFormatter(Options{LogTimestamp: true})
+	fmtr.AddName("name")
+	prefix, args := fmtr.FormatInfo(1, "msg", []any{"k", []any{1, "v", map[string]float64{"f": 1.5}}})
+	f.Add(prefix + " " + args)
+	_, args = NewFormatterJSON(Options{}).FormatError(errString("e"), "msg", []any{"raw", json.RawMessage("[1,\n2]")})
+	f.Add(args)
+	f.Add(`"k"=[NaN -Inf {"\xff"=null}]`)
+
+	opts := Options{LogTimestamp: true}
+	f.Fuzz(func(t *testing.T, input string) {
+		if len(input) > maxFuzzInput {
+			return
+		}
+		p := NewParser(strings.NewReader(input), opts)
+		for p.Next() {
+			// Whatever was parsed should render and parse back the same.
+			rec := p.Record()
+			_, line := NewFormatterJSON(Options{}).FormatInfo(0, "", rec.Values)
+			again := NewParser(strings.NewReader(line), Options{})
+			if !again.Next() {
+				t.Fatalf("failed to parse %s: %v", line, again.Err())
+			}
+			_, line2 := NewFormatterJSON(Options{}).FormatInfo(0, "", again.Record().Values)
+			if line2 != line {
+				t.Fatalf("\nexpected %s\n     got %s", line, line2)
+			}
+		}
+	})
+}
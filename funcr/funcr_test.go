@@ -17,12 +17,22 @@ limitations under the License.
 package funcr
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 )
@@ -105,6 +115,51 @@ func (t Terrorpanic) Error() string {
 	panic("Terrorpanic")
 }
 
+// Logging this with UseEncodingMarshalers should result in the MarshalJSON()
+// value, compacted.
+type Tjsonmarshaler struct{ val string }
+
+func (t Tjsonmarshaler) MarshalJSON() ([]byte, error) {
+	return []byte("{\n  \"json\": [1, 2]\n}"), nil
+}
+
+func (t Tjsonmarshaler) String() string {
+	return "String(): you should not see this"
+}
+
+// Logging this with UseEncodingMarshalers should result in an error.
+type Tjsonmarshalererr struct{ val string }
+
+func (t Tjsonmarshalererr) MarshalJSON() ([]byte, error) {
+	return nil, fmt.Errorf("uh oh")
+}
+
+// Logging this with UseEncodingMarshalers should result in an error.
+type Tjsonmarshalerinvalid struct{ val string }
+
+func (t Tjsonmarshalerinvalid) MarshalJSON() ([]byte, error) {
+	return []byte("{not json"), nil
+}
+
+// Logging this with UseEncodingMarshalers should result in a panic.
+type Tjsonmarshalerpanic struct{ val string }
+
+func (t Tjsonmarshalerpanic) MarshalJSON() ([]byte, error) {
+	panic("Tjsonmarshalerpanic")
+}
+
+// Logging this with UseEncodingMarshalers should result in the MarshalText()
+// value.
+type Ttextmarshaler struct{ val string }
+
+func (t Ttextmarshaler) MarshalText() ([]byte, error) {
+	return []byte("I am a TextMarshaler"), nil
+}
+
+func (t Ttextmarshaler) String() string {
+	return "String(): you should not see this"
+}
+
 type TjsontagsString struct {
 	String0 string `json:"-"`       // first field ignored
 	String1 string `json:"string1"` // renamed
@@ -1002,23 +1057,69 @@ func TestRender(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			test := func(t *testing.T, formatter Formatter, expect string) {
+			test := func(t *testing.T, newFormatter func(Options) Formatter, expect string) {
+				// The hook replaces a builtin with arbitrary ones.
+				var opts Options
+				var list []Builtin
+				if len(tc.builtins) > 0 {
+					opts.RenderBuiltinsHook = func([]any) []any { return tc.builtins }
+					list = []Builtin{BuiltinMessage}
+				}
+				formatter := newFormatter(opts)
 				formatter.AddValues(tc.values)
-				r := formatter.render(tc.builtins, tc.args)
+				r := string(formatter.appendFullRecord(nil, list, &record{}, tc.args))
 				if r != expect {
 					t.Errorf("wrong output:\nexpected %q\n     got %q", expect, r)
 				}
 			}
 			t.Run("KV", func(t *testing.T) {
-				test(t, NewFormatter(Options{}), tc.expectKV)
+				test(t, NewFormatter, tc.expectKV)
 			})
 			t.Run("JSON", func(t *testing.T) {
-				test(t, NewFormatterJSON(Options{}), tc.expectJSON)
+				test(t, NewFormatterJSON, tc.expectJSON)
 			})
 		})
 	}
 }
 
+func TestRawMessage(t *testing.T) {
+	// Since Go 1.27, json.RawMessage has a String method, but it must still
+	// be rendered as in Go 1.26 and before: as JSON, or as bytes in
+	// key-value mode.
+	raw := json.RawMessage(`{"a": [1, "x"]}`)
+	testCases := []struct {
+		name       string
+		val        any
+		expectKV   string
+		expectJSON string
+	}{{
+		name:       "plain",
+		val:        raw,
+		expectKV:   `[123 34 97 34 58 32 91 49 44 32 34 120 34 93 125]`,
+		expectJSON: `{"a": [1, "x"]}`,
+	}, {
+		name:       "in a map",
+		val:        map[string]json.RawMessage{"k": raw},
+		expectKV:   `{"k"=[123 34 97 34 58 32 91 49 44 32 34 120 34 93 125]}`,
+		expectJSON: `{"k":{"a": [1, "x"]}}`,
+	}, {
+		name:       "in a slice",
+		val:        []any{raw},
+		expectKV:   `[[123 34 97 34 58 32 91 49 44 32 34 120 34 93 125]]`,
+		expectJSON: `[{"a": [1, "x"]}]`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NewFormatter(Options{}).pretty(tc.val); got != tc.expectKV {
+				t.Errorf("KV:\nexpected %q\n     got %q", tc.expectKV, got)
+			}
+			if got := NewFormatterJSON(Options{}).pretty(tc.val); got != tc.expectJSON {
+				t.Errorf("JSON:\nexpected %q\n     got %q", tc.expectJSON, got)
+			}
+		})
+	}
+}
+
 func TestSanitize(t *testing.T) {
 	testCases := []struct {
 		name   string
@@ -1620,3 +1721,1796 @@ func TestOptionsLogInfoLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestOptionsSortKeys(t *testing.T) {
+	testCases := []struct {
+		name       string
+		values     []any
+		args       []any
+		expectKV   string
+		expectJSON string
+	}{{
+		name:       "string keys",
+		args:       makeKV("m", map[string]int{"c": 3, "a": 1, "b": 2}),
+		expectKV:   `"level"=0 "msg"="msg" "m"={"a"=1 "b"=2 "c"=3}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","m":{"a":1,"b":2,"c":3}}`,
+	}, {
+		name:       "int keys",
+		args:       makeKV("m", map[int]string{10: "ten", 9: "nine", 100: "hundred"}),
+		expectKV:   `"level"=0 "msg"="msg" "m"={"9"="nine" "10"="ten" "100"="hundred"}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","m":{"9":"nine","10":"ten","100":"hundred"}}`,
+	}, {
+		name:       "negative int keys",
+		args:       makeKV("m", map[int8]int{-10: 1, 2: 2, -2: 3}),
+		expectKV:   `"level"=0 "msg"="msg" "m"={"-10"=1 "-2"=3 "2"=2}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","m":{"-10":1,"-2":3,"2":2}}`,
+	}, {
+		name:       "uint keys",
+		args:       makeKV("m", map[uint64]int{1 << 63: 1, 20: 2, 3: 3}),
+		expectKV:   `"level"=0 "msg"="msg" "m"={"3"=3 "20"=2 "9223372036854775808"=1}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","m":{"3":3,"20":2,"9223372036854775808":1}}`,
+	}, {
+		name:       "float keys",
+		args:       makeKV("m", map[float64]int{10.5: 1, 9.25: 2, -1: 3}),
+		expectKV:   `"level"=0 "msg"="msg" "m"={"-1"=3 "9.25"=2 "10.5"=1}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","m":{"-1":3,"9.25":2,"10.5":1}}`,
+	}, {
+		name:       "TextMarshaler keys",
+		args:       makeKV("m", map[point]int{{2, 1}: 21, {1, 2}: 12}),
+		expectKV:   `"level"=0 "msg"="msg" "m"={"(1, 2)"=12 "(2, 1)"=21}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","m":{"(1, 2)":12,"(2, 1)":21}}`,
+	}, {
+		name:       "nested maps",
+		args:       makeKV("m", map[string]map[string]int{"y": {"b": 2, "a": 1}, "x": {"d": 4, "c": 3}}),
+		expectKV:   `"level"=0 "msg"="msg" "m"={"x"={"c"=3 "d"=4} "y"={"a"=1 "b"=2}}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","m":{"x":{"c":3,"d":4},"y":{"a":1,"b":2}}}`,
+	}, {
+		name:       "saved values",
+		values:     makeKV("zz", 1, "aa", 2, "mm", 3),
+		args:       makeKV("k", "v"),
+		expectKV:   `"level"=0 "msg"="msg" "aa"=2 "mm"=3 "zz"=1 "k"="v"`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","aa":2,"mm":3,"zz":1,"k":"v"}`,
+	}, {
+		name:       "saved values with duplicates",
+		values:     makeKV("b", 1, "a", 2, "b", 3, 42, 4),
+		expectKV:   `"level"=0 "msg"="msg" "<non-string-key: 42>"=4 "a"=2 "b"=1 "b"=3`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","<non-string-key: 42>":4,"a":2,"b":1,"b":3}`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run("KV: "+tc.name, func(t *testing.T) {
+			capt := &capture{}
+			sink := newSink(capt.Func, NewFormatter(Options{SortKeys: true}))
+			if len(tc.values) > 0 {
+				sink = sink.WithValues(tc.values...)
+			}
+			sink.Info(0, "msg", tc.args...)
+			if capt.log != tc.expectKV {
+				t.Errorf("\nexpected %q\n     got %q", tc.expectKV, capt.log)
+			}
+		})
+		t.Run("JSON: "+tc.name, func(t *testing.T) {
+			capt := &capture{}
+			sink := newSink(capt.Func, NewFormatterJSON(Options{SortKeys: true}))
+			if len(tc.values) > 0 {
+				sink = sink.WithValues(tc.values...)
+			}
+			sink.Info(0, "msg", tc.args...)
+			if capt.log != tc.expectJSON {
+				t.Errorf("\nexpected %q\n     got %q", tc.expectJSON, capt.log)
+			}
+		})
+	}
+}
+
+func TestFormatTo(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		f    Formatter
+	}{
+		{"KV", NewFormatter(Options{LogTimestamp: true, TimestampFormat: "TIMESTAMP"})},
+		{"JSON", NewFormatterJSON(Options{LogTimestamp: true, TimestampFormat: "TIMESTAMP"})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := tc.f
+			f.AddName("name")
+			f.AddValues(makeKV("saved", 1))
+			args := makeKV("int", 1, "str", "ABC", "struct", struct{ X, Y int }{1, 2})
+
+			buf := []byte("existing;")
+			prefix, out := f.FormatInfoTo(buf, 0, "msg", args)
+			expectPrefix, expectArgs := f.FormatInfo(0, "msg", args)
+			if prefix != expectPrefix {
+				t.Errorf("wrong info prefix:\nexpected %q\n     got %q", expectPrefix, prefix)
+			}
+			if string(out) != "existing;"+expectArgs {
+				t.Errorf("wrong info output:\nexpected %q\n     got %q", "existing;"+expectArgs, out)
+			}
+
+			err := fmt.Errorf("err")
+			prefix, out = f.FormatErrorTo(nil, err, "msg", args)
+			expectPrefix, expectArgs = f.FormatError(err, "msg", args)
+			if prefix != expectPrefix {
+				t.Errorf("wrong error prefix:\nexpected %q\n     got %q", expectPrefix, prefix)
+			}
+			if string(out) != expectArgs {
+				t.Errorf("wrong error output:\nexpected %q\n     got %q", expectArgs, out)
+			}
+		})
+	}
+}
+
+func TestFormatToAllocs(t *testing.T) {
+	f := NewFormatterJSON(Options{})
+	f.AddName("name")
+	f.AddValues(makeKV("saved", 1))
+	args := makeKV("int", 12345, "str", "ABC", "bool", true, "float", 3.14)
+	err := fmt.Errorf("err")
+	buf := make([]byte, 0, 1024)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, buf = f.FormatInfoTo(buf[:0], 0, "msg", args)
+		_, buf = f.FormatErrorTo(buf[:0], err, "msg", args)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+type Tplaininner struct {
+	S  string
+	N  int
+	F  float32
+	St Tstringer `json:"st"`
+}
+
+type Tplainouter struct {
+	Tplaininner
+	Ptr    *Tplaininner  `json:"ptr,omitempty"`
+	Slice  []Tplaininner `json:"slice"`
+	Map    map[int]uint8 `json:"map"`
+	Pseudo PseudoStruct  `json:"pseudo"`
+	Any    any           `json:"any"`
+}
+
+func TestPrettyTypeCache(t *testing.T) {
+	inner := Tplaininner{S: "str", N: -1, F: 1.5}
+	cases := []struct {
+		val      any
+		maxDepth int
+		exp      string
+	}{{
+		val: inner,
+		exp: `{"S":"str","N":-1,"F":1.5,"st":"I am a fmt.Stringer"}`,
+	}, {
+		val: Tplainouter{
+			Tplaininner: inner,
+			Slice:       []Tplaininner{inner},
+			Map:         map[int]uint8{7: 6},
+			Pseudo:      PseudoStruct{"k", "v"},
+			Any:         inner,
+		},
+		exp: `{"S":"str","N":-1,"F":1.5,"st":"I am a fmt.Stringer",` +
+			`"slice":[{"S":"str","N":-1,"F":1.5,"st":"I am a fmt.Stringer"}],` +
+			`"map":{"7":6},"pseudo":{"k":"v"},` +
+			`"any":{"S":"str","N":-1,"F":1.5,"st":"I am a fmt.Stringer"}}`,
+	}, {
+		val: Tplainouter{Ptr: &inner},
+		exp: `{"S":"","N":0,"F":0,"st":"I am a fmt.Stringer",` +
+			`"ptr":{"S":"str","N":-1,"F":1.5,"st":"I am a fmt.Stringer"},` +
+			`"slice":[],"map":{},"pseudo":{},"any":null}`,
+	}, {
+		val:      Tplainouter{Slice: []Tplaininner{inner}, Map: map[int]uint8{1: 2}},
+		maxDepth: 1,
+		exp: `{"S":"<max-log-depth-exceeded>","N":"<max-log-depth-exceeded>","F":"<max-log-depth-exceeded>","st":"<max-log-depth-exceeded>",` +
+			`"slice":["<max-log-depth-exceeded>"],"map":{"1":"<max-log-depth-exceeded>"},"pseudo":{},"any":null}`,
+	}, {
+		val: map[substr][]substr{"k": {"a", "b"}},
+		exp: `{"k":["a","b"]}`,
+	}, {
+		val: map[bool]float64{true: 0.5},
+		exp: `{"true":0.5}`,
+	}}
+
+	for i, tc := range cases {
+		f := NewFormatterJSON(Options{MaxLogDepth: tc.maxDepth})
+		// The first pass fills the type cache, the second uses it.
+		for pass := 0; pass < 2; pass++ {
+			if got := f.pretty(tc.val); got != tc.exp {
+				t.Errorf("case %d, pass %d:\n\texpected %q\n\tgot      %q", i, pass, tc.exp, got)
+			}
+		}
+	}
+}
+
+func TestOptionsTypeEncoders(t *testing.T) {
+	ts := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	opts := Options{
+		TypeEncoders: []TypeEncoder{{
+			Type:   reflect.TypeOf(time.Time{}),
+			Encode: func(v any) any { return v.(time.Time).Format(time.RFC3339) }, //nolint:forcetypeassert
+		}, {
+			Type:   reflect.TypeOf(time.Duration(0)),
+			Encode: func(v any) any { return v.(time.Duration).String() }, //nolint:forcetypeassert
+		}, {
+			Type:   reflect.TypeOf([]byte(nil)),
+			Encode: func(v any) any { return base64.StdEncoding.EncodeToString(v.([]byte)) }, //nolint:forcetypeassert
+		}, {
+			Type:   reflect.TypeOf(substr("")),
+			Encode: func(_ any) any { panic("substr") },
+		}, {
+			// Matches *big.Int and anything else with a Text(int) method.
+			Match: func(t reflect.Type) bool {
+				m, ok := t.MethodByName("Text")
+				return ok && m.Type.NumIn() == 2 && m.Type.In(1).Kind() == reflect.Int
+			},
+			Encode: func(v any) any {
+				return reflect.ValueOf(v).MethodByName("Text").Call([]reflect.Value{reflect.ValueOf(10)})[0].Interface()
+			},
+		}},
+	}
+
+	type T struct {
+		Time  time.Time
+		Bytes []byte
+		Durs  []time.Duration
+	}
+	testCases := []struct {
+		name   string
+		val    any
+		expect string
+	}{{
+		name:   "time",
+		val:    ts,
+		expect: `"2006-01-02T15:04:05Z"`,
+	}, {
+		name:   "duration",
+		val:    1500 * time.Millisecond,
+		expect: `"1.5s"`,
+	}, {
+		name:   "pointer",
+		val:    &ts,
+		expect: `"2006-01-02T15:04:05Z"`,
+	}, {
+		name:   "nil pointer",
+		val:    (*time.Time)(nil),
+		expect: `null`,
+	}, {
+		name:   "bytes",
+		val:    []byte("hello"),
+		expect: `"aGVsbG8="`,
+	}, {
+		name:   "predicate",
+		val:    big.NewInt(1).Lsh(big.NewInt(1), 70),
+		expect: `"1180591620717411303424"`,
+	}, {
+		name:   "panic",
+		val:    substr("x"),
+		expect: `"<panic: substr>"`,
+	}, {
+		name:   "nested",
+		val:    T{Time: ts, Bytes: []byte{0xff}, Durs: []time.Duration{time.Second, time.Minute}},
+		expect: `{"Time":"2006-01-02T15:04:05Z","Bytes":"/w==","Durs":["1s","1m0s"]}`,
+	}, {
+		name:   "map values",
+		val:    map[string][]byte{"k": []byte("v")},
+		expect: `{"k":"dg=="}`,
+	}, {
+		name:   "pseudo struct",
+		val:    PseudoStruct(makeKV("d", time.Hour)),
+		expect: `{"d":"1h0m0s"}`,
+	}, {
+		name:   "no encoder",
+		val:    []int{1, 2},
+		expect: `[1,2]`,
+	}}
+
+	f := NewFormatterJSON(opts)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := f.pretty(tc.val); got != tc.expect {
+				t.Errorf("\nexpected %q\n     got %q", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestOptionsUseEncodingMarshalers(t *testing.T) {
+	ts := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	type T struct {
+		J Tjsonmarshaler
+		T Ttextmarshaler `json:"text"`
+		P *Ttextmarshaler
+	}
+	testCases := []struct {
+		name     string
+		val      any
+		expect   string // empty for invalid JSON
+		expectKV string // if different from JSON
+	}{{
+		name:     "json.Marshaler",
+		val:      Tjsonmarshaler{},
+		expect:   `{"json":[1,2]}`,
+		expectKV: `"{\"json\":[1,2]}"`,
+	}, {
+		name:   "json.Marshaler error",
+		val:    Tjsonmarshalererr{},
+		expect: `"<error-MarshalJSON: uh oh>"`,
+	}, {
+		name: "json.Marshaler invalid",
+		val:  Tjsonmarshalerinvalid{},
+	}, {
+		name:   "json.Marshaler panic",
+		val:    Tjsonmarshalerpanic{},
+		expect: `"<panic: Tjsonmarshalerpanic>"`,
+	}, {
+		name:   "encoding.TextMarshaler",
+		val:    Ttextmarshaler{},
+		expect: `"I am a TextMarshaler"`,
+	}, {
+		name:   "encoding.TextMarshaler error",
+		val:    pointErr{1, 2},
+		expect: `"<error-MarshalText: uh oh: 1, 2>"`,
+	}, {
+		name:     "logr.Marshaler wins",
+		val:      Tmarshaler{},
+		expect:   `{"Inner":"I am a logr.Marshaler"}`,
+		expectKV: `{"Inner"="I am a logr.Marshaler"}`,
+	}, {
+		name:   "time",
+		val:    ts,
+		expect: `"2006-01-02T15:04:05Z"`,
+	}, {
+		name:     "raw JSON",
+		val:      json.RawMessage(`{"a":1}`),
+		expect:   `{"a":1}`,
+		expectKV: `[123 34 97 34 58 49 125]`,
+	}, {
+		name:     "nested",
+		val:      T{P: &Ttextmarshaler{}},
+		expect:   `{"J":{"json":[1,2]},"text":"I am a TextMarshaler","P":"I am a TextMarshaler"}`,
+		expectKV: `{"J"="{\"json\":[1,2]}" "text"="I am a TextMarshaler" "P"="I am a TextMarshaler"}`,
+	}, {
+		name:   "slice",
+		val:    []Ttextmarshaler{{}},
+		expect: `["I am a TextMarshaler"]`,
+	}, {
+		name:     "map",
+		val:      map[point]Tjsonmarshaler{{1, 2}: {}},
+		expect:   `{"(1, 2)":{"json":[1,2]}}`,
+		expectKV: `{"(1, 2)"="{\"json\":[1,2]}"}`,
+	}}
+
+	fKV := NewFormatter(Options{UseEncodingMarshalers: true})
+	fJSON := NewFormatterJSON(Options{UseEncodingMarshalers: true})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.expect == "" {
+				// Just check that it's a string describing the problem.
+				got := fJSON.pretty(tc.val)
+				if !strings.HasPrefix(got, `"<invalid-MarshalJSON: `) {
+					t.Errorf("expected an invalid-MarshalJSON string, got %q", got)
+				}
+				return
+			}
+			if got := fJSON.pretty(tc.val); got != tc.expect {
+				t.Errorf("JSON:\nexpected %q\n     got %q", tc.expect, got)
+			}
+			want := tc.expect
+			if tc.expectKV != "" {
+				want = tc.expectKV
+			}
+			if got := fKV.pretty(tc.val); got != want {
+				t.Errorf("KV:\nexpected %q\n     got %q", want, got)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		f := NewFormatterJSON(Options{})
+		if got, want := f.pretty(Tjsonmarshaler{}), `"String(): you should not see this"`; got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+}
+
+func TestOptionsLimits(t *testing.T) {
+	testCases := []struct {
+		name       string
+		opts       Options
+		values     []any
+		msg        string
+		args       []any
+		expectKV   string
+		expectJSON string
+	}{{
+		name:       "string under limit",
+		opts:       Options{MaxStringLength: 5},
+		args:       makeKV("s", "12345"),
+		expectKV:   `"level"=0 "msg"="msg" "s"="12345"`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","s":"12345"}`,
+	}, {
+		name:       "string over limit",
+		opts:       Options{MaxStringLength: 5},
+		msg:        "a long message",
+		args:       makeKV("s", "123456789", "long key", Tstringer{}),
+		expectKV:   `"level"=0 "msg"="a lon…(+9 bytes)" "s"="12345…(+4 bytes)" "long key"="I am …(+14 bytes)"`,
+		expectJSON: `{"logger":"","level":0,"msg":"a lon…(+9 bytes)","s":"12345…(+4 bytes)","long key":"I am …(+14 bytes)"}`,
+	}, {
+		name:       "string at rune boundary",
+		opts:       Options{MaxStringLength: 5},
+		args:       makeKV("s", "1234ü6"),
+		expectKV:   `"level"=0 "msg"="msg" "s"="1234…(+3 bytes)"`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","s":"1234…(+3 bytes)"}`,
+	}, {
+		name:       "string with escapes",
+		opts:       Options{MaxStringLength: 3},
+		args:       makeKV("s", "\"\n\"\n"),
+		expectKV:   `"level"=0 "msg"="msg" "s"="\"\n\"…(+1 bytes)"`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","s":"\"\n\"…(+1 bytes)"}`,
+	}, {
+		name:       "map keys are not truncated",
+		opts:       Options{MaxStringLength: 3},
+		args:       makeKV("m", map[string]string{"abcdef": "abcdef"}),
+		expectKV:   `"level"=0 "msg"="msg" "m"={"abcdef"="abc…(+3 bytes)"}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","m":{"abcdef":"abc…(+3 bytes)"}}`,
+	}, {
+		name:       "slice",
+		opts:       Options{MaxCollectionItems: 2},
+		args:       makeKV("s", []int{1, 2, 3, 4, 5}, "a", [3]string{"a", "b", "c"}, "short", []int{1, 2}),
+		expectKV:   `"level"=0 "msg"="msg" "s"=[1 2 "<+3 more items>"] "a"=["a" "b" "<+1 more items>"] "short"=[1 2]`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","s":[1,2,"<+3 more items>"],"a":["a","b","<+1 more items>"],"short":[1,2]}`,
+	}, {
+		name:       "map",
+		opts:       Options{MaxCollectionItems: 2, SortKeys: true},
+		args:       makeKV("m", map[string]int{"a": 1, "b": 2, "c": 3, "d": 4}),
+		expectKV:   `"level"=0 "msg"="msg" "m"={"a"=1 "b"=2 "<truncated>"="<+2 more items>"}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","m":{"a":1,"b":2,"<truncated>":"<+2 more items>"}}`,
+	}, {
+		name:       "line under limit",
+		opts:       Options{MaxLineBytes: 100},
+		args:       makeKV("k1", "v1", "k2", "v2"),
+		expectKV:   `"level"=0 "msg"="msg" "k1"="v1" "k2"="v2"`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","k1":"v1","k2":"v2"}`,
+	}, {
+		name:       "line drops args",
+		opts:       Options{MaxLineBytes: 90},
+		values:     makeKV("saved", 1),
+		args:       makeKV("k1", "v1", "k2", strings.Repeat("x", 100), "k3", "v3"),
+		expectKV:   `"level"=0 "msg"="msg" "saved"=1 "k1"="v1" "<truncated>"="<+118 bytes>"`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","saved":1,"k1":"v1","<truncated>":"<+118 bytes>"}`,
+	}, {
+		name:       "line drops values",
+		opts:       Options{MaxLineBytes: 90},
+		values:     makeKV("saved", strings.Repeat("x", 100)),
+		args:       makeKV("k1", "v1"),
+		expectKV:   `"level"=0 "msg"="msg" "<truncated>"="<+121 bytes>"`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","<truncated>":"<+121 bytes>"}`,
+	}}
+
+	for _, tc := range testCases {
+		msg := tc.msg
+		if msg == "" {
+			msg = "msg"
+		}
+		t.Run("KV: "+tc.name, func(t *testing.T) {
+			capt := &capture{}
+			sink := newSink(capt.Func, NewFormatter(tc.opts))
+			if len(tc.values) > 0 {
+				sink = sink.WithValues(tc.values...)
+			}
+			sink.Info(0, msg, tc.args...)
+			if capt.log != tc.expectKV {
+				t.Errorf("\nexpected %q\n     got %q", tc.expectKV, capt.log)
+			}
+		})
+		t.Run("JSON: "+tc.name, func(t *testing.T) {
+			capt := &capture{}
+			sink := newSink(capt.Func, NewFormatterJSON(tc.opts))
+			if len(tc.values) > 0 {
+				sink = sink.WithValues(tc.values...)
+			}
+			sink.Info(0, msg, tc.args...)
+			if capt.log != tc.expectJSON {
+				t.Errorf("\nexpected %q\n     got %q", tc.expectJSON, capt.log)
+			}
+			if !json.Valid([]byte(capt.log)) {
+				t.Errorf("invalid JSON: %q", capt.log)
+			}
+			if max := tc.opts.MaxLineBytes; max > 0 && len(capt.log) > max {
+				t.Errorf("line is %d bytes, expected at most %d", len(capt.log), max)
+			}
+		})
+	}
+}
+
+func TestOptionsStrictJSON(t *testing.T) {
+	testCases := []struct {
+		name   string
+		val    any
+		expect string // with StrictJSON
+		loose  string // without StrictJSON
+	}{{
+		name:   "plain string",
+		val:    "hello, wörld",
+		expect: `"hello, wörld"`,
+		loose:  `"hello, wörld"`,
+	}, {
+		name:   "escapes",
+		val:    "\"\\\n\r\t",
+		expect: `"\"\\\n\r\t"`,
+		loose:  `"\"\\\n\r\t"`,
+	}, {
+		name:   "control characters",
+		val:    "\x00\a\x1b[31m\x7f",
+		expect: `"\u0000\u0007\u001b[31m` + "\x7f" + `"`,
+		loose:  `"\x00\a\x1b[31m\x7f"`,
+	}, {
+		name:   "invalid UTF-8",
+		val:    "a\xffb\xc3",
+		expect: `"a\ufffdb\ufffd"`,
+		loose:  "\"a\xffb\xc3\"",
+	}, {
+		name:   "line separators",
+		val:    "\u2028\u2029",
+		expect: `"\u2028\u2029"`,
+		loose:  `"\u2028\u2029"`,
+	}, {
+		name:   "non-BMP",
+		val:    "\U0001F600",
+		expect: `"` + "\U0001F600" + `"`,
+		loose:  `"` + "\U0001F600" + `"`,
+	}, {
+		name:   "NaN",
+		val:    math.NaN(),
+		expect: `"NaN"`,
+		loose:  `NaN`,
+	}, {
+		name:   "Inf",
+		val:    []float32{float32(math.Inf(1)), float32(math.Inf(-1)), 1.5},
+		expect: `["+Inf","-Inf",1.5]`,
+		loose:  `[+Inf,-Inf,1.5]`,
+	}, {
+		name:   "map keys",
+		val:    map[string]int{"\x00": 1},
+		expect: `{"\u0000":1}`,
+		loose:  `{"\x00":1}`,
+	}, {
+		name:   "raw JSON",
+		val:    json.RawMessage("[1,\n 2]"),
+		expect: `[1,2]`,
+		loose:  "[1,\n 2]",
+	}, {
+		name:   "invalid raw JSON",
+		val:    json.RawMessage("[1,"),
+		expect: `"<invalid-RawMessage: unexpected end of JSON input>"`,
+		loose:  `[1,`,
+	}}
+
+	strict := NewFormatterJSON(Options{StrictJSON: true})
+	loose := NewFormatterJSON(Options{})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := strict.pretty(tc.val)
+			if got != tc.expect {
+				t.Errorf("strict:\nexpected %q\n     got %q", tc.expect, got)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("strict: invalid JSON %q", got)
+			}
+			if got := loose.pretty(tc.val); got != tc.loose {
+				t.Errorf("loose:\nexpected %q\n     got %q", tc.loose, got)
+			}
+		})
+	}
+
+	t.Run("keys", func(t *testing.T) {
+		capt := &capture{}
+		sink := newSink(capt.Func, NewFormatterJSON(Options{StrictJSON: true, LogInfoLevel: ptrstr("lv\x01")}))
+		sink = sink.WithName("n\x02").WithValues("\x03", 1)
+		sink.Info(0, "m\x04", "\x05", struct {
+			F int `json:"f\x06"`
+		}{1})
+		expect := `{"logger":"n\u0002","lv\u0001":0,"msg":"m\u0004","\u0003":1,"\u0005":{"f\u0006":1}}`
+		if capt.log != expect {
+			t.Errorf("\nexpected %q\n     got %q", expect, capt.log)
+		}
+	})
+
+	t.Run("key-value mode", func(t *testing.T) {
+		f := NewFormatter(Options{StrictJSON: true})
+		if got, want := f.pretty(math.NaN()), `NaN`; got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+}
+
+func TestOptionsDuplicateKeys(t *testing.T) {
+	testCases := []struct {
+		name       string
+		policy     DuplicateKeyPolicy
+		values     []any
+		group      string
+		groupVals  []any
+		args       []any
+		expectKV   string
+		expectJSON string
+	}{{
+		name:       "allow",
+		policy:     AllowDuplicateKeys,
+		values:     makeKV("k", 1),
+		args:       makeKV("k", 2, "msg", "arg"),
+		expectKV:   `"level"=0 "msg"="msg" "k"=1 "k"=2 "msg"="arg"`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","k":1,"k":2,"msg":"arg"}`,
+	}, {
+		name:       "last wins",
+		policy:     LastKeyWins,
+		values:     makeKV("k", 1, "v", 0),
+		args:       makeKV("k", 2, "msg", "arg", "k", 3),
+		expectKV:   `"level"=0 "v"=0 "msg"="arg" "k"=3`,
+		expectJSON: `{"logger":"","level":0,"v":0,"msg":"arg","k":3}`,
+	}, {
+		name:       "first wins",
+		policy:     FirstKeyWins,
+		values:     makeKV("k", 1, "v", 0),
+		args:       makeKV("k", 2, "msg", "arg", "k", 3),
+		expectKV:   `"level"=0 "msg"="msg" "k"=1 "v"=0`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","k":1,"v":0}`,
+	}, {
+		name:       "rename",
+		policy:     RenameDuplicateKeys,
+		values:     makeKV("k", 1, "v", 0),
+		args:       makeKV("k", 2, "msg", "arg", "k", 3, "fields.k", 4),
+		expectKV:   `"level"=0 "msg"="msg" "k"=1 "v"=0 "fields.k"=2 "fields.msg"="arg" "fields.fields.k"=3 "fields.fields.fields.k"=4`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","k":1,"v":0,"fields.k":2,"fields.msg":"arg","fields.fields.k":3,"fields.fields.fields.k":4}`,
+	}, {
+		name:       "no duplicates",
+		policy:     FirstKeyWins,
+		values:     makeKV("k", 1),
+		args:       makeKV("k2", 2, 3),
+		expectKV:   `"level"=0 "msg"="msg" "k"=1 "k2"=2 "<non-string-key: 3>"="<no-value>"`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","k":1,"k2":2,"<non-string-key: 3>":"<no-value>"}`,
+	}, {
+		name:       "groups are separate",
+		policy:     FirstKeyWins,
+		values:     makeKV("k", 1, "g", 0),
+		group:      "g",
+		groupVals:  makeKV("k", 2, "msg", "g"),
+		args:       makeKV("k", 3),
+		expectKV:   `"level"=0 "msg"="msg" "k"=1 "g"=0`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","k":1,"g":0}`,
+	}, {
+		name:       "groups last wins",
+		policy:     LastKeyWins,
+		values:     makeKV("k", 1, "g", 0),
+		group:      "g",
+		groupVals:  makeKV("k", 2, "msg", "g"),
+		args:       makeKV("k", 3),
+		expectKV:   `"level"=0 "msg"="msg" "k"=1 "g"={"msg"="g" "k"=3}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","k":1,"g":{"msg":"g","k":3}}`,
+	}, {
+		name:       "groups rename",
+		policy:     RenameDuplicateKeys,
+		values:     makeKV("g", 0),
+		group:      "g",
+		groupVals:  makeKV("k", 2),
+		args:       makeKV("k", 3),
+		expectKV:   `"level"=0 "msg"="msg" "g"=0 "fields.g"={"k"=2 "fields.k"=3}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","g":0,"fields.g":{"k":2,"fields.k":3}}`,
+	}, {
+		name:       "empty group",
+		policy:     LastKeyWins,
+		values:     makeKV("k", 1),
+		group:      "g",
+		args:       makeKV("k", 2),
+		expectKV:   `"level"=0 "msg"="msg" "k"=1 "g"={"k"=2}`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","k":1,"g":{"k":2}}`,
+	}}
+
+	for _, tc := range testCases {
+		run := func(t *testing.T, f Formatter, expect string) {
+			t.Helper()
+			if len(tc.values) > 0 {
+				f.AddValues(tc.values)
+			}
+			if tc.group != "" {
+				f.startGroup(tc.group)
+				if len(tc.groupVals) > 0 {
+					f.AddValues(tc.groupVals)
+				}
+			}
+			capt := &capture{}
+			sink := newSink(capt.Func, f)
+			sink.Info(0, "msg", tc.args...)
+			if capt.log != expect {
+				t.Errorf("\nexpected %q\n     got %q", expect, capt.log)
+			}
+		}
+		t.Run("KV: "+tc.name, func(t *testing.T) {
+			run(t, NewFormatter(Options{DuplicateKeys: tc.policy}), tc.expectKV)
+		})
+		t.Run("JSON: "+tc.name, func(t *testing.T) {
+			run(t, NewFormatterJSON(Options{DuplicateKeys: tc.policy}), tc.expectJSON)
+		})
+	}
+
+	t.Run("prefix and builtins hook", func(t *testing.T) {
+		capt := &capture{}
+		sink := newSink(capt.Func, NewFormatterJSON(Options{
+			DuplicateKeys:      RenameDuplicateKeys,
+			DuplicateKeyPrefix: "user_",
+			RenderBuiltinsHook: func(kvList []any) []any {
+				return append(kvList, "extra", 1)
+			},
+		}))
+		sink.Info(0, "msg", "extra", 2, "level", 3)
+		expect := `{"logger":"","level":0,"msg":"msg","extra":1,"user_extra":2,"user_level":3}`
+		if capt.log != expect {
+			t.Errorf("\nexpected %q\n     got %q", expect, capt.log)
+		}
+	})
+}
+
+func TestOptionsBuiltinKeys(t *testing.T) {
+	testCases := []struct {
+		name       string
+		opts       Options
+		err        error
+		expectKV   string
+		expectJSON string
+	}{{
+		name:       "defaults",
+		opts:       Options{},
+		expectKV:   `"level"=0 "msg"="msg" "k"=1`,
+		expectJSON: `{"logger":"","level":0,"msg":"msg","k":1}`,
+	}, {
+		name: "renamed",
+		opts: Options{
+			BuiltinKeys: map[Builtin]string{
+				BuiltinLogger:  "name",
+				BuiltinLevel:   "v",
+				BuiltinMessage: "message",
+			},
+		},
+		expectKV:   `"v"=0 "message"="msg" "k"=1`,
+		expectJSON: `{"name":"","v":0,"message":"msg","k":1}`,
+	}, {
+		name: "BuiltinKeys overrides LogInfoLevel",
+		opts: Options{
+			LogInfoLevel: ptrstr("lvl"),
+			BuiltinKeys:  map[Builtin]string{BuiltinLevel: "v"},
+		},
+		expectKV:   `"v"=0 "msg"="msg" "k"=1`,
+		expectJSON: `{"logger":"","v":0,"msg":"msg","k":1}`,
+	}, {
+		name: "disabled",
+		opts: Options{
+			BuiltinKeys: map[Builtin]string{BuiltinLogger: "", BuiltinLevel: ""},
+		},
+		expectKV:   `"msg"="msg" "k"=1`,
+		expectJSON: `{"msg":"msg","k":1}`,
+	}, {
+		name: "disabled error",
+		opts: Options{
+			BuiltinKeys: map[Builtin]string{BuiltinError: ""},
+		},
+		err:        fmt.Errorf("oops"),
+		expectKV:   `"msg"="msg" "k"=1`,
+		expectJSON: `{"logger":"","msg":"msg","k":1}`,
+	}, {
+		name: "ordered",
+		opts: Options{
+			LogCaller:    All,
+			BuiltinKeys:  map[Builtin]string{BuiltinCaller: "src"},
+			BuiltinOrder: []Builtin{BuiltinMessage, BuiltinLevel, BuiltinMessage, BuiltinError, Builtin(99)},
+		},
+		expectKV:   `"msg"="msg" "level"=0 "src"={"file"="funcr_test.go" "line"=CALLER} "k"=1`,
+		expectJSON: `{"msg":"msg","level":0,"logger":"","src":{"file":"funcr_test.go","line":CALLER},"k":1}`,
+	}, {
+		name: "ordered error",
+		opts: Options{
+			BuiltinOrder: []Builtin{BuiltinError, BuiltinMessage},
+		},
+		err:        fmt.Errorf("oops"),
+		expectKV:   `"error"="oops" "msg"="msg" "k"=1`,
+		expectJSON: `{"error":"oops","msg":"msg","logger":"","k":1}`,
+	}}
+
+	for _, tc := range testCases {
+		run := func(t *testing.T, f Formatter, expect string) {
+			t.Helper()
+			capt := &capture{}
+			sink := newSink(capt.Func, f)
+			var line int
+			if tc.err != nil {
+				_, _, line, _ = runtime.Caller(0)
+				sink.Error(tc.err, "msg", "k", 1)
+			} else {
+				_, _, line, _ = runtime.Caller(0)
+				sink.Info(0, "msg", "k", 1)
+			}
+			expect = strings.ReplaceAll(expect, "CALLER", fmt.Sprint(line+1))
+			if capt.log != expect {
+				t.Errorf("\nexpected %q\n     got %q", expect, capt.log)
+			}
+		}
+		t.Run("KV: "+tc.name, func(t *testing.T) {
+			run(t, NewFormatter(tc.opts), tc.expectKV)
+		})
+		t.Run("JSON: "+tc.name, func(t *testing.T) {
+			run(t, NewFormatterJSON(tc.opts), tc.expectJSON)
+		})
+	}
+
+	t.Run("builtins hook", func(t *testing.T) {
+		var keys []any
+		capt := &capture{}
+		sink := newSink(capt.Func, NewFormatterJSON(Options{
+			BuiltinKeys:  map[Builtin]string{BuiltinMessage: "message"},
+			BuiltinOrder: []Builtin{BuiltinMessage},
+			RenderBuiltinsHook: func(kvList []any) []any {
+				for i := 0; i < len(kvList); i += 2 {
+					keys = append(keys, kvList[i])
+				}
+				return kvList
+			},
+		}))
+		sink.Info(0, "msg")
+		if want := []any{"message", "logger", "level"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("expected keys %v, got %v", want, keys)
+		}
+	})
+}
+
+func TestOptionsCallerPath(t *testing.T) {
+	_, thisFile, _, _ := runtime.Caller(0)
+	dir := filepath.ToSlash(filepath.Dir(thisFile))
+
+	// Test binaries do not always know their main module.
+	moduleFile := "github.com/go-logr/logr/funcr/funcr_test.go"
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Path == "github.com/go-logr/logr" {
+		moduleFile = "funcr/funcr_test.go"
+	}
+
+	testCases := []struct {
+		name         string
+		opts         Options
+		expectFile   string
+		expectFunc   string
+		expectSuffix bool // expectFile is a suffix
+	}{{
+		name:       "default",
+		opts:       Options{},
+		expectFile: "funcr_test.go",
+	}, {
+		name:       "base",
+		opts:       Options{CallerPath: CallerPathBase},
+		expectFile: "funcr_test.go",
+	}, {
+		name:         "full",
+		opts:         Options{CallerPath: CallerPathFull},
+		expectFile:   "/funcr/funcr_test.go",
+		expectSuffix: true,
+	}, {
+		name:       "trimmed",
+		opts:       Options{CallerPath: CallerPathFull, CallerTrimPrefix: dir + "/"},
+		expectFile: "funcr_test.go",
+	}, {
+		name:       "trim prefix not matched",
+		opts:       Options{CallerPath: CallerPathFull, CallerTrimPrefix: "/no/such/dir/"},
+		expectFile: thisFile,
+	}, {
+		name:       "package",
+		opts:       Options{CallerPath: CallerPathPackage},
+		expectFile: "funcr/funcr_test.go",
+	}, {
+		name:       "module",
+		opts:       Options{CallerPath: CallerPathModule},
+		expectFile: moduleFile,
+	}, {
+		name:       "func",
+		opts:       Options{LogCallerFunc: true},
+		expectFile: "funcr_test.go",
+		expectFunc: "github.com/go-logr/logr/funcr.TestOptionsCallerPath.func1",
+	}, {
+		name:       "short func",
+		opts:       Options{LogCallerFunc: true, LogCallerShortFunc: true},
+		expectFile: "funcr_test.go",
+		expectFunc: "funcr.TestOptionsCallerPath.func1",
+	}, {
+		name:       "short func without func",
+		opts:       Options{LogCallerShortFunc: true},
+		expectFile: "funcr_test.go",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got Caller
+			opts := tc.opts
+			opts.LogCaller = All
+			opts.RenderBuiltinsHook = func(kvList []any) []any {
+				for i := 0; i < len(kvList); i += 2 {
+					if c, ok := kvList[i+1].(Caller); ok {
+						got = c
+					}
+				}
+				return kvList
+			}
+			sink := newSink(func(_, _ string) {}, NewFormatter(opts))
+			sink.Info(0, "msg")
+			if tc.expectSuffix {
+				if !strings.HasSuffix(got.File, tc.expectFile) {
+					t.Errorf("expected file ending in %q, got %q", tc.expectFile, got.File)
+				}
+			} else if got.File != tc.expectFile {
+				t.Errorf("expected file %q, got %q", tc.expectFile, got.File)
+			}
+			if got.Func != tc.expectFunc {
+				t.Errorf("expected func %q, got %q", tc.expectFunc, got.Func)
+			}
+		})
+	}
+}
+
+func TestModuleFile(t *testing.T) {
+	pc, file, _, _ := runtime.Caller(0)
+	bi, _ := debug.ReadBuildInfo()
+	want := "github.com/go-logr/logr/funcr/funcr_test.go"
+	if bi != nil && bi.Main.Path == "github.com/go-logr/logr" {
+		want = "funcr/funcr_test.go"
+	}
+	if got := moduleFile(file, pc); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// Standard library packages are not in a module.
+	pc, file, _, _ = runtime.Caller(1) // testing.tRunner
+	if got, want := moduleFile(file, pc), "testing/testing.go"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestLastPathElems(t *testing.T) {
+	testCases := []struct {
+		path   string
+		n      int
+		expect string
+	}{
+		{"/a/b/c.go", 1, "c.go"},
+		{"/a/b/c.go", 2, "b/c.go"},
+		{"/a/b/c.go", 3, "a/b/c.go"},
+		{"/a/b/c.go", 4, "a/b/c.go"},
+		{"a/b/c.go", 4, "a/b/c.go"},
+		{"c.go", 2, "c.go"},
+	}
+	for _, tc := range testCases {
+		if got := lastPathElems(tc.path, tc.n); got != tc.expect {
+			t.Errorf("lastPathElems(%q, %d): expected %q, got %q", tc.path, tc.n, tc.expect, got)
+		}
+	}
+}
+
+func TestOptionsTimestamp(t *testing.T) {
+	loc := time.FixedZone("UTC-7", -7*60*60)
+	start := time.Date(2006, time.January, 2, 15, 4, 5, 0, loc)
+	var now time.Time
+	clock := func() time.Time { return now }
+
+	testCases := []struct {
+		name       string
+		opts       Options
+		expect     string
+		expectHook any
+	}{{
+		name:       "layout",
+		opts:       Options{},
+		expect:     `"2006-01-02 15:04:05.500000"`,
+		expectHook: "2006-01-02 15:04:05.500000",
+	}, {
+		name:       "layout UTC",
+		opts:       Options{TimestampFormat: time.RFC3339Nano, TimestampUTC: true},
+		expect:     `"2006-01-02T22:04:05.5Z"`,
+		expectHook: "2006-01-02T22:04:05.5Z",
+	}, {
+		name:       "unix",
+		opts:       Options{TimestampStyle: TimestampUnix},
+		expect:     `1136239445.5`,
+		expectHook: 1136239445.5,
+	}, {
+		name:       "unix milli",
+		opts:       Options{TimestampStyle: TimestampUnixMilli},
+		expect:     `1136239445500`,
+		expectHook: int64(1136239445500),
+	}, {
+		name:       "unix nano",
+		opts:       Options{TimestampStyle: TimestampUnixNano},
+		expect:     `1136239445500000000`,
+		expectHook: int64(1136239445500000000),
+	}, {
+		name:       "elapsed",
+		opts:       Options{TimestampStyle: TimestampElapsed},
+		expect:     `0.5`,
+		expectHook: 0.5,
+	}}
+
+	// logAt creates a Formatter at the start time and logs through it
+	// half a second later.
+	logAt := func(opts Options) string {
+		now = start
+		f := NewFormatterJSON(opts)
+		now = start.Add(500 * time.Millisecond)
+		capt := &capture{}
+		newSink(capt.Func, f).Info(0, "msg")
+		return capt.log
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			opts.LogTimestamp = true
+			opts.Clock = clock
+			expect := `{"logger":"","ts":` + tc.expect + `,"level":0,"msg":"msg"}`
+			if got := logAt(opts); got != expect {
+				t.Errorf("\nexpected %q\n     got %q", expect, got)
+			}
+
+			var hookVal any
+			opts.RenderBuiltinsHook = func(kvList []any) []any {
+				hookVal = kvList[3]
+				return kvList
+			}
+			if got := logAt(opts); got != expect {
+				t.Errorf("hook:\nexpected %q\n     got %q", expect, got)
+			}
+			if hookVal != tc.expectHook {
+				t.Errorf("hook: expected %#v, got %#v", tc.expectHook, hookVal)
+			}
+		})
+	}
+}
+
+func TestOptionsProcessInfo(t *testing.T) {
+	host := hostname()
+	pidStr := strconv.Itoa(os.Getpid())
+
+	t.Run("host and pid", func(t *testing.T) {
+		capt := &capture{}
+		sink := newSink(capt.Func, NewFormatterJSON(Options{LogHostname: true, LogPID: true}))
+		sink.Info(0, "msg")
+		expect := `{"logger":"","level":0,"msg":"msg","host":` + strconv.Quote(host) + `,"pid":` + pidStr + `}`
+		if capt.log != expect {
+			t.Errorf("\nexpected %q\n     got %q", expect, capt.log)
+		}
+	})
+
+	t.Run("ordered", func(t *testing.T) {
+		capt := &capture{}
+		sink := newSink(capt.Func, NewFormatter(Options{
+			LogPID:       true,
+			BuiltinOrder: []Builtin{BuiltinPID, BuiltinLevel},
+		}))
+		sink.Info(0, "msg")
+		expect := `"pid"=` + pidStr + ` "level"=0 "msg"="msg"`
+		if capt.log != expect {
+			t.Errorf("\nexpected %q\n     got %q", expect, capt.log)
+		}
+	})
+
+	t.Run("goroutine", func(t *testing.T) {
+		var kvs []any
+		sink := newSink(func(string, string) {}, NewFormatter(Options{
+			LogGoroutineID: true,
+			RenderBuiltinsHook: func(kvList []any) []any {
+				kvs = kvList
+				return kvList
+			},
+		}))
+		ids := make(chan uint64, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				sink.Info(0, "msg")
+				ids <- kvs[len(kvs)-1].(uint64) //nolint:forcetypeassert
+			}()
+			if id := <-ids; id == 0 {
+				t.Errorf("expected a goroutine ID, got 0")
+			}
+		}
+		// Goroutine IDs are never reused.
+		sink.Info(0, "msg")
+		first := kvs[len(kvs)-1]
+		go func() {
+			sink.Info(0, "msg")
+			ids <- kvs[len(kvs)-1].(uint64) //nolint:forcetypeassert
+		}()
+		if second := <-ids; second == first {
+			t.Errorf("expected different goroutine IDs, got %d twice", second)
+		}
+	})
+
+	t.Run("sequence", func(t *testing.T) {
+		var seqs []uint64
+		hook := func(kvList []any) []any {
+			seqs = append(seqs, kvList[len(kvList)-1].(uint64)) //nolint:forcetypeassert
+			return kvList
+		}
+		capt := &capture{}
+		sink1 := newSink(capt.Func, NewFormatterJSON(Options{LogSequence: true, RenderBuiltinsHook: hook}))
+		sink2 := newSink(capt.Func, NewFormatter(Options{LogSequence: true, RenderBuiltinsHook: hook}))
+		sink1.Info(0, "msg")
+		sink2.Info(0, "msg")
+		sink1.Error(fmt.Errorf("err"), "msg")
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] != seqs[i-1]+1 {
+				t.Errorf("expected consecutive sequence numbers, got %v", seqs)
+			}
+		}
+		expect := `{"logger":"","msg":"msg","error":"err","seq":` + strconv.FormatUint(seqs[2], 10) + `}`
+		if capt.log != expect {
+			t.Errorf("\nexpected %q\n     got %q", expect, capt.log)
+		}
+	})
+}
+
+// Tjsonmarshalerctl returns a JSON string holding raw control characters,
+// which JSON allows.
+type Tjsonmarshalerctl struct{}
+
+func (Tjsonmarshalerctl) MarshalJSON() ([]byte, error) {
+	return []byte("\"a\u009b1m\x7fb\""), nil
+}
+
+func TestOptionsSafeText(t *testing.T) {
+	testCases := []struct {
+		name   string
+		val    any
+		expect string // with SafeText
+		loose  string // without SafeText
+	}{{
+		name:   "plain string",
+		val:    "hello, wörld",
+		expect: `"hello, wörld"`,
+		loose:  `"hello, wörld"`,
+	}, {
+		name:   "line breaks",
+		val:    "a\r\nb\u2028c",
+		expect: `"a\r\nb\u2028c"`,
+		loose:  `"a\r\nb\u2028c"`,
+	}, {
+		name:   "escape sequences",
+		val:    "\x1b[31mred\u009b0m",
+		expect: `"\x1b[31mred\u009b0m"`,
+		loose:  `"\x1b[31mred\u009b0m"`,
+	}, {
+		name:   "invalid UTF-8",
+		val:    "a\x9b1mb\xff",
+		expect: `"a\x9b1mb\xff"`,
+		loose:  "\"a\x9b1mb\xff\"",
+	}, {
+		name:   "error",
+		val:    fmt.Errorf("a\x9bb"),
+		expect: `"a\x9bb"`,
+		loose:  "\"a\x9bb\"",
+	}, {
+		name:   "map keys",
+		val:    map[string]int{"\x9b": 1},
+		expect: `{"\x9b"=1}`,
+		loose:  "{\"\x9b\"=1}",
+	}, {
+		name:   "MarshalJSON",
+		val:    Tjsonmarshalerctl{},
+		expect: `"a\u009b1m\x7fb"`,
+		loose:  "\"a\u009b1m\x7fb\"",
+	}}
+
+	safe := NewFormatter(Options{SafeText: true, UseEncodingMarshalers: true})
+	loose := NewFormatter(Options{UseEncodingMarshalers: true})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := safe.pretty(tc.val); got != tc.expect {
+				t.Errorf("safe:\nexpected %q\n     got %q", tc.expect, got)
+			}
+			if got := loose.pretty(tc.val); got != tc.loose {
+				t.Errorf("loose:\nexpected %q\n     got %q", tc.loose, got)
+			}
+		})
+	}
+
+	t.Run("names", func(t *testing.T) {
+		for _, tc := range []struct {
+			opts   Options
+			json   bool
+			prefix string
+			args   string
+		}{{
+			opts:   Options{SafeText: true},
+			prefix: `a\nb/c\x1b[2J\u009b\xffd`,
+			args:   `"level"=0 "msg"="x\ny"`,
+		}, {
+			opts:   Options{},
+			prefix: "a\nb/c\x1b[2J\u009b\xffd",
+			args:   `"level"=0 "msg"="x\ny"`,
+		}, {
+			opts: Options{SafeText: true},
+			json: true,
+			args: `{"logger":"a\nb/c\x1b[2J\u009b\xffd","level":0,"msg":"x\ny"}`,
+		}} {
+			f := NewFormatter(tc.opts)
+			if tc.json {
+				f = NewFormatterJSON(tc.opts)
+			}
+			f.AddName("a\nb")
+			f.AddName("c\x1b[2J\u009b\xffd")
+			prefix, args := f.FormatInfo(0, "x\ny", nil)
+			if prefix != tc.prefix || args != tc.args {
+				t.Errorf("%+v:\nexpected %q %q\n     got %q %q", tc.opts, tc.prefix, tc.args, prefix, args)
+			}
+		}
+	})
+}
+
+func TestCBORRoundTrip(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	str := "a string"
+	cases := []struct {
+		name string
+		args []any
+	}{{
+		name: "primitives",
+		args: makeKV("b", true, "i", -42, "i8", int8(-128), "i64", int64(math.MinInt64),
+			"u", uint(42), "u64", uint64(math.MaxUint64), "f32", float32(1.1), "f64", 3.14159,
+			"nan", math.NaN(), "inf", math.Inf(-1), "c", complex(1, -2)),
+	}, {
+		name: "strings",
+		args: makeKV("empty", "", "escapes", "\"\\\n\t\x00\x1b", "unicode", "wörld \U0001F600 ",
+			"invalid", "a\xffb", "long", strings.Repeat("x", 300), "substr", substr("sub")),
+	}, {
+		name: "collections",
+		args: makeKV("slice", []int{1, 2, 3}, "empty", []string{}, "nilslice", []string(nil),
+			"bytes", []byte("hi"), "array", [2]bool{true, false},
+			"map", map[string]int{"one": 1}, "intkeys", map[int]string{7: "seven"},
+			"structkeys", map[point]int{{1, 2}: 3}, "nested", map[string][]map[string]int{"a": {{"b": 1}}}),
+	}, {
+		name: "structs",
+		args: makeKV("point", point{1, 2}, "embed", Tembedstruct{Tinnerstruct{"in"}, "out"},
+			"embednonstruct", Tembednonstruct{1, Tinnermap{"a": "b"}, Tinnerslice{"c"}},
+			"jsontags", Tembedjsontags{Outer: "out"}, "raw", Trawjson{json.RawMessage(`{"a": [1, 2.5, "x", null, true]}`)},
+			"recursive", Trecursive{&Trecursive{}}),
+	}, {
+		name: "pointers",
+		args: makeKV("ptr", intPtr(3), "nilptr", (*int)(nil), "strptr", &str, "nil", nil, "iface", any(5)),
+	}, {
+		name: "pseudostruct",
+		args: makeKV("ps", PseudoStruct(makeKV("k1", 1, "k2", PseudoStruct(makeKV("k3", "v"))))),
+	}, {
+		name: "methods",
+		args: makeKV("marshaler", Tmarshaler{"m"}, "stringer", Tstringer{"s"}, "error", Terror{"e"},
+			"panics", Tstringerpanic{"p"}, "jsonmarshaler", Tjsonmarshaler{"j"},
+			"jsonerr", Tjsonmarshalererr{"j"}, "jsoninvalid", Tjsonmarshalerinvalid{"j"},
+			"jsonpanic", Tjsonmarshalerpanic{"j"}, "textmarshaler", Ttextmarshaler{"t"}),
+	}, {
+		name: "odd keys",
+		args: makeKV("dup", 1, "dup", 2, 42, "non-string key", "missing value"),
+	}}
+
+	optionSets := []Options{
+		{},
+		{SortKeys: true},
+		{UseEncodingMarshalers: true},
+		{DuplicateKeys: RenameDuplicateKeys},
+		{MaxStringLength: 10, MaxCollectionItems: 1, MaxLogDepth: 2},
+		{LogTimestamp: true, LogCaller: All, LogCallerFunc: true, LogHostname: true, LogPID: true},
+		{LogTimestamp: true, TimestampStyle: TimestampUnix, BuiltinOrder: []Builtin{BuiltinMessage}},
+		{RenderBuiltinsHook: func(kvList []any) []any { return append(kvList, "hooked", PseudoStruct(kvList)) }},
+	}
+
+	now := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	for i, opts := range optionSets {
+		opts.StrictJSON = true
+		opts.Clock = func() time.Time { return now }
+		for _, tc := range cases {
+			t.Run(fmt.Sprintf("%d/%s", i, tc.name), func(t *testing.T) {
+				var out [2]string
+				for j, f := range []Formatter{NewFormatterJSON(opts), NewFormatterCBOR(opts)} {
+					f.AddName("name")
+					f.AddValues([]any{"saved", 1, "group", "outer"})
+					f.startGroup("group")
+					f.AddValues([]any{"inner", []int{1}})
+					sep := []string{"\n", ""}[j] // CBOR records are self-delimiting
+					capt := &capture{}
+					sink := newSink(capt.Func, f)
+					sink.Info(0, "msg", tc.args...)
+					out[j] = capt.log + sep
+					sink.Error(fmt.Errorf("err"), "msg", tc.args...)
+					out[j] += capt.log
+				}
+				// Invalid UTF-8 was replaced before encoding, so it doesn't
+				// need escaping.
+				expect := strings.ReplaceAll(out[0], `\ufffd`, "\ufffd")
+				cbor := []byte(out[1])
+				var got []string
+				for len(cbor) > 0 {
+					js, n, err := CBORToJSON(cbor)
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					if !json.Valid([]byte(js)) {
+						t.Errorf("invalid JSON: %s", js)
+					}
+					got = append(got, js)
+					cbor = cbor[n:]
+				}
+				if got := strings.Join(got, "\n"); got != expect {
+					t.Errorf("\nexpected %s\n     got %s", expect, got)
+				}
+			})
+		}
+	}
+}
+
+func TestCBORToJSON(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cbor   string
+		expect string
+		err    string
+	}{{
+		name:   "definite lengths",
+		cbor:   "\xa2\x61a\x83\x01\x18\x64\x39\x01\xf3\x61b\x40",
+		expect: `{"a":[1,100,-500],"b":""}`,
+	}, {
+		name:   "large integers",
+		cbor:   "\x82\x1b\xff\xff\xff\xff\xff\xff\xff\xff\x3b\xff\xff\xff\xff\xff\xff\xff\xff",
+		expect: `[18446744073709551615,-18446744073709551616]`,
+	}, {
+		name:   "floats",
+		cbor:   "\x86\xf9\x3e\x00\xf9\x80\x00\xf9\x00\x01\xf9\x7c\x00\xfa\x3f\x8c\xcc\xcd\xfb\x7f\xf8\x00\x00\x00\x00\x00\x00",
+		expect: `[1.5,-0,0.000000059604645,"+Inf",1.1,"NaN"]`,
+	}, {
+		name:   "simple values",
+		cbor:   "\x84\xf4\xf5\xf6\xf7",
+		expect: `[false,true,null,null]`,
+	}, {
+		name:   "byte strings",
+		cbor:   "\x5f\x42hi\x41!\xff",
+		expect: `"aGkh"`,
+	}, {
+		name:   "indefinite text",
+		cbor:   "\x7f\x62ab\x61\"\xff",
+		expect: `"ab\""`,
+	}, {
+		name:   "tags",
+		cbor:   "\xc1\x1a\x43\xb9\x40\xe5",
+		expect: `1136214245`,
+	}, {
+		name:   "non-text keys",
+		cbor:   "\xa3\x01\x02\xf5\x61x\x81\x00\x40",
+		expect: `{"1":2,"true":"x","[0]":""}`,
+	}, {
+		name:   "text keys in non-text keys",
+		cbor:   "\xa1\xa1\x61a\x01\x02",
+		expect: `{"{\"a\":1}":2}`,
+	}, {
+		name: "empty",
+		cbor: "",
+		err:  "truncated CBOR at offset 0: unexpected EOF",
+	}, {
+		name: "truncated",
+		cbor: "\xbf\x61a\x19\x01",
+		err:  "truncated CBOR at offset 4: unexpected EOF",
+	}, {
+		name: "unterminated",
+		cbor: "\x9f\x01",
+		err:  "truncated CBOR at offset 2: unexpected EOF",
+	}, {
+		name: "unexpected break",
+		cbor: "\x82\x01\xff",
+		err:  "invalid CBOR at offset 2: unexpected break",
+	}, {
+		name: "reserved",
+		cbor: "\x1c",
+		err:  "invalid CBOR at offset 0: reserved additional information 28",
+	}, {
+		name: "indefinite integer",
+		cbor: "\x3f",
+		err:  "invalid CBOR at offset 0: indefinite length for major type 1",
+	}, {
+		name: "bad chunk",
+		cbor: "\x7f\x41a\xff",
+		err:  "invalid CBOR at offset 1: invalid chunk in indefinite-length string",
+	}, {
+		name: "simple value",
+		cbor: "\xf8\x20",
+		err:  "invalid CBOR at offset 0: unsupported simple value 32",
+	}, {
+		name: "too deep",
+		cbor: strings.Repeat("\x81", maxCBORDepth+1) + "\x00",
+		err:  fmt.Sprintf("invalid CBOR at offset %d: nested too deeply", maxCBORDepth),
+	}, {
+		name: "non-text keys in non-text keys",
+		cbor: "\xa1\xa1\x01\x02\x03",
+		err:  "invalid CBOR at offset 2: map key which is not text inside another",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.err != "" {
+				_, _, err := CBORToJSON([]byte(tc.cbor))
+				if err == nil || err.Error() != tc.err {
+					t.Errorf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			got, n, err := CBORToJSON([]byte(tc.cbor + "\x00")) // with a following item
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.expect {
+				t.Errorf("\nexpected %s\n     got %s", tc.expect, got)
+			}
+			if n != len(tc.cbor) {
+				t.Errorf("expected to use %d bytes, used %d", len(tc.cbor), n)
+			}
+		})
+	}
+}
+
+func TestParser(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   Options
+		input  string
+		expect []Record
+		err    string
+	}{{
+		name:  "key-value",
+		input: `a/b "level"=1 "msg"="hello" "k"="v" "n"=-3 "f"=1.5 "u"=18446744073709551615 "nil"=null "b"=true` + "\n",
+		expect: []Record{{
+			Logger:   "a/b",
+			Builtins: map[Builtin]any{BuiltinLevel: 1, BuiltinMessage: "hello"},
+			Values:   makeKV("k", "v", "n", int64(-3), "f", 1.5, "u", uint64(math.MaxUint64), "nil", nil, "b", true),
+		}},
+	}, {
+		name:  "key-value without prefix",
+		input: "\"level\"=0 \"msg\"=\"a\"\n \"level\"=0 \"msg\"=\"b\"\n",
+		expect: []Record{{
+			Builtins: map[Builtin]any{BuiltinLevel: 0, BuiltinMessage: "a"},
+		}, {
+			Builtins: map[Builtin]any{BuiltinLevel: 0, BuiltinMessage: "b"},
+		}},
+	}, {
+		name:  "key-value nesting",
+		input: `"level"=0 "msg"="" "s"={"F"=[1 "x y" {"="="}"}] "E"={} "A"=[]} "q"="\"\\\n\x1b\xff"`,
+		expect: []Record{{
+			Builtins: map[Builtin]any{BuiltinLevel: 0, BuiltinMessage: ""},
+			Values: makeKV("s", PseudoStruct(makeKV(
+				"F", []any{int64(1), "x y", PseudoStruct(makeKV("=", "}"))},
+				"E", PseudoStruct{},
+				"A", []any{})),
+				"q", "\"\\\n\x1b\xff"),
+		}},
+	}, {
+		name:  "key-value error",
+		input: `name "msg"="failed" "error"="boom" "level"=2`,
+		expect: []Record{{
+			Logger:   "name",
+			Builtins: map[Builtin]any{BuiltinMessage: "failed", BuiltinError: "boom"},
+			Values:   makeKV("level", int64(2)),
+		}},
+	}, {
+		name:  "json",
+		opts:  Options{LogCaller: All, LogCallerFunc: true, LogTimestamp: true},
+		input: `{"logger":"a", "ts":"2006-01-02 15:04:05.000000", "caller":{"file":"f.go","line":12,"function":"main.f"}, "level":0, "msg":"hi", "obj":{"k":[1.5e3, -0, "\u00e9\/\ud83d\ude00"]}}`,
+		expect: []Record{{
+			Logger: "a",
+			Builtins: map[Builtin]any{
+				BuiltinLogger:    "a",
+				BuiltinTimestamp: "2006-01-02 15:04:05.000000",
+				BuiltinCaller:    Caller{File: "f.go", Line: 12, Func: "main.f"},
+				BuiltinLevel:     0,
+				BuiltinMessage:   "hi",
+			},
+			Values: makeKV("obj", PseudoStruct(makeKV("k", []any{1500.0, math.Copysign(0, -1), "é/😀"}))),
+		}},
+	}, {
+		name:  "json error",
+		opts:  Options{LogCaller: Info},
+		input: `{"logger":"","msg":"m","error":"e","caller":"not a builtin"}`,
+		expect: []Record{{
+			Builtins: map[Builtin]any{BuiltinLogger: "", BuiltinMessage: "m", BuiltinError: "e"},
+			Values:   makeKV("caller", "not a builtin"),
+		}},
+	}, {
+		name: "json process info",
+		opts: Options{LogTimestamp: true, TimestampStyle: TimestampUnix, LogPID: true, LogGoroutineID: true, LogSequence: true},
+		input: `{"logger":"","ts":1136214245,"level":0,"msg":"m","pid":7,"goroutine":1,"seq":2}` + "\r\n\r\n" +
+			`{"logger":"","ts":"bad","level":0,"msg":"m","pid":7,"goroutine":1,"seq":-2}`,
+		expect: []Record{{
+			Builtins: map[Builtin]any{BuiltinLogger: "", BuiltinTimestamp: 1136214245.0, BuiltinLevel: 0, BuiltinMessage: "m",
+				BuiltinPID: 7, BuiltinGoroutineID: uint64(1), BuiltinSequence: uint64(2)},
+		}, {
+			Builtins: map[Builtin]any{BuiltinLogger: "", BuiltinTimestamp: "bad", BuiltinLevel: 0, BuiltinMessage: "m",
+				BuiltinPID: 7, BuiltinGoroutineID: uint64(1), BuiltinSequence: int64(-2)},
+		}},
+	}, {
+		name:  "json spanning lines",
+		input: "{\"logger\":\"\",\"level\":0,\"msg\":\"m\",\"raw\":{\"a\": [1,\n  2]\n}}\n{\"logger\":\"\",\"level\":0,\"msg\":\"n\"}",
+		expect: []Record{{
+			Builtins: map[Builtin]any{BuiltinLogger: "", BuiltinLevel: 0, BuiltinMessage: "m"},
+			Values:   makeKV("raw", PseudoStruct(makeKV("a", []any{int64(1), int64(2)}))),
+		}, {
+			Builtins: map[Builtin]any{BuiltinLogger: "", BuiltinLevel: 0, BuiltinMessage: "n"},
+		}},
+	}, {
+		name:  "custom keys",
+		opts:  Options{LogInfoLevel: ptrstr(""), BuiltinKeys: map[Builtin]string{BuiltinMessage: "message"}},
+		input: `"message"="m" "msg"="not a builtin"`,
+		expect: []Record{{
+			Builtins: map[Builtin]any{BuiltinMessage: "m"},
+			Values:   makeKV("msg", "not a builtin"),
+		}},
+	}, {
+		name:  "json on several lines",
+		input: "{\"k\":[1,\n2],\n\"s\":\"}\"}\n{\"k\":\"next\"}\n",
+		expect: []Record{
+			{Values: makeKV("k", []any{int64(1), int64(2)}, "s", "}")},
+			{Values: makeKV("k", "next")},
+		},
+	}, {
+		// Lines which do not start with the builtins of the Options, like
+		// those of Formatters with other Options, have whichever builtins
+		// they start with.
+		name:   "fewer builtins",
+		input:  `"msg"="ok" "k"=1`,
+		expect: []Record{{Builtins: map[Builtin]any{BuiltinMessage: "ok"}, Values: makeKV("k", int64(1))}},
+	}, {
+		name: "other options",
+		input: `{"logger":"a","ts":"2006-01-02 15:04:05.000000","caller":{"file":"f.go","line":12},"level":0,"msg":"m","error":"not a builtin"}` + "\n" +
+			`{"logger":"a","caller":{"file":"f.go","line":12},"msg":"m","error":"e","pid":1,"level":0}`,
+		expect: []Record{{
+			Logger: "a",
+			Builtins: map[Builtin]any{
+				BuiltinLogger:    "a",
+				BuiltinTimestamp: "2006-01-02 15:04:05.000000",
+				BuiltinCaller:    Caller{File: "f.go", Line: 12},
+				BuiltinLevel:     0,
+				BuiltinMessage:   "m",
+			},
+			Values: makeKV("error", "not a builtin"),
+		}, {
+			Logger: "a",
+			Builtins: map[Builtin]any{
+				BuiltinLogger:  "a",
+				BuiltinCaller:  Caller{File: "f.go", Line: 12},
+				BuiltinMessage: "m",
+				BuiltinError:   "e",
+				BuiltinPID:     1,
+			},
+			Values: makeKV("level", int64(0)),
+		}},
+	}, {
+		name:   "no builtins",
+		input:  `name "k"=1`,
+		expect: []Record{{Logger: "name", Values: makeKV("k", int64(1))}},
+	}, {
+		name:   "invalid key-value",
+		input:  "\"k\"=\"ok\"\n\"k\"=oops\n",
+		expect: []Record{{Values: makeKV("k", "ok")}},
+		err:    `line 2: invalid log line at offset 4: unexpected 'o'`,
+	}, {
+		name:  "unterminated key-value",
+		input: `"msg"="ok`,
+		err:   `line 1: unexpected end of line`,
+	}, {
+		name:  "unterminated json",
+		input: "{\"msg\":[1,\n2,\n",
+		err:   `line 1: unexpected end of line`,
+	}, {
+		name:  "invalid json on several lines",
+		input: "{\"msg\":[1,\n2 x\n",
+		err:   `line 1: invalid log line at offset 13: expected ',', found 'x'`,
+	}, {
+		name:  "trailing garbage",
+		input: `{"msg":1} x`,
+		err:   `line 1: invalid log line at offset 10: unexpected 'x' after the last value`,
+	}, {
+		name:  "too deep",
+		input: `"k"=` + strings.Repeat("[", maxParseDepth+1) + strings.Repeat("]", maxParseDepth+1),
+		err:   fmt.Sprintf("line 1: invalid log line at offset %d: values nested too deeply", 4+maxParseDepth),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewParser(strings.NewReader(tc.input), tc.opts)
+			var got []Record
+			for p.Next() {
+				got = append(got, p.Record())
+			}
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("\nexpected %#v\n     got %#v", tc.expect, got)
+			}
+			if err := p.Err(); (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+				t.Errorf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+// randomValue returns a random value to log, of up to depth levels of
+// nesting.
+func randomValue(r *rand.Rand, depth int) any {
+	kinds := 14
+	if depth == 0 {
+		kinds = 8 // no collections
+	}
+	switch r.Intn(kinds) {
+	case 0:
+		return randomString(r)
+	case 1:
+		return r.Int63() - r.Int63()
+	case 2:
+		return int8(r.Intn(256) - 128)
+	case 3:
+		return r.Uint64()
+	case 4:
+		return r.NormFloat64() * math.Pow(10, float64(r.Intn(60)-30))
+	case 5:
+		return float32(r.NormFloat64() * 1000)
+	case 6:
+		specials := []any{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 0.0,
+			math.MaxFloat64, math.SmallestNonzeroFloat64, float32(math.MaxFloat32)}
+		return specials[r.Intn(len(specials))]
+	case 7:
+		others := []any{true, false, nil, complex(r.NormFloat64(), -1), fmt.Errorf("%s", randomString(r)),
+			Tstringer{randomString(r)}, Tmarshaler{randomString(r)}, Tjsonmarshaler{randomString(r)}}
+		return others[r.Intn(len(others))]
+	case 8:
+		list := make([]any, r.Intn(4))
+		for i := range list {
+			list[i] = randomValue(r, depth-1)
+		}
+		return list
+	case 9:
+		m := map[string]any{}
+		for i := r.Intn(4); i > 0; i-- {
+			m[randomString(r)] = randomValue(r, depth-1)
+		}
+		return m
+	case 10:
+		return struct {
+			A any
+			B string `json:"b,omitempty"`
+			C []int
+		}{randomValue(r, depth-1), randomString(r), []int{r.Intn(100)}}
+	case 11:
+		ps := PseudoStruct{}
+		for i := r.Intn(4); i > 0; i-- {
+			ps = append(ps, randomString(r), randomValue(r, depth-1))
+		}
+		return ps
+	case 12:
+		return []byte(randomString(r))
+	}
+	raws := []string{`{"a":[1,-2.5,"x",null,true,{}]}`, `[]`, `"x\n"`, `-7`}
+	return json.RawMessage(raws[r.Intn(len(raws))])
+}
+
+// randomString returns a random string, made from pieces which need escaping
+// or are otherwise awkward to parse.
+func randomString(r *rand.Rand) string {
+	pieces := []string{"a", "Z", " ", "\"", "\\", "\n", "\t", "\x00", "\x1b", "\x7f", "\u2028", "é", "😀",
+		"\xff", "=", "{", "}", "[", "]", ",", ":"}
+	s := ""
+	for i := r.Intn(8); i > 0; i-- {
+		s += pieces[r.Intn(len(pieces))]
+	}
+	return s
+}
+
+func TestParserReset(t *testing.T) {
+	p := NewParser(strings.NewReader("\"k\"=oops\n\"k\"=1\n"), Options{})
+	if p.Next() || p.Err() == nil {
+		t.Fatalf("expected an error, got %v", p.Err())
+	}
+	p.Reset(strings.NewReader("\"k\"=2\n\"k\"=x\n"))
+	if !p.Next() {
+		t.Fatalf("unexpected error: %v", p.Err())
+	}
+	if got := p.Record(); !reflect.DeepEqual(got.Values, makeKV("k", int64(2))) {
+		t.Errorf("wrong record %#v", got)
+	}
+	// Lines are counted from the start of the new input.
+	if p.Next() || p.Err() == nil || !strings.HasPrefix(p.Err().Error(), "line 2:") {
+		t.Errorf("expected an error on line 2, got %v", p.Err())
+	}
+}
+
+func TestParserRoundTrip(t *testing.T) {
+	optionSets := []Options{
+		{},
+		{StrictJSON: true},
+		{SortKeys: true, LogTimestamp: true},
+		{MaxStringLength: 5, MaxCollectionItems: 2, MaxLogDepth: 2, MaxLineBytes: 300, DuplicateKeys: RenameDuplicateKeys},
+		{LogTimestamp: true, TimestampStyle: TimestampUnix, LogPID: true, LogHostname: true, LogGoroutineID: true,
+			BuiltinOrder: []Builtin{BuiltinMessage}},
+		{BuiltinKeys: map[Builtin]string{BuiltinMessage: "message", BuiltinLogger: ""}, DuplicateKeys: LastKeyWins},
+	}
+
+	now := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	for i, opts := range optionSets {
+		opts.Clock = func() time.Time { return now }
+		// The same, without anything which changes values.
+		plain := opts
+		plain.MaxStringLength, plain.MaxCollectionItems, plain.MaxLogDepth, plain.MaxLineBytes = 0, 0, 0, 0
+		plain.DuplicateKeys = AllowDuplicateKeys
+
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r := rand.New(rand.NewSource(int64(i)))
+			var input strings.Builder
+			var expect []string
+			for n := 0; n < 200; n++ {
+				f := newFormatter(opts, outputFormat(n%2)) // key-value or JSON
+				f.AddName("outer")
+				if r.Intn(2) == 0 {
+					f.AddName("inner")
+				}
+				f.AddValues([]any{"saved", randomValue(r, 2), randomString(r), randomValue(r, 2)})
+				if r.Intn(2) == 0 {
+					f.startGroup(randomString(r))
+				}
+				var kvList []any
+				for j := r.Intn(6); j > 0; j-- {
+					kvList = append(kvList, randomString(r), randomValue(r, 3))
+				}
+				switch r.Intn(4) {
+				case 0:
+					kvList = append(kvList, "saved", 1) // a duplicate
+				case 1:
+					kvList = append(kvList, 42, "non-string key")
+				case 2:
+					kvList = append(kvList, "missing value")
+				}
+				capt := &capture{}
+				sink := newSink(capt.Func, f)
+				if r.Intn(2) == 0 {
+					sink.Info(r.Intn(3), randomString(r), kvList...)
+				} else {
+					sink.Error(fmt.Errorf("%s", randomString(r)), randomString(r), kvList...)
+				}
+				input.WriteString(capt.log + "\n")
+				expect = append(expect, capt.log)
+			}
+
+			p := NewParser(strings.NewReader(input.String()), opts)
+			for n := 0; p.Next(); n++ {
+				if n >= len(expect) {
+					t.Fatalf("too many records")
+				}
+				rec := p.Record()
+				g := newFormatter(plain, outputFormat(n%2))
+				if rec.Logger != "" {
+					g.AddName(rec.Logger)
+				}
+				capt := &capture{}
+				sink := newSink(capt.Func, g)
+				msg, _ := rec.Builtins[BuiltinMessage].(string)
+				if rec.IsError() {
+					sink.Error(errors.New(rec.Builtins[BuiltinError].(string)), msg, rec.Values...) //nolint:forcetypeassert
+				} else {
+					sink.Info(rec.Builtins[BuiltinLevel].(int), msg, rec.Values...) //nolint:forcetypeassert
+				}
+				want := expect[n]
+				if opts.StrictJSON {
+					// Invalid UTF-8 was replaced, so it doesn't need escaping
+					// any more.
+					want = strings.ReplaceAll(want, `\ufffd`, "\ufffd")
+				}
+				if capt.log != want {
+					t.Errorf("record %d:\nexpected %s\n     got %s", n, want, capt.log)
+				}
+			}
+			if err := p.Err(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,654 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package funcr
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Record is a log line which was read back by a Parser.
+type Record struct {
+	// Logger is the name of the logger which wrote the line: the prefix of a
+	// key-value line, or the logger builtin of a JSON line.
+	Logger string
+
+	// Builtins holds the builtins found in the line, as the same types
+	// Options.RenderBuiltinsHook is passed, e.g. an int for BuiltinLevel and
+	// a Caller for BuiltinCaller.  A builtin whose value does not have the
+	// expected form is held as it was parsed.
+	Builtins map[Builtin]any
+
+	// Values holds the other key-value pairs of the line, in order, including
+	// those which were saved with WithValues.  Objects, including structs,
+	// maps, and groups, are parsed as PseudoStruct, arrays as []any, and
+	// numbers as int64, uint64 (if too big for int64), or float64.
+	Values []any
+}
+
+// IsError tells whether the line was logged by Error rather than Info, as
+// shown by the error builtin.
+func (r Record) IsError() bool {
+	_, found := r.Builtins[BuiltinError]
+	return found
+}
+
+// Parser reads log lines which were produced by a Formatter, in key-value or
+// JSON format, and parses them into Records.  Each line is a JSON object, or
+// the prefix and args of a key-value line separated by a space, as written by
+// fmt.Println(prefix, args).  JSON lines may span several lines of input if
+// they include a json.RawMessage with newlines.
+//
+// The Options should be those the lines were produced with, so that builtins
+// can be told apart from other values.  Builtins are recognized when the line
+// starts with them, as the Formatter writes them, so they are not recognized
+// if they were changed by Options.RenderBuiltinsHook.  If a line does not
+// start with the builtins which the Options enable, any builtins it starts
+// with, in the order of Options.BuiltinOrder, are recognized instead, so that
+// lines from Formatters with other Options can be parsed too.
+type Parser struct {
+	r     *bufio.Reader
+	lists [2][2][]Builtin // [json][isError]
+	order [2][]Builtin    // [json], all builtins which have keys
+	keys  [maxBuiltins]string
+	style TimestampStyle
+	line  int
+	rec   Record
+	err   error
+}
+
+// NewParser returns a Parser which reads lines from r.
+func NewParser(r io.Reader, opts Options) *Parser {
+	p := &Parser{r: bufio.NewReader(r), style: opts.TimestampStyle}
+	for i, outfmt := range []outputFormat{outputKeyValue, outputJSON} {
+		f := newFormatter(opts, outfmt)
+		p.keys = f.builtinCfg.keys
+		p.lists[i][0] = f.builtins(nil, false)
+		p.lists[i][1] = f.builtins(nil, true)
+		for _, b := range f.builtinCfg.order {
+			if p.keys[b] != "" && (b != BuiltinLogger || outfmt != outputKeyValue) {
+				p.order[i] = append(p.order[i], b)
+			}
+		}
+	}
+	return p
+}
+
+// Reset discards the state of p, including any error, and makes it read from
+// r instead.  This allows a Parser to be reused, for example to parse lines
+// one at a time.
+func (p *Parser) Reset(r io.Reader) {
+	p.r.Reset(r)
+	p.line = 0
+	p.rec = Record{}
+	p.err = nil
+}
+
+// Next parses the next line, which is then available from Record.  It returns
+// false when there are no more lines, or on error, which is then available
+// from Err.
+func (p *Parser) Next() bool {
+	if p.err != nil {
+		return false
+	}
+	var line string
+	for line == "" {
+		var err error
+		if line, err = p.readLine(); err != nil {
+			p.err = err
+			return false
+		}
+	}
+
+	start := p.line
+	for {
+		rec, err := p.parse(line)
+		if err == nil {
+			p.rec = rec
+			return true
+		}
+		if errors.Is(err, errIncomplete) && isJSONLine(line) {
+			more, rerr := p.readRest(line)
+			if rerr == nil {
+				line = more
+				continue
+			}
+			if rerr != io.EOF {
+				p.err = rerr
+				return false
+			}
+			if more != line {
+				_, err = p.parse(more)
+			}
+		}
+		p.err = fmt.Errorf("line %d: %w", start, err)
+		return false
+	}
+}
+
+// readRest reads the lines which a JSON line continues on, until its brackets
+// are balanced, and returns them joined to it.  At the end of the input, it
+// returns what it read with io.EOF.  Parsing the line again only once it may
+// be complete, rather than after each line, keeps lines which continue on
+// many others from taking quadratic time.
+func (p *Parser) readRest(line string) (string, error) {
+	var depth bracketDepth
+	depth.scan(line)
+	var b strings.Builder
+	b.WriteString(line)
+	for {
+		next, err := p.readLine()
+		if err != nil {
+			return b.String(), err
+		}
+		b.WriteByte('\n')
+		b.WriteString(next)
+		depth.scan("\n")
+		depth.scan(next)
+		if depth.balanced() {
+			return b.String(), nil
+		}
+	}
+}
+
+// bracketDepth tracks the nesting of brackets outside of strings in a JSON
+// line which is read in pieces.
+type bracketDepth struct {
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+func (d *bracketDepth) scan(s string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case d.escaped:
+			d.escaped = false
+		case d.inString:
+			if c == '\\' {
+				d.escaped = true
+			} else if c == '"' {
+				d.inString = false
+			}
+		case c == '"':
+			d.inString = true
+		case c == '{' || c == '[':
+			d.depth++
+		case c == '}' || c == ']':
+			d.depth--
+		}
+	}
+}
+
+func (d *bracketDepth) balanced() bool {
+	return d.depth <= 0 && !d.inString
+}
+
+// Record returns the Record parsed by the last call to Next.
+func (p *Parser) Record() Record {
+	return p.rec
+}
+
+// Err returns the first error encountered by Next, or nil if it stopped at the
+// end of the input.
+func (p *Parser) Err() error {
+	if p.err == io.EOF {
+		return nil
+	}
+	return p.err
+}
+
+// readLine returns the next line of input, without its line ending.
+func (p *Parser) readLine() (string, error) {
+	line, err := p.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	p.line++
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// errIncomplete is returned by a lineParser which reached the end of its input
+// in the middle of a value.
+var errIncomplete = errors.New("unexpected end of line")
+
+// parse parses one log line.
+func (p *Parser) parse(line string) (Record, error) {
+	var rec Record
+	lp := lineParser{s: line}
+	var kvs []any
+	var err error
+	if isJSONLine(line) {
+		lp.json = true
+		kvs, err = lp.object(0)
+	} else {
+		rec.Logger, lp.s = splitPrefix(line)
+		kvs, err = lp.pairs(0, 0)
+	}
+	if err == nil {
+		err = lp.end()
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	mode := 0
+	if lp.json {
+		mode = 1
+	}
+	list := p.matchBuiltins(p.lists[mode][0], kvs)
+	if errList := p.matchBuiltins(p.lists[mode][1], kvs); len(errList) > len(list) {
+		list = errList
+	}
+	if list == nil {
+		list = p.findBuiltins(p.order[mode], kvs)
+	}
+	if len(list) > 0 {
+		rec.Builtins = make(map[Builtin]any, len(list))
+	}
+	for i, b := range list {
+		v := kvs[2*i+1]
+		if conv, ok := p.builtinValue(b, v); ok {
+			v = conv
+		}
+		rec.Builtins[b] = v
+		if s, ok := v.(string); ok && b == BuiltinLogger {
+			rec.Logger = s
+		}
+	}
+	if rest := kvs[2*len(list):]; len(rest) > 0 {
+		rec.Values = rest
+	}
+	return rec, nil
+}
+
+// isJSONLine tells whether a line is a JSON object rather than a key-value
+// line.
+func isJSONLine(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " \t"), "{")
+}
+
+// splitPrefix splits a key-value line into the prefix and the args.
+func splitPrefix(line string) (prefix, args string) {
+	if strings.HasPrefix(line, `"`) {
+		return "", line
+	}
+	if i := strings.Index(line, ` "`); i >= 0 {
+		return line[:i], line[i+1:]
+	}
+	return strings.TrimSuffix(line, " "), ""
+}
+
+// matchBuiltins returns list if the keys of kvs start with those of the
+// builtins in list, or nil otherwise.
+func (p *Parser) matchBuiltins(list []Builtin, kvs []any) []Builtin {
+	if 2*len(list) > len(kvs) {
+		return nil
+	}
+	for i, b := range list {
+		if kvs[2*i] != p.keys[b] {
+			return nil
+		}
+	}
+	return list
+}
+
+// findBuiltins returns the builtins from order whose keys the keys of kvs
+// start with, in order.  The level and error builtins are never both found,
+// since Info lines have only the one, and Error lines only the other.
+func (p *Parser) findBuiltins(order []Builtin, kvs []any) []Builtin {
+	var list []Builtin
+	levelOrError := false
+	for i := 0; 2*i < len(kvs); i++ {
+		j := 0
+		for ; j < len(order); j++ {
+			b := order[j]
+			if kvs[2*i] == p.keys[b] && !(levelOrError && (b == BuiltinLevel || b == BuiltinError)) {
+				break
+			}
+		}
+		if j == len(order) {
+			break
+		}
+		b := order[j]
+		list = append(list, b)
+		levelOrError = levelOrError || b == BuiltinLevel || b == BuiltinError
+		order = order[j+1:]
+	}
+	return list
+}
+
+// builtinValue converts the parsed value of a builtin to the type which
+// Formatter.builtinValue returns for it, if possible.
+func (p *Parser) builtinValue(b Builtin, v any) (any, bool) {
+	switch b {
+	case BuiltinLogger, BuiltinMessage, BuiltinError, BuiltinHostname:
+		s, ok := v.(string)
+		return s, ok
+	case BuiltinTimestamp:
+		switch p.style {
+		case TimestampUnix, TimestampElapsed:
+			return toFloat(v)
+		case TimestampUnixMilli, TimestampUnixNano:
+			n, ok := v.(int64)
+			return n, ok
+		}
+		s, ok := v.(string)
+		return s, ok
+	case BuiltinCaller:
+		return toCaller(v)
+	case BuiltinLevel, BuiltinPID:
+		if n, ok := v.(int64); ok && int64(int(n)) == n {
+			return int(n), true
+		}
+	case BuiltinGoroutineID, BuiltinSequence:
+		switch n := v.(type) {
+		case int64:
+			return uint64(n), n >= 0
+		case uint64:
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// toCaller converts a parsed object to the Caller it was rendered from.
+func toCaller(v any) (Caller, bool) {
+	obj, ok := v.(PseudoStruct)
+	if !ok {
+		return Caller{}, false
+	}
+	var c Caller
+	for i := 0; i < len(obj); i += 2 {
+		switch obj[i] {
+		case "file":
+			c.File, ok = obj[i+1].(string)
+		case "line":
+			var n int64
+			n, ok = obj[i+1].(int64)
+			c.Line = int(n)
+		case "function":
+			c.Func, ok = obj[i+1].(string)
+		default:
+			ok = false
+		}
+		if !ok {
+			return Caller{}, false
+		}
+	}
+	return c, true
+}
+
+// maxParseDepth limits the nesting of values, to bound the recursion.
+const maxParseDepth = 1000
+
+// lineParser parses the values of one log line.  Key-value and JSON lines
+// share the same syntax for values, except for their separators, and that JSON
+// lines may contain whitespace between values.  Strings are parsed with Go's
+// syntax, which funcr uses unless Options.StrictJSON is set, or else with
+// JSON's.
+type lineParser struct {
+	s    string
+	pos  int
+	json bool
+}
+
+func (lp *lineParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid log line at offset %d: %s", lp.pos, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips whitespace between JSON values.
+func (lp *lineParser) skipSpace() {
+	if !lp.json {
+		return
+	}
+	for lp.pos < len(lp.s) {
+		switch lp.s[lp.pos] {
+		case ' ', '\t', '\n', '\r':
+			lp.pos++
+		default:
+			return
+		}
+	}
+}
+
+// end checks that nothing but whitespace is left.
+func (lp *lineParser) end() error {
+	lp.skipSpace()
+	if lp.pos < len(lp.s) {
+		return lp.errorf("unexpected %q after the last value", lp.s[lp.pos])
+	}
+	return nil
+}
+
+// expect consumes the byte c.
+func (lp *lineParser) expect(c byte) error {
+	lp.skipSpace()
+	if lp.pos >= len(lp.s) {
+		return errIncomplete
+	}
+	if lp.s[lp.pos] != c {
+		return lp.errorf("expected %q, found %q", c, lp.s[lp.pos])
+	}
+	lp.pos++
+	return nil
+}
+
+// peek returns the next byte, or 0 at the end of the input.
+func (lp *lineParser) peek() byte {
+	lp.skipSpace()
+	if lp.pos >= len(lp.s) {
+		return 0
+	}
+	return lp.s[lp.pos]
+}
+
+func (lp *lineParser) separators() (comma, colon byte) {
+	if lp.json {
+		return ',', ':'
+	}
+	return ' ', '='
+}
+
+// object parses an object, including the braces.
+func (lp *lineParser) object(depth int) (PseudoStruct, error) {
+	if err := lp.expect('{'); err != nil {
+		return nil, err
+	}
+	return lp.pairs('}', depth)
+}
+
+// pairs parses key-value pairs up to the closing delimiter, which is consumed,
+// or the end of the input if that is 0.
+func (lp *lineParser) pairs(close byte, depth int) (PseudoStruct, error) {
+	comma, colon := lp.separators()
+	kvs := PseudoStruct{}
+	for {
+		switch c := lp.peek(); {
+		case c == close && (c != 0 || lp.pos >= len(lp.s)):
+			lp.pos++
+			return kvs, nil
+		case len(kvs) > 0:
+			if err := lp.expect(comma); err != nil {
+				return nil, err
+			}
+		}
+		if lp.peek() == 0 && close == 0 {
+			return nil, lp.errorf("expected a key")
+		}
+		key, err := lp.str()
+		if err != nil {
+			return nil, err
+		}
+		if err := lp.expect(colon); err != nil {
+			return nil, err
+		}
+		val, err := lp.value(depth)
+		if err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, key, val)
+	}
+}
+
+// array parses an array, including the brackets.
+func (lp *lineParser) array(depth int) ([]any, error) {
+	comma, _ := lp.separators()
+	lp.pos++ // '['
+	list := []any{}
+	for {
+		if lp.peek() == ']' {
+			lp.pos++
+			return list, nil
+		}
+		if len(list) > 0 {
+			if err := lp.expect(comma); err != nil {
+				return nil, err
+			}
+		}
+		val, err := lp.value(depth)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, val)
+	}
+}
+
+// value parses any value.
+func (lp *lineParser) value(depth int) (any, error) {
+	if depth >= maxParseDepth {
+		return nil, lp.errorf("values nested too deeply")
+	}
+	switch c := lp.peek(); c {
+	case 0:
+		return nil, errIncomplete
+	case '"':
+		return lp.str()
+	case '{':
+		return lp.object(depth + 1)
+	case '[':
+		return lp.array(depth + 1)
+	}
+	for _, lit := range []struct {
+		text  string
+		value any
+	}{
+		{"null", nil},
+		{"true", true},
+		{"false", false},
+		{"NaN", math.NaN()},
+		{"+Inf", math.Inf(1)},
+		{"-Inf", math.Inf(-1)},
+	} {
+		if strings.HasPrefix(lp.s[lp.pos:], lit.text) {
+			lp.pos += len(lit.text)
+			return lit.value, nil
+		}
+	}
+	return lp.number()
+}
+
+// str parses a quoted string.
+func (lp *lineParser) str() (string, error) {
+	if err := lp.expect('"'); err != nil {
+		return "", err
+	}
+	start := lp.pos - 1
+	escaped := false
+	for {
+		if lp.pos >= len(lp.s) {
+			return "", errIncomplete
+		}
+		c := lp.s[lp.pos]
+		lp.pos++
+		if c == '"' {
+			break
+		}
+		if c == '\\' {
+			escaped = true
+			lp.pos++
+		}
+	}
+	quoted := lp.s[start:lp.pos]
+	if !escaped {
+		// Unquote would replace invalid UTF-8, which funcr writes as-is.
+		return quoted[1 : len(quoted)-1], nil
+	}
+	if s, err := strconv.Unquote(quoted); err == nil {
+		return s, nil
+	}
+	// JSON has escapes which Go does not, e.g. "\/" and surrogate pairs.
+	var s string
+	if err := json.Unmarshal([]byte(quoted), &s); err != nil {
+		lp.pos = start
+		return "", lp.errorf("invalid string %s", quoted)
+	}
+	return s, nil
+}
+
+// number parses a number.  Integers are parsed as int64 or uint64 if they
+// fit, and anything else as float64.
+func (lp *lineParser) number() (any, error) {
+	start := lp.pos
+	for lp.pos < len(lp.s) && strings.IndexByte("+-.0123456789Ee", lp.s[lp.pos]) >= 0 {
+		lp.pos++
+	}
+	num := lp.s[start:lp.pos]
+	if num == "" {
+		return nil, lp.errorf("unexpected %q", lp.s[lp.pos])
+	}
+	if num == "-0" {
+		return math.Copysign(0, -1), nil
+	}
+	if !strings.ContainsAny(num, ".Ee") {
+		if n, err := strconv.ParseInt(num, 10, 64); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseUint(num, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		lp.pos = start
+		return nil, lp.errorf("invalid number %q", num)
+	}
+	return f, nil
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gelfr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Compression is how messages are compressed over UDP.
+type Compression int
+
+const (
+	// CompressionNone sends messages as they are.
+	CompressionNone Compression = iota
+	// CompressionGzip compresses messages with gzip.
+	CompressionGzip
+	// CompressionZlib compresses messages with zlib.
+	CompressionZlib
+)
+
+// ConnOptions carries parameters which influence the way messages are sent.
+type ConnOptions struct {
+	// Compression tells the Conn how to compress messages over UDP.  GELF
+	// does not allow compression over TCP, so it is ignored there.
+	Compression Compression
+
+	// ChunkSize is the largest UDP datagram sent.  Larger messages are split
+	// into chunks.  If not specified, 1420 bytes is used, which fits into
+	// the MTU of most networks.  Within a LAN, 8192 bytes is usually fine.
+	ChunkSize int
+}
+
+// GELF chunking, for UDP.
+const (
+	chunkHeaderSize = 12 // magic, message ID, sequence number and count
+	maxChunks       = 128
+)
+
+// chunkMagic starts each chunk.
+var chunkMagic = []byte{0x1e, 0x0f}
+
+// timeout limits how long connecting and each write may take, so that a
+// stuck server does not block logging forever.
+const timeout = 10 * time.Second
+
+// Conn sends GELF messages to Graylog.  Each call to Write sends one message.
+// Over UDP, messages are compressed as ConnOptions.Compression tells, and
+// split into chunks if they are larger than ConnOptions.ChunkSize.  Over TCP,
+// messages are terminated by a null byte.
+//
+// If a write fails, Conn reconnects and tries once more.  If that fails too,
+// the message is lost, and the next Write reconnects again.  A Conn is safe
+// for concurrent use.
+type Conn struct {
+	network string
+	addr    string
+	opts    ConnOptions
+	stream  bool // whether messages are null-byte framed
+
+	mu     sync.Mutex
+	conn   net.Conn // nil while disconnected
+	closed bool
+}
+
+// Dial connects to a GELF input at addr, over network, which is "udp",
+// "udp4", "udp6", "tcp", "tcp4" or "tcp6".
+func Dial(network, addr string, opts ConnOptions) (*Conn, error) {
+	c := &Conn{network: network, addr: addr, opts: opts}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		c.stream = true
+	case "udp", "udp4", "udp6":
+	default:
+		return nil, fmt.Errorf("unsupported network %q", network)
+	}
+	if c.opts.ChunkSize == 0 {
+		c.opts.ChunkSize = 1420
+	}
+	if c.opts.ChunkSize <= chunkHeaderSize {
+		return nil, fmt.Errorf("chunk size %d is too small", c.opts.ChunkSize)
+	}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect (re)connects c.  It must be called with c.mu held, or before c is
+// shared.
+func (c *Conn) connect() error {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	conn, err := net.DialTimeout(c.network, c.addr, timeout)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+// Write sends one message.
+func (c *Conn) Write(msg []byte) (int, error) {
+	packets, err := c.packets(msg)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.conn == nil || attempt > 0 {
+			if err = c.connect(); err != nil {
+				continue
+			}
+		}
+		if err = c.send(packets); err == nil {
+			return len(msg), nil
+		}
+	}
+	return 0, err
+}
+
+// packets returns what to write for one message: the framed message for TCP,
+// and the datagrams for UDP.
+func (c *Conn) packets(msg []byte) ([][]byte, error) {
+	if c.stream {
+		frame := make([]byte, 0, len(msg)+1)
+		frame = append(frame, msg...)
+		return [][]byte{append(frame, 0)}, nil
+	}
+	msg, err := c.compress(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) <= c.opts.ChunkSize {
+		return [][]byte{msg}, nil
+	}
+	size := c.opts.ChunkSize - chunkHeaderSize
+	count := (len(msg) + size - 1) / size
+	if count > maxChunks {
+		return nil, fmt.Errorf("message of %d bytes needs %d chunks, more than the %d allowed", len(msg), count, maxChunks)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunk := make([]byte, 0, chunkHeaderSize+end-i*size)
+		chunk = append(chunk, chunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunks = append(chunks, append(chunk, msg[i*size:end]...))
+	}
+	return chunks, nil
+}
+
+// compress compresses a message for UDP.
+func (c *Conn) compress(msg []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch c.opts.Compression {
+	case CompressionNone:
+		return msg, nil
+	case CompressionGzip:
+		w = gzip.NewWriter(&buf)
+	case CompressionZlib:
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unknown compression %d", c.opts.Compression)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// send writes the packets of one message to c.conn, which must not be nil.
+func (c *Conn) send(packets [][]byte) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	for _, p := range packets {
+		if _, err := c.conn.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection.  Writes after Close fail with net.ErrClosed.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gelfr
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decompress decompresses a GELF message by its magic bytes, as Graylog does.
+func decompress(msg []byte) (string, error) {
+	var r io.Reader
+	switch {
+	case bytes.HasPrefix(msg, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(msg))
+		if err != nil {
+			return "", err
+		}
+		r = gz
+	case len(msg) > 0 && msg[0] == 0x78:
+		z, err := zlib.NewReader(bytes.NewReader(msg))
+		if err != nil {
+			return "", err
+		}
+		r = z
+	default:
+		return string(msg), nil
+	}
+	data, err := io.ReadAll(r)
+	return string(data), err
+}
+
+// udpServer reassembles the chunked and compressed messages it receives and
+// sends them to msgs.  It also counts the datagrams.
+func udpServer(t *testing.T) (net.PacketConn, <-chan string, <-chan int) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	msgs := make(chan string, 100)
+	datagrams := make(chan int, 1000)
+	go func() {
+		pending := map[string][][]byte{}
+		buf := make([]byte, 64*1024)
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			datagrams <- n
+			data := append([]byte(nil), buf[:n]...)
+			if bytes.HasPrefix(data, chunkMagic) {
+				if len(data) < chunkHeaderSize {
+					t.Errorf("short chunk of %d bytes", len(data))
+					continue
+				}
+				id, seq, count := string(data[2:10]), int(data[10]), int(data[11])
+				if seq >= count || count > maxChunks {
+					t.Errorf("invalid chunk %d of %d", seq, count)
+					continue
+				}
+				chunks := pending[id]
+				if chunks == nil {
+					chunks = make([][]byte, count)
+					pending[id] = chunks
+				}
+				chunks[seq] = data[chunkHeaderSize:]
+				var whole []byte
+				for _, c := range chunks {
+					if c == nil {
+						whole = nil
+						break
+					}
+					whole = append(whole, c...)
+				}
+				if whole == nil {
+					continue
+				}
+				delete(pending, id)
+				data = whole
+			}
+			msg, err := decompress(data)
+			if err != nil {
+				t.Errorf("decompress: %v", err)
+				continue
+			}
+			msgs <- msg
+		}
+	}()
+	return pc, msgs, datagrams
+}
+
+// tcpServer accepts connections and sends the null-terminated messages it
+// reads to msgs.  Each connection is handled by handle, if set, before its
+// messages are read.
+func tcpServer(t *testing.T, handle func(i int, conn net.Conn) bool) (net.Listener, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	msgs := make(chan string, 100)
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if handle != nil && !handle(i, conn) {
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					msg, err := r.ReadString(0)
+					if err != nil {
+						return
+					}
+					msgs <- strings.TrimSuffix(msg, "\x00")
+				}
+			}()
+		}
+	}()
+	return ln, msgs
+}
+
+func receive(t *testing.T, msgs <-chan string, expect ...string) {
+	t.Helper()
+	for _, e := range expect {
+		select {
+		case got := <-msgs:
+			if got != e {
+				t.Errorf("expected %q, got %q", e, got)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for %q", e)
+		}
+	}
+}
+
+func dial(t *testing.T, network, addr string, opts ConnOptions) *Conn {
+	t.Helper()
+	c, err := Dial(network, addr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestConnUDP(t *testing.T) {
+	// Compressed, a long message of repeated text is smaller than a chunk,
+	// so random text is used where chunks are expected.
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 6000)
+	for i := range random {
+		random[i] = byte('a' + rnd.Intn(26))
+	}
+	long := prefix + `"short_message":"` + string(random) + `","timestamp":1136239445.123,"level":6}`
+
+	testCases := []struct {
+		name        string
+		compression Compression
+	}{
+		{"none", CompressionNone},
+		{"gzip", CompressionGzip},
+		{"zlib", CompressionZlib},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pc, msgs, datagrams := udpServer(t)
+			c := dial(t, "udp", pc.LocalAddr().String(), ConnOptions{Compression: tc.compression, ChunkSize: 1000})
+			log := New(c, testOptions)
+			log.Info("short", "k", "v")
+			receive(t, msgs, prefix+`"short_message":"short","timestamp":1136239445.123,"level":6,"_k":"v"}`)
+			if n := <-datagrams; n > 1000 {
+				t.Errorf("datagram of %d bytes exceeds the chunk size", n)
+			}
+
+			log.Info(string(random))
+			receive(t, msgs, long)
+			chunks := 0
+			for len(datagrams) > 0 {
+				if n := <-datagrams; n > 1000 {
+					t.Errorf("datagram of %d bytes exceeds the chunk size", n)
+				}
+				chunks++
+			}
+			if tc.compression == CompressionNone && chunks != 7 {
+				t.Errorf("expected 7 chunks, got %d", chunks)
+			}
+			if chunks < 2 {
+				t.Errorf("expected the message to be chunked, got %d datagrams", chunks)
+			}
+		})
+	}
+}
+
+func TestConnTooManyChunks(t *testing.T) {
+	pc, _, _ := udpServer(t)
+	c := dial(t, "udp", pc.LocalAddr().String(), ConnOptions{ChunkSize: 100})
+	if _, err := c.Write(make([]byte, 88*maxChunks)); err != nil {
+		t.Errorf("expected %d chunks to be fine, got %v", maxChunks, err)
+	}
+	if _, err := c.Write(make([]byte, 88*maxChunks+1)); err == nil {
+		t.Error("expected an error for too many chunks")
+	}
+}
+
+func TestConnTCP(t *testing.T) {
+	ln, msgs := tcpServer(t, nil)
+	// Compression is ignored over TCP.
+	log := New(dial(t, "tcp", ln.Addr().String(), ConnOptions{Compression: CompressionGzip}), testOptions)
+	log.Info("one")
+	log.Info("multi\nline")
+	receive(t, msgs,
+		prefix+`"short_message":"one","timestamp":1136239445.123,"level":6}`,
+		prefix+`"short_message":"multi\nline","timestamp":1136239445.123,"level":6}`)
+}
+
+func TestConnReconnect(t *testing.T) {
+	// The server drops the first connection.
+	ln, msgs := tcpServer(t, func(i int, conn net.Conn) bool {
+		if i == 0 {
+			conn.Close()
+			return false
+		}
+		return true
+	})
+	c := dial(t, "tcp", ln.Addr().String(), ConnOptions{})
+
+	// Writes to the dropped connection may succeed before the client
+	// notices, so keep writing until one arrives.
+	deadline := time.After(10 * time.Second)
+	for {
+		if _, err := c.Write([]byte("retry")); err != nil {
+			t.Fatalf("write was not retried: %v", err)
+		}
+		select {
+		case msg := <-msgs:
+			if msg != "retry" {
+				t.Errorf("expected %q, got %q", "retry", msg)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("timed out waiting for the reconnect")
+		}
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	pc, _, _ := udpServer(t)
+	c := dial(t, "udp", pc.LocalAddr().String(), ConnOptions{})
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if _, err := c.Write([]byte("late")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed, got %v", err)
+	}
+}
+
+func TestConnDialError(t *testing.T) {
+	testCases := []struct {
+		name    string
+		network string
+		opts    ConnOptions
+	}{
+		{"network", "unix", ConnOptions{}},
+		{"chunk size", "udp", ConnOptions{ChunkSize: chunkHeaderSize}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Dial(tc.network, "127.0.0.1:12201", tc.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gelfr_test
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr/gelfr"
+)
+
+// stdout writes each message on its own line.
+type stdout struct{}
+
+func (stdout) Write(msg []byte) (int, error) {
+	return fmt.Println(string(msg))
+}
+
+func ExampleNew() {
+	log := gelfr.New(stdout{}, gelfr.Options{
+		Host:  "example.com",
+		Clock: func() time.Time { return time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC) },
+	})
+	log = log.WithName("server")
+	log.Info("started", "port", 8080)
+	log.Error(os.ErrNotExist, "failed to open", "path", "/etc/example.conf")
+	// Output:
+	// {"version":"1.1","host":"example.com","short_message":"started","timestamp":1136214245,"level":6,"_logger":"server","_port":8080}
+	// {"version":"1.1","host":"example.com","short_message":"failed to open","full_message":"file does not exist","timestamp":1136214245,"level":3,"_logger":"server","_error":"file does not exist","_path":"/etc/example.conf"}
+}
+
+func ExampleDial() {
+	// Send to a GELF UDP input, or to a TCP input with
+	// gelfr.Dial("tcp", "graylog.example.com:12201", gelfr.ConnOptions{}).
+	conn, err := gelfr.Dial("udp", "graylog.example.com:12201", gelfr.ConnOptions{
+		Compression: gelfr.CompressionGzip,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer conn.Close()
+
+	log := gelfr.New(conn, gelfr.Options{})
+	log.Info("hello", "to", "graylog")
+}
@@ -0,0 +1,279 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gelfr implements github.com/go-logr/logr.Logger in terms of GELF
+// 1.1 messages, as read by Graylog.
+//
+// Each log line is one message, with the fields:
+//
+//	version        "1.1"
+//	host           Options.Host
+//	short_message  the message
+//	full_message   the error of Error logs, with its stack trace if it has
+//	               one, and with Options.ErrorStacks the stack of the caller
+//	timestamp      seconds since the epoch, with milliseconds
+//	level          Options.LevelSeverity of the V-level for Info logs, or 3
+//	               (error) for Error logs
+//	_logger        the logger name
+//	_error         the error of Error logs
+//
+// Key-value pairs are additional fields, with an underscore before the key.
+// Characters other than letters, digits, '_', '.' and '-' are replaced by
+// '_', and the reserved "_id" becomes "__id".  Strings and numbers are
+// written as-is, and other values as JSON strings, as rendered by funcr,
+// since GELF only allows strings and numbers.  If a key appears more than
+// once, the last value wins.
+//
+// Messages are written to an io.Writer, one message per call to Write.  Dial
+// returns a Conn, which sends them to Graylog over UDP or TCP.
+package gelfr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/internal/render"
+	"github.com/go-logr/logr/syslogr"
+)
+
+// Options carries parameters which influence the way logs are generated.
+type Options struct {
+	// LevelSeverity tells the logger which level to use for Info logs of a
+	// V-level.  If not specified, V(0) logs are syslogr.SeverityInfo and
+	// higher levels are syslogr.SeverityDebug.
+	LevelSeverity func(level int) syslogr.Severity
+
+	// Host is the host of all messages.  If not specified, os.Hostname is
+	// used.
+	Host string
+
+	// ErrorStacks tells the logger to add the stack of the goroutine which
+	// called Error to the full_message.
+	ErrorStacks bool
+
+	// Clock tells the logger how to get the current time, for the
+	// timestamp.  If not specified, time.Now is used.
+	Clock func() time.Time
+
+	// Verbosity tells the logger which V logs to write.  Higher values
+	// enable more logs.
+	Verbosity int
+}
+
+// emptyMessage is the short_message of logs without a message, which GELF
+// does not allow.
+const emptyMessage = "-"
+
+// New returns a logr.Logger which writes GELF messages to w, one message per
+// call to w.Write.  Writes are serialized, so w need not be safe for
+// concurrent use.  Errors from w are ignored.
+func New(w io.Writer, opts Options) logr.Logger {
+	if opts.LevelSeverity == nil {
+		opts.LevelSeverity = defaultLevelSeverity
+	}
+	if opts.Host == "" {
+		opts.Host, _ = os.Hostname()
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	return logr.New(&sink{
+		opts: &opts,
+		out:  &output{w: w},
+		fmtr: funcr.NewFormatterJSON(render.WithoutBuiltins(funcr.Options{StrictJSON: true})),
+	})
+}
+
+func defaultLevelSeverity(level int) syslogr.Severity {
+	if level == 0 {
+		return syslogr.SeverityInfo
+	}
+	return syslogr.SeverityDebug
+}
+
+// output serializes the messages of a logger and those derived from it.
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *output) write(msg []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, _ = o.w.Write(msg)
+}
+
+// sink implements logr.LogSink.
+type sink struct {
+	opts   *Options
+	out    *output
+	name   string
+	values []any
+	fmtr   funcr.Formatter // renders values
+}
+
+var _ logr.LogSink = &sink{}
+
+func (*sink) Init(logr.RuntimeInfo) {}
+
+func (l *sink) Enabled(level int) bool {
+	return level <= l.opts.Verbosity
+}
+
+func (l *sink) Info(level int, msg string, kvList ...any) {
+	l.log(l.opts.LevelSeverity(level), msg, nil, kvList)
+}
+
+func (l *sink) Error(err error, msg string, kvList ...any) {
+	l.log(syslogr.SeverityError, msg, err, kvList)
+}
+
+func (l sink) WithName(name string) logr.LogSink {
+	if l.name != "" {
+		l.name += "/"
+	}
+	l.name += name
+	return &l
+}
+
+func (l sink) WithValues(kvList ...any) logr.LogSink {
+	l.values = render.AppendValues(l.values, kvList)
+	return &l
+}
+
+// field is an additional field, with its value as JSON.
+type field struct {
+	name  string
+	value string
+}
+
+// log writes one message.
+func (l *sink) log(level syslogr.Severity, msg string, err error, kvList []any) {
+	if msg == "" {
+		msg = emptyMessage
+	}
+	buf := make([]byte, 0, 256)
+	buf = append(buf, `{"version":"1.1","host":`...)
+	buf = appendString(buf, l.opts.Host)
+	buf = append(buf, `,"short_message":`...)
+	buf = appendString(buf, msg)
+	if err != nil {
+		buf = append(buf, `,"full_message":`...)
+		buf = appendString(buf, l.fullMessage(err))
+	}
+	buf = append(buf, `,"timestamp":`...)
+	buf = strconv.AppendFloat(buf, float64(l.opts.Clock().UnixMilli())/1000, 'f', -1, 64)
+	buf = append(buf, `,"level":`...)
+	buf = strconv.AppendInt(buf, int64(level), 10)
+
+	var fields []field
+	index := map[string]int{}
+	add := func(key string, value any) {
+		f := field{fieldName(key), l.value(value)}
+		if i, found := index[f.name]; found {
+			fields[i] = f
+			return
+		}
+		index[f.name] = len(fields)
+		fields = append(fields, f)
+	}
+	if l.name != "" {
+		add("logger", l.name)
+	}
+	if err != nil {
+		add("error", err)
+	}
+	for _, kvs := range [][]any{l.values, kvList} {
+		for i := 0; i < len(kvs); i += 2 {
+			k := render.Key(l.fmtr, kvs[i])
+			var v any = render.NoValue
+			if i+1 < len(kvs) {
+				v = kvs[i+1]
+			}
+			add(k, v)
+		}
+	}
+	for _, f := range fields {
+		buf = append(buf, ',')
+		buf = appendString(buf, f.name)
+		buf = append(buf, ':')
+		buf = append(buf, f.value...)
+	}
+	buf = append(buf, '}')
+	l.out.write(buf)
+}
+
+// fullMessage returns the error with its stack trace, if it prints one with
+// %+v like the errors of github.com/pkg/errors, and with the stack of the
+// caller if Options.ErrorStacks is set.
+func (l *sink) fullMessage(err error) string {
+	msg := fmt.Sprintf("%+v", err)
+	if l.opts.ErrorStacks {
+		stack := make([]byte, 64<<10)
+		stack = stack[:runtime.Stack(stack, false)]
+		msg += "\n\n" + string(stack)
+	}
+	return msg
+}
+
+// value renders a value as JSON: strings and numbers as-is, and anything
+// else as a JSON string.  Values which render as JSON strings, like errors
+// and fmt.Stringers, are strings too.
+func (l *sink) value(v any) string {
+	if s, ok := v.(string); ok {
+		return string(appendString(nil, s))
+	}
+	js := render.JSON(l.fmtr, v)
+	if js != "" && (js[0] == '"' || js[0] == '-' || js[0] >= '0' && js[0] <= '9') {
+		return js
+	}
+	return string(appendString(nil, js))
+}
+
+// appendString appends s as a JSON string.
+func appendString(buf []byte, s string) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s) // strings always encode
+	return append(buf, bytes.TrimSuffix(b.Bytes(), []byte{'\n'})...)
+}
+
+// fieldName returns the name of the additional field for a key.
+func fieldName(key string) string {
+	name := make([]byte, 0, len(key)+1)
+	name = append(name, '_')
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			c = '_'
+		}
+		name = append(name, c)
+	}
+	if string(name) == "_id" {
+		return "__id"
+	}
+	return string(name)
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gelfr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/syslogr"
+)
+
+type point struct{ X, Y int }
+
+// stackError prints a stack trace with %+v, like the errors of
+// github.com/pkg/errors.
+type stackError struct{}
+
+func (stackError) Error() string { return "boom" }
+
+func (e stackError) Format(s fmt.State, verb rune) {
+	if s.Flag('+') {
+		fmt.Fprint(s, "boom\nmain.main\n\tmain.go:42")
+		return
+	}
+	fmt.Fprint(s, e.Error())
+}
+
+// messages records each write as one message.
+type messages struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (m *messages) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.msgs = append(m.msgs, string(p))
+	return len(p), nil
+}
+
+func (m *messages) get() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.msgs
+}
+
+var testOptions = Options{
+	Host: "host",
+	Clock: func() time.Time {
+		return time.Date(2006, time.January, 2, 15, 4, 5, 123456789, time.FixedZone("", -7*3600))
+	},
+}
+
+// prefix is the start of each message logged with testOptions.
+const prefix = `{"version":"1.1","host":"host",`
+
+func TestLogger(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   func(*Options)
+		log    func(*messages, Options)
+		expect []string
+	}{{
+		name: "info",
+		log: func(m *messages, opts Options) {
+			log := New(m, opts)
+			log.Info("hello", "k", "v", "n", 1, "f", 0.5)
+			log.Info("")
+			log.V(1).Info("not logged")
+		},
+		expect: []string{
+			prefix + `"short_message":"hello","timestamp":1136239445.123,"level":6,"_k":"v","_n":1,"_f":0.5}`,
+			prefix + `"short_message":"-","timestamp":1136239445.123,"level":6}`,
+		},
+	}, {
+		name: "verbosity",
+		opts: func(opts *Options) { opts.Verbosity = 2 },
+		log: func(m *messages, opts Options) {
+			log := New(m, opts)
+			log.V(1).Info("one")
+			log.V(2).Info("two")
+			log.V(3).Info("three")
+		},
+		expect: []string{
+			prefix + `"short_message":"one","timestamp":1136239445.123,"level":7}`,
+			prefix + `"short_message":"two","timestamp":1136239445.123,"level":7}`,
+		},
+	}, {
+		name: "level severity",
+		opts: func(opts *Options) {
+			opts.Verbosity = 1
+			opts.LevelSeverity = func(level int) syslogr.Severity { return syslogr.SeverityNotice + syslogr.Severity(level) }
+		},
+		log: func(m *messages, opts Options) {
+			log := New(m, opts)
+			log.Info("zero")
+			log.V(1).Info("one")
+		},
+		expect: []string{
+			prefix + `"short_message":"zero","timestamp":1136239445.123,"level":5}`,
+			prefix + `"short_message":"one","timestamp":1136239445.123,"level":6}`,
+		},
+	}, {
+		name: "error",
+		log: func(m *messages, opts Options) {
+			log := New(m, opts)
+			log.Error(os.ErrNotExist, "failed", "path", "/tmp")
+			log.Error(stackError{}, "crashed")
+			log.Error(nil, "no error")
+		},
+		expect: []string{
+			prefix + `"short_message":"failed","full_message":"file does not exist","timestamp":1136239445.123,"level":3,"_error":"file does not exist","_path":"/tmp"}`,
+			prefix + `"short_message":"crashed","full_message":"boom\nmain.main\n\tmain.go:42","timestamp":1136239445.123,"level":3,"_error":"boom"}`,
+			prefix + `"short_message":"no error","timestamp":1136239445.123,"level":3}`,
+		},
+	}, {
+		name: "names and values",
+		log: func(m *messages, opts Options) {
+			log := New(m, opts).WithName("a").WithValues("k", "v1")
+			log.WithName("b").Info("hello", "x", 1)
+			log.WithValues("k", "v2").Info("override")
+		},
+		expect: []string{
+			prefix + `"short_message":"hello","timestamp":1136239445.123,"level":6,"_logger":"a/b","_k":"v1","_x":1}`,
+			prefix + `"short_message":"override","timestamp":1136239445.123,"level":6,"_logger":"a","_k":"v2"}`,
+		},
+	}, {
+		name: "field names",
+		log: func(m *messages, opts Options) {
+			New(m, opts).Info("hello", "id", 1, "a b", 2, "ü", 3, "dotted.key-x", 4, 5, 6, "odd")
+		},
+		expect: []string{
+			prefix + `"short_message":"hello","timestamp":1136239445.123,"level":6,"__id":1,"_a_b":2,"___":3,"_dotted.key-x":4,"__non-string-key__5_":6,"_odd":"<no-value>"}`,
+		},
+	}, {
+		name: "values",
+		log: func(m *messages, opts Options) {
+			New(m, opts).Info("hello",
+				"bool", true,
+				"nil", nil,
+				"struct", point{1, 2},
+				"slice", []int{1, 2},
+				"html", "<a&b>",
+				"duration", time.Second)
+		},
+		expect: []string{
+			prefix + `"short_message":"hello","timestamp":1136239445.123,"level":6,"_bool":"true","_nil":"null","_struct":"{\"X\":1,\"Y\":2}","_slice":"[1,2]","_html":"<a&b>","_duration":"1s"}`,
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := testOptions
+			if tc.opts != nil {
+				tc.opts(&opts)
+			}
+			var m messages
+			tc.log(&m, opts)
+			if got := m.get(); !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(tc.expect, "\n"), strings.Join(got, "\n"))
+			}
+			for _, msg := range m.get() {
+				if !json.Valid([]byte(msg)) {
+					t.Errorf("invalid JSON: %s", msg)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorStacks(t *testing.T) {
+	opts := testOptions
+	opts.ErrorStacks = true
+	var m messages
+	New(&m, opts).Error(errors.New("boom"), "failed")
+	var msg struct {
+		FullMessage string `json:"full_message"`
+	}
+	if err := json.Unmarshal([]byte(m.get()[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(msg.FullMessage, "boom\n\ngoroutine ") || !strings.Contains(msg.FullMessage, "TestErrorStacks") {
+		t.Errorf("expected the error and the stack of the caller, got %q", msg.FullMessage)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	var m messages
+	New(&m, Options{}).Info("hello")
+	var msg struct {
+		Host      string  `json:"host"`
+		Timestamp float64 `json:"timestamp"`
+	}
+	if err := json.Unmarshal([]byte(m.get()[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if host, _ := os.Hostname(); msg.Host != host {
+		t.Errorf("expected host %q, got %q", host, msg.Host)
+	}
+	if d := time.Since(time.UnixMilli(int64(msg.Timestamp * 1000))); d < -time.Second || d > time.Minute {
+		t.Errorf("timestamp is %v off", d)
+	}
+}
+
+func TestLoggerConcurrent(t *testing.T) {
+	var m messages
+	log := New(&m, testOptions)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l := log.WithValues("goroutine", i)
+			for j := 0; j < 100; j++ {
+				l.Info("hello", "j", j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := len(m.get()); n != 1000 {
+		t.Errorf("expected 1000 messages, got %d", n)
+	}
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package batch holds what the clients in this module which send logs in
+// batches have in common: a queue which a background goroutine empties in
+// batches, the backoff between attempts to send them, and for HTTP servers,
+// which failures to retry and how long the server asks to wait.
+package batch
+
+import (
+	"sync"
+	"time"
+)
+
+// Options carries parameters which influence the way items are batched.
+type Options struct {
+	// Size is the most items in a batch.
+	Size int
+
+	// Bytes is the most bytes of items in a batch, as told by the size
+	// function given to New, or 0 for no limit.  A batch has at least one
+	// item, however large.
+	Bytes int
+
+	// Timeout is the longest an item waits for a batch to fill.
+	Timeout time.Duration
+
+	// QueueSize is how many items can wait for a batch.
+	QueueSize int
+}
+
+// Batcher queues items and sends them in batches from a background goroutine:
+// when a batch is full, when its oldest item has waited for Options.Timeout,
+// on Flush, and on Close.  It is safe for concurrent use.
+type Batcher[T any] struct {
+	opts Options
+	size func(T) int
+	send func(batch []T)
+
+	queue   chan T
+	flushes chan chan struct{}
+	done    chan struct{} // closed by Close
+	exited  chan struct{} // closed when run returns
+	once    sync.Once
+}
+
+// New returns a Batcher which calls send with each batch, one at a time.  The
+// batch is reused afterwards, so send must not keep it.  size tells the size
+// of an item, for Options.Bytes, and may be nil without it.
+func New[T any](opts Options, size func(T) int, send func(batch []T)) *Batcher[T] {
+	b := &Batcher[T]{
+		opts:    opts,
+		size:    size,
+		send:    send,
+		queue:   make(chan T, opts.QueueSize),
+		flushes: make(chan chan struct{}),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Add queues an item without blocking, and tells whether there was room for
+// it.  After Close, there never is.
+func (b *Batcher[T]) Add(item T) bool {
+	select {
+	case <-b.done:
+		return false
+	default:
+	}
+	select {
+	case b.queue <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+// Flush sends the queued items, and waits until send returned for them.
+func (b *Batcher[T]) Flush() {
+	ack := make(chan struct{})
+	select {
+	case b.flushes <- ack:
+		<-ack
+	case <-b.exited:
+	}
+}
+
+// Close sends the queued items, and waits until send returned for them.
+// Waits in send are cut short.
+func (b *Batcher[T]) Close() {
+	b.once.Do(func() {
+		close(b.done)
+		<-b.exited
+	})
+}
+
+// Wait waits for d, for send to wait between attempts, and tells whether that
+// was before Close.
+func (b *Batcher[T]) Wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-b.done:
+		return false
+	}
+}
+
+// maxRetryAfter is the longest Retry waits for the RetryAfter of a
+// StatusError, so that a server cannot hold up a client indefinitely.
+const maxRetryAfter = 10 * time.Minute
+
+// Retry calls try with items, and again with what it returns to retry, until
+// nothing is left, maxRetries retries were made, or Close.  If try fails, all
+// items are retried if the error is Retryable, and none otherwise.  Retries
+// wait for backoff, or for the RetryAfter of a StatusError, which may be
+// longer than backoff.Max, up to maxRetryAfter.  Retry returns the items which
+// were not sent.
+func (b *Batcher[T]) Retry(items []T, maxRetries int, backoff Backoff, try func(items []T) (retry []T, err error)) []T {
+	for attempt := 0; ; attempt++ {
+		retry, err := try(items)
+		wait := backoff.Next()
+		if err != nil {
+			if !Retryable(err) {
+				return items
+			}
+			retry = items
+			if d := retryAfter(err); d > 0 {
+				wait = d
+				if wait > maxRetryAfter {
+					wait = maxRetryAfter
+				}
+			}
+		}
+		if len(retry) == 0 {
+			return nil
+		}
+		items = retry
+		if attempt >= maxRetries {
+			return items
+		}
+		if !b.Wait(wait) {
+			return items
+		}
+	}
+}
+
+// run batches items until Close.
+func (b *Batcher[T]) run() {
+	defer close(b.exited)
+	batch := make([]T, 0, b.opts.Size)
+	bytes := 0
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	send := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+		if len(batch) > 0 {
+			b.send(batch)
+			batch, bytes = batch[:0], 0
+		}
+	}
+	add := func(item T) {
+		n := 0
+		if b.opts.Bytes > 0 {
+			n = b.size(item)
+			if len(batch) > 0 && bytes+n > b.opts.Bytes {
+				send()
+			}
+		}
+		batch = append(batch, item)
+		bytes += n
+		if len(batch) == b.opts.Size || b.opts.Bytes > 0 && bytes >= b.opts.Bytes {
+			send()
+		}
+	}
+	// drain sends everything which is queued right now.
+	drain := func() {
+		for {
+			select {
+			case item := <-b.queue:
+				add(item)
+			default:
+				send()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case item := <-b.queue:
+			add(item)
+			if len(batch) > 0 && timer == nil {
+				timer = time.NewTimer(b.opts.Timeout)
+				timeout = timer.C
+			}
+		case <-timeout:
+			send()
+		case ack := <-b.flushes:
+			drain()
+			close(ack)
+		case <-b.done:
+			drain()
+			return
+		}
+	}
+}
+
+// Backoff is an exponential backoff: each wait is twice the one before, from
+// Min up to Max.
+type Backoff struct {
+	Min, Max time.Duration
+	next     time.Duration
+}
+
+// Next returns how long to wait before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	if b.next < b.Min {
+		b.next = b.Min
+	}
+	d := b.next
+	if b.next *= 2; b.next > b.Max {
+		b.next = b.Max
+	}
+	return d
+}
+
+// Reset starts over from Min, after an attempt which succeeded.
+func (b *Backoff) Reset() {
+	b.next = b.Min
+}
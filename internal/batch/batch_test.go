@@ -0,0 +1,287 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records the batches it is sent.
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (r *recorder) send(batch []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]string(nil), batch...))
+}
+
+func (r *recorder) get() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.batches
+}
+
+func TestBatcher(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   Options
+		items  []string
+		expect [][]string
+	}{{
+		name:   "size",
+		opts:   Options{Size: 2},
+		items:  []string{"a", "b", "c", "d", "e"},
+		expect: [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+	}, {
+		name:   "bytes",
+		opts:   Options{Size: 10, Bytes: 4},
+		items:  []string{"a", "bb", "ccc", "dddddd", "e", "fff"},
+		expect: [][]string{{"a", "bb"}, {"ccc"}, {"dddddd"}, {"e", "fff"}},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Timeout = time.Hour
+			tc.opts.QueueSize = len(tc.items)
+			r := &recorder{}
+			b := New(tc.opts, func(s string) int { return len(s) }, r.send)
+			defer b.Close()
+			for _, item := range tc.items {
+				if !b.Add(item) {
+					t.Fatalf("no room for %s", item)
+				}
+			}
+			b.Flush()
+			if got := r.get(); !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("expected %q, got %q", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestBatcherTimeout(t *testing.T) {
+	r := &recorder{}
+	b := New(Options{Size: 10, Timeout: 10 * time.Millisecond, QueueSize: 10}, nil, r.send)
+	defer b.Close()
+	b.Add("a")
+	b.Add("b")
+	for deadline := time.Now().Add(10 * time.Second); len(r.get()) == 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the batch")
+		}
+	}
+	if expect := [][]string{{"a", "b"}}; !reflect.DeepEqual(r.get(), expect) {
+		t.Errorf("expected %q, got %q", expect, r.get())
+	}
+}
+
+func TestBatcherClose(t *testing.T) {
+	r := &recorder{}
+	b := New(Options{Size: 10, Timeout: time.Hour, QueueSize: 1}, nil, r.send)
+	if !b.Add("a") {
+		t.Fatal("no room for a")
+	}
+	b.Close()
+	if expect := [][]string{{"a"}}; !reflect.DeepEqual(r.get(), expect) {
+		t.Errorf("expected %q, got %q", expect, r.get())
+	}
+	if b.Add("b") {
+		t.Error("expected no room after Close")
+	}
+	b.Flush() // returns at once
+	b.Close()
+	if b.Wait(time.Hour) {
+		t.Error("expected Wait to be cut short after Close")
+	}
+}
+
+func TestBatcherQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	b := New(Options{Size: 1, Timeout: time.Hour, QueueSize: 1}, nil, func([]string) { <-release })
+	defer b.Close()
+	added := 0
+	for i := 0; i < 10; i++ {
+		if b.Add("x") {
+			added++
+		}
+	}
+	close(release)
+	// One item may be sent already, and one queued.
+	if added == 0 || added > 2 {
+		t.Errorf("expected room for one or two items, got %d", added)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errNetwork := errors.New("network error")
+	testCases := []struct {
+		name    string
+		errs    []error    // for each try, or nil
+		retries [][]string // for each try
+		tries   int
+		dropped []string
+	}{{
+		name:  "success",
+		tries: 1,
+	}, {
+		name:  "network error",
+		errs:  []error{errNetwork, errNetwork},
+		tries: 3,
+	}, {
+		name:    "too many errors",
+		errs:    []error{errNetwork, errNetwork, errNetwork, errNetwork},
+		tries:   3,
+		dropped: []string{"a", "b"},
+	}, {
+		name:    "not retryable",
+		errs:    []error{&StatusError{Code: http.StatusBadRequest}},
+		tries:   1,
+		dropped: []string{"a", "b"},
+	}, {
+		name:  "retry after",
+		errs:  []error{&StatusError{Code: http.StatusTooManyRequests, RetryAfter: time.Millisecond}},
+		tries: 2,
+	}, {
+		name:    "some items",
+		retries: [][]string{{"b"}, {"b"}, {"b"}},
+		tries:   3,
+		dropped: []string{"b"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := New[string](Options{}, nil, nil)
+			defer b.Close()
+			tries := 0
+			dropped := b.Retry([]string{"a", "b"}, 2, Backoff{Min: time.Millisecond, Max: time.Millisecond}, func(items []string) ([]string, error) {
+				defer func() { tries++ }()
+				if tries < len(tc.errs) {
+					return nil, tc.errs[tries]
+				}
+				if tries < len(tc.retries) {
+					return tc.retries[tries], nil
+				}
+				return nil, nil
+			})
+			if tries != tc.tries {
+				t.Errorf("expected %d tries, got %d", tc.tries, tries)
+			}
+			if !reflect.DeepEqual(dropped, tc.dropped) {
+				t.Errorf("expected %q to be dropped, got %q", tc.dropped, dropped)
+			}
+		})
+	}
+}
+
+func TestRetryAfterLongerThanMax(t *testing.T) {
+	b := New[string](Options{}, nil, nil)
+	defer b.Close()
+	const retryAfter = 50 * time.Millisecond
+	var times []time.Time
+	b.Retry([]string{"a"}, 1, Backoff{Min: time.Millisecond, Max: time.Millisecond}, func(items []string) ([]string, error) {
+		times = append(times, time.Now())
+		if len(times) == 1 {
+			return nil, &StatusError{Code: http.StatusServiceUnavailable, RetryAfter: retryAfter}
+		}
+		return nil, nil
+	})
+	if len(times) != 2 {
+		t.Fatalf("expected 2 tries, got %d", len(times))
+	}
+	if d := times[1].Sub(times[0]); d < retryAfter {
+		t.Errorf("expected to wait for Retry-After, waited %v", d)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: 5 * time.Second}
+	var got []time.Duration
+	for i := 0; i < 5; i++ {
+		got = append(got, b.Next())
+	}
+	b.Reset()
+	got = append(got, b.Next())
+	expect := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, time.Second}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected %v, got %v", expect, got)
+	}
+}
+
+func TestPost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "text/plain" || r.Header.Get("X-Test") != "yes" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("busy") != "" {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("busy\n"))
+			return
+		}
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+	req := Request{
+		Client:      server.Client(),
+		URL:         server.URL,
+		Server:      "test server",
+		ContentType: "text/plain",
+		Headers:     map[string]string{"X-Test": "yes"},
+		Timeout:     10 * time.Second,
+		MaxResponse: 4,
+	}
+	if data, err := req.Post(nil); err != nil || string(data) != "0123" {
+		t.Errorf("expected the start of the response, got %q, %v", data, err)
+	}
+
+	req.URL += "?busy=1"
+	_, err := req.Post(nil)
+	var serr *StatusError
+	if !errors.As(err, &serr) || serr.Code != http.StatusServiceUnavailable || serr.RetryAfter != 3*time.Second {
+		t.Fatalf("expected a StatusError with a Retry-After, got %#v", err)
+	}
+	if expect := "test server returned 503 Service Unavailable: busy"; err.Error() != expect {
+		t.Errorf("expected %q, got %q", expect, err.Error())
+	}
+	if !Retryable(err) {
+		t.Error("expected the error to be retryable")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := ParseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("expected 3s, got %v", d)
+	}
+	if d := ParseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); d <= 0 || d > time.Minute {
+		t.Errorf("expected up to a minute, got %v", d)
+	}
+	for _, value := range []string{"", "-1", "soon"} {
+		if d := ParseRetryAfter(value); d != 0 {
+			t.Errorf("%q: expected 0, got %v", value, d)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Request describes the requests which send batches to an HTTP server.
+type Request struct {
+	// Client sends the requests.
+	Client *http.Client
+
+	// URL is where the requests are posted.
+	URL string
+
+	// Server names the server in errors, like "bulk API".
+	Server string
+
+	// ContentType is the Content-Type of the requests.
+	ContentType string
+
+	// Headers are additional headers of the requests.
+	Headers map[string]string
+
+	// Timeout limits each request, including reading the response.
+	Timeout time.Duration
+
+	// MaxResponse limits how much of a response is read.
+	MaxResponse int64
+}
+
+// Post sends one request, and returns the body of the response.  A status
+// other than 2xx is returned as a *StatusError.
+func (r *Request) Post(body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", r.ContentType)
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, r.MaxResponse))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{
+			Server:     r.Server,
+			Code:       resp.StatusCode,
+			Body:       string(bytes.TrimSpace(data)),
+			RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return data, nil
+}
+
+// StatusError is a response with an unexpected status code.
+type StatusError struct {
+	Server     string
+	Code       int
+	Body       string
+	RetryAfter time.Duration // from the Retry-After header, or 0
+}
+
+func (err *StatusError) Error() string {
+	if err.Body == "" {
+		return fmt.Sprintf("%s returned %d %s", err.Server, err.Code, http.StatusText(err.Code))
+	}
+	return fmt.Sprintf("%s returned %d %s: %s", err.Server, err.Code, http.StatusText(err.Code), err.Body)
+}
+
+// RetryableStatus tells whether a request which failed with status should be
+// retried.
+func RetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Retryable tells whether a request which failed with err should be retried:
+// if it is a StatusError with a RetryableStatus, or another error, like a
+// network error.
+func Retryable(err error) bool {
+	var serr *StatusError
+	if !errors.As(err, &serr) {
+		return true
+	}
+	return RetryableStatus(serr.Code)
+}
+
+// retryAfter returns how long the server asked to wait after err.
+func retryAfter(err error) time.Duration {
+	var serr *StatusError
+	if errors.As(err, &serr) {
+		return serr.RetryAfter
+	}
+	return 0
+}
+
+// ParseRetryAfter parses a Retry-After header, in seconds or as an HTTP date.
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package render holds helpers for the LogSinks in this module which render
+// values with funcr, but lay out log lines themselves, and a LogSink for those
+// which send the JSON objects rendered by funcr elsewhere.  The Formatters
+// given to the helpers must be JSON Formatters made with WithoutBuiltins,
+// which render nothing but the key-value pairs.
+package render
+
+import (
+	"encoding/json"
+
+	"github.com/go-logr/logr/funcr"
+)
+
+// NoValue is the value of a key without one, as in funcr.
+const NoValue = "<no-value>"
+
+// WithoutBuiltins returns opts with all of funcr's builtins disabled, so that
+// a Formatter made with them renders only key-value pairs.
+func WithoutBuiltins(opts funcr.Options) funcr.Options {
+	keys := make(map[funcr.Builtin]string, int(funcr.BuiltinSequence)+1)
+	for b := funcr.BuiltinLogger; b <= funcr.BuiltinSequence; b++ {
+		keys[b] = ""
+	}
+	opts.BuiltinKeys = keys
+	return opts
+}
+
+// JSON renders a value as JSON, as f renders the value of a key-value pair.
+func JSON(f funcr.Formatter, v any) string {
+	_, obj := f.FormatInfo(0, "", []any{"", v})
+	return obj[len(`{"":`) : len(obj)-1]
+}
+
+// Key returns a key as f renders it: strings as-is, and other keys as a short
+// snippet, like "<non-string-key: 42>".
+func Key(f funcr.Formatter, k any) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	_, obj := f.FormatInfo(0, "", []any{k, nil})
+	var s string
+	if err := json.Unmarshal([]byte(obj[1:len(obj)-len(`:null}`)]), &s); err != nil {
+		return obj // not expected with StrictJSON
+	}
+	return s
+}
+
+// Text renders a value as text: strings as-is, and anything else as JSON.
+// Values which render as JSON strings, like errors and fmt.Stringers, are
+// unquoted.
+func Text(f funcr.Formatter, v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	js := JSON(f, v)
+	if js != "" && js[0] == '"' {
+		var s string
+		if err := json.Unmarshal([]byte(js), &s); err == nil {
+			return s
+		}
+	}
+	return js
+}
+
+// AppendValues returns values with kvList appended, always in a new array, so
+// that loggers derived from the same one do not overwrite each other's values.
+func AppendValues(values, kvList []any) []any {
+	n := len(values)
+	return append(values[:n:n], kvList...)
+}
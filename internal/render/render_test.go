@@ -0,0 +1,109 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+)
+
+func TestWithoutBuiltins(t *testing.T) {
+	f := funcr.NewFormatterJSON(WithoutBuiltins(funcr.Options{
+		LogCaller:      funcr.All,
+		LogTimestamp:   true,
+		LogPID:         true,
+		LogSequence:    true,
+		LogGoroutineID: true,
+		LogHostname:    true,
+	}))
+	f.AddName("name")
+	if _, got := f.FormatError(errors.New("err"), "msg", []any{"k", "v"}); got != `{"k":"v"}` {
+		t.Errorf("expected only the key-value pairs, got %s", got)
+	}
+}
+
+type stringer struct{}
+
+func (stringer) String() string { return "a \"stringer\"" }
+
+func TestText(t *testing.T) {
+	f := funcr.NewFormatterJSON(WithoutBuiltins(funcr.Options{StrictJSON: true}))
+	for _, tc := range []struct {
+		val    any
+		expect string
+	}{
+		{"a \"string\"", `a "string"`},
+		{errors.New("an error"), "an error"},
+		{stringer{}, `a "stringer"`},
+		{42, "42"},
+		{nil, "null"},
+		{[]string{"a"}, `["a"]`},
+		{map[string]int{"a": 1}, `{"a":1}`},
+	} {
+		if got := Text(f, tc.val); got != tc.expect {
+			t.Errorf("%#v: expected %q, got %q", tc.val, tc.expect, got)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	f := funcr.NewFormatterJSON(WithoutBuiltins(funcr.Options{StrictJSON: true, MaxStringLength: 4}))
+	for _, tc := range []struct {
+		val    any
+		expect string
+	}{
+		{"a string", `"a st…(+4 bytes)"`},
+		{errors.New("an error"), `"an e…(+4 bytes)"`},
+		{42, "42"},
+		{nil, "null"},
+		{[]int{1, 2}, "[1,2]"},
+		{struct{ X, Y int }{1, 2}, `{"X":1,"Y":2}`},
+	} {
+		if got := JSON(f, tc.val); got != tc.expect {
+			t.Errorf("%#v: expected %s, got %s", tc.val, tc.expect, got)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	f := funcr.NewFormatterJSON(WithoutBuiltins(funcr.Options{StrictJSON: true}))
+	for _, tc := range []struct {
+		key    any
+		expect string
+	}{
+		{"key", "key"},
+		{"", ""},
+		{42, "<non-string-key: 42>"},
+		{struct{ A, B string }{"a long value", "another"}, `<non-string-key: {"A":"a long val>`},
+	} {
+		if got := Key(f, tc.key); got != tc.expect {
+			t.Errorf("%#v: expected %q, got %q", tc.key, tc.expect, got)
+		}
+	}
+}
+
+func TestAppendValues(t *testing.T) {
+	values := make([]any, 2, 4)
+	values[0], values[1] = "k", "v"
+	a := AppendValues(values, []any{"a", 1})
+	b := AppendValues(values, []any{"b", 2})
+	if a[2] != "a" || b[2] != "b" {
+		t.Errorf("values were overwritten: %v, %v", a, b)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+)
+
+// NewSink returns a logr.LogSink which renders each log line as a JSON object
+// with funcr and opts, and passes it to write with the logger name, the names
+// given to WithName joined by '/'.  StrictJSON is always set, so that each
+// object is valid JSON.
+func NewSink(opts funcr.Options, write func(name, obj string)) logr.LogSink {
+	opts.StrictJSON = true
+	l := &sink{
+		Formatter: funcr.NewFormatterJSON(opts),
+		write:     write,
+	}
+	// For skipping sink.Info and sink.Error, as funcr's own LogSink does.
+	l.AddCallDepth(1) // via Formatter
+	return l
+}
+
+// sink implements logr.LogSink.
+type sink struct {
+	funcr.Formatter
+	name  string
+	write func(name, obj string)
+}
+
+var _ logr.LogSink = &sink{}
+var _ logr.CallDepthLogSink = &sink{}
+
+func (l sink) WithName(name string) logr.LogSink {
+	l.AddName(name) // via Formatter
+	if l.name != "" {
+		l.name += "/"
+	}
+	l.name += name
+	return &l
+}
+
+func (l sink) WithValues(kvList ...any) logr.LogSink {
+	l.AddValues(kvList) // via Formatter
+	return &l
+}
+
+func (l sink) WithCallDepth(depth int) logr.LogSink {
+	l.AddCallDepth(depth) // via Formatter
+	return &l
+}
+
+func (l *sink) Info(level int, msg string, kvList ...any) {
+	_, obj := l.FormatInfo(level, msg, kvList)
+	l.write(l.name, obj)
+}
+
+func (l *sink) Error(err error, msg string, kvList ...any) {
+	_, obj := l.FormatError(err, msg, kvList)
+	l.write(l.name, obj)
+}
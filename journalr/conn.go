@@ -0,0 +1,111 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journalr
+
+import (
+	"net"
+	"sync"
+)
+
+// DefaultSocket is where journald listens for entries in the native
+// protocol.
+const DefaultSocket = "/run/systemd/journal/socket"
+
+// Conn sends journal entries to journald.  Each call to Write sends one
+// entry, as one datagram.  On Linux, entries which are too large for a
+// datagram are written to a sealed memfd, or if that is not supported to an
+// unlinked file in /dev/shm, and its file descriptor is sent instead, as
+// journald expects.
+//
+// If a write fails, Conn reconnects and tries once more, so that entries are
+// not lost when journald restarts.  A Conn is safe for concurrent use.
+type Conn struct {
+	addr *net.UnixAddr
+
+	mu     sync.Mutex
+	conn   *net.UnixConn // nil while disconnected
+	closed bool
+}
+
+// Dial connects to journald at path, or at DefaultSocket if path is empty.
+// It fails if journald is not running, so that programs can fall back to
+// other output.
+func Dial(path string) (*Conn, error) {
+	if path == "" {
+		path = DefaultSocket
+	}
+	c := &Conn{addr: &net.UnixAddr{Name: path, Net: "unixgram"}}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect (re)connects c.  It must be called with c.mu held, or before c is
+// shared.
+func (c *Conn) connect() error {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, c.addr)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+// Write sends one entry.
+func (c *Conn) Write(entry []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.conn == nil || attempt > 0 {
+			if err = c.connect(); err != nil {
+				continue
+			}
+		}
+		if _, err = c.conn.Write(entry); err != nil {
+			err = sendLarge(c.conn, entry, err)
+		}
+		if err == nil {
+			return len(entry), nil
+		}
+	}
+	return 0, err
+}
+
+// Close closes the connection.  Writes after Close fail with net.ErrClosed.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
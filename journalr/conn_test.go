@@ -0,0 +1,119 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journalr
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// listen returns a journald stand-in at a new socket.
+func listen(t *testing.T, path string) *net.UnixConn {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix datagram sockets are not supported on Windows")
+	}
+	ln, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln
+}
+
+// receive reads one datagram, with any file descriptors sent along.
+func receive(t *testing.T, ln *net.UnixConn) (data, oob []byte) {
+	t.Helper()
+	if err := ln.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	data, oob = make([]byte, 64*1024), make([]byte, 1024)
+	n, oobn, _, _, err := ln.ReadMsgUnix(data, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data[:n], oob[:oobn]
+}
+
+func dial(t *testing.T, path string) *Conn {
+	t.Helper()
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestConn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	ln := listen(t, path)
+	log := New(dial(t, path), Options{Identifier: "app"})
+	log.Info("hello", "k", "v")
+	data, _ := receive(t, ln)
+	expect := []field{{"MESSAGE", "hello"}, {"PRIORITY", "6"}, {"SYSLOG_IDENTIFIER", "app"}, {"K", "v"}}
+	if got := parseEntry(t, data); !reflect.DeepEqual(got, expect) {
+		t.Errorf("\nexpected %q\n     got %q", expect, got)
+	}
+}
+
+func TestConnReconnect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	ln := listen(t, path)
+	c := dial(t, path)
+
+	// journald restarts.
+	ln.Close()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	ln = listen(t, path)
+
+	if _, err := c.Write([]byte("MESSAGE=again\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := receive(t, ln); string(data) != "MESSAGE=again\n" {
+		t.Errorf("wrong entry %q", data)
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	listen(t, path)
+	c := dial(t, path)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if _, err := c.Write([]byte("MESSAGE=x\n")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected %v, got %v", net.ErrClosed, err)
+	}
+}
+
+func TestDialError(t *testing.T) {
+	if _, err := Dial(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error without journald")
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journalr_test
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/journalr"
+)
+
+// stdout writes each entry, followed by an empty line.
+type stdout struct{}
+
+func (stdout) Write(entry []byte) (int, error) {
+	return fmt.Println(string(entry))
+}
+
+func ExampleNew() {
+	log := journalr.New(stdout{}, journalr.Options{Identifier: "example"})
+	log = log.WithName("server")
+	log.Info("started", "port", 8080, "tls", true)
+	log.Error(os.ErrNotExist, "failed to open", "path", "/etc/example.conf")
+	// Output:
+	// MESSAGE=started
+	// PRIORITY=6
+	// LOGGER=server
+	// SYSLOG_IDENTIFIER=example
+	// PORT=8080
+	// TLS=true
+	//
+	// MESSAGE=failed to open
+	// PRIORITY=3
+	// ERROR=file does not exist
+	// LOGGER=server
+	// SYSLOG_IDENTIFIER=example
+	// PATH=/etc/example.conf
+}
+
+func ExampleDial() {
+	var log logr.Logger
+	if conn, err := journalr.Dial(""); err == nil {
+		defer conn.Close()
+		log = journalr.New(conn, journalr.Options{LogCaller: true})
+	} else {
+		// Not running under systemd.
+		log = funcr.New(func(prefix, args string) {
+			fmt.Fprintln(os.Stderr, prefix, args)
+		}, funcr.Options{})
+	}
+	log.Info("hello", "to", "the journal")
+}
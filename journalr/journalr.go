@@ -0,0 +1,278 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package journalr implements github.com/go-logr/logr.Logger in terms of
+// systemd-journald entries, in the journal's native protocol.
+//
+// Each log line is one entry, with the fields:
+//
+//	MESSAGE            the message
+//	PRIORITY           Options.LevelPriority of the V-level for Info logs,
+//	                   or 3 (error) for Error logs
+//	ERROR              the error of Error logs
+//	LOGGER             the logger name
+//	SYSLOG_IDENTIFIER  Options.Identifier
+//	CODE_FILE          the file, line and function which called the logger
+//	CODE_LINE
+//	CODE_FUNC
+//
+// Key-value pairs are fields too, so that journalctl can match them.  Keys
+// are upper-cased and prefixed with Options.KeyPrefix, characters other than
+// A-Z, 0-9 and '_' are replaced by '_', leading underscores are removed, and
+// names are truncated to 64 characters, as the journal requires.  Keys which
+// would still not be valid, or which would be the name of one of the fields
+// above or of another field with a meaning to journald, like MESSAGE_ID, are
+// prefixed with "KEY_".  Values are strings as-is, and other values as JSON,
+// as rendered by funcr.
+//
+// Entries are written to an io.Writer, one entry per call to Write.  Dial
+// returns a Conn, which sends them to journald.
+package journalr
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/internal/render"
+	"github.com/go-logr/logr/syslogr"
+)
+
+// Options carries parameters which influence the way logs are generated.
+type Options struct {
+	// LevelPriority tells the logger which priority to use for Info logs of
+	// a V-level.  If not specified, V(0) logs are syslogr.SeverityInfo and
+	// higher levels are syslogr.SeverityDebug.
+	LevelPriority func(level int) syslogr.Severity
+
+	// Identifier is the SYSLOG_IDENTIFIER of all entries.  If not
+	// specified, the base name of the executable is used.
+	Identifier string
+
+	// KeyPrefix is prepended to the keys of key-value pairs, to keep them
+	// apart from the fields written by the logger and by other programs.
+	KeyPrefix string
+
+	// LogCaller tells the logger to write the CODE_FILE, CODE_LINE and
+	// CODE_FUNC fields.
+	LogCaller bool
+
+	// Verbosity tells the logger which V logs to write.  Higher values
+	// enable more logs.
+	Verbosity int
+}
+
+// maxFieldName is the longest field name the journal accepts.
+const maxFieldName = 64
+
+// reservedFields are the fields which the logger writes, and the other fields
+// which journald gives a meaning to.  Keys are not written as these.
+var reservedFields = map[string]bool{
+	"MESSAGE":           true,
+	"MESSAGE_ID":        true,
+	"PRIORITY":          true,
+	"ERROR":             true,
+	"ERRNO":             true,
+	"LOGGER":            true,
+	"SYSLOG_IDENTIFIER": true,
+	"SYSLOG_FACILITY":   true,
+	"SYSLOG_PID":        true,
+	"SYSLOG_TIMESTAMP":  true,
+	"SYSLOG_RAW":        true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+	"DOCUMENTATION":     true,
+	"TID":               true,
+	"INVOCATION_ID":     true,
+	"OBJECT_PID":        true,
+}
+
+// New returns a logr.Logger which writes journal entries to w, one entry per
+// call to w.Write.  Writes are serialized, so w need not be safe for
+// concurrent use.  Errors from w are ignored.
+func New(w io.Writer, opts Options) logr.Logger {
+	return logr.New(NewSink(w, opts))
+}
+
+// NewSink returns the logr.LogSink used by New, for use with other
+// logr.LogSinks.
+func NewSink(w io.Writer, opts Options) logr.LogSink {
+	if opts.LevelPriority == nil {
+		opts.LevelPriority = defaultLevelPriority
+	}
+	if opts.Identifier == "" && len(os.Args) > 0 {
+		opts.Identifier = filepath.Base(os.Args[0])
+	}
+	return &sink{
+		opts: &opts,
+		out:  &output{w: w},
+		fmtr: funcr.NewFormatterJSON(render.WithoutBuiltins(funcr.Options{StrictJSON: true})),
+	}
+}
+
+func defaultLevelPriority(level int) syslogr.Severity {
+	if level == 0 {
+		return syslogr.SeverityInfo
+	}
+	return syslogr.SeverityDebug
+}
+
+// output serializes the entries of a logger and those derived from it.
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *output) write(entry []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, _ = o.w.Write(entry)
+}
+
+// sink implements logr.LogSink.
+type sink struct {
+	opts   *Options
+	out    *output
+	name   string
+	values []any
+	depth  int
+	fmtr   funcr.Formatter // renders values
+}
+
+var _ logr.LogSink = &sink{}
+var _ logr.CallDepthLogSink = &sink{}
+
+func (l *sink) Init(info logr.RuntimeInfo) {
+	l.depth += info.CallDepth
+}
+
+func (l *sink) Enabled(level int) bool {
+	return level <= l.opts.Verbosity
+}
+
+func (l *sink) Info(level int, msg string, kvList ...any) {
+	l.log(l.opts.LevelPriority(level), msg, nil, kvList)
+}
+
+func (l *sink) Error(err error, msg string, kvList ...any) {
+	l.log(syslogr.SeverityError, msg, err, kvList)
+}
+
+func (l sink) WithName(name string) logr.LogSink {
+	if l.name != "" {
+		l.name += "/"
+	}
+	l.name += name
+	return &l
+}
+
+func (l sink) WithValues(kvList ...any) logr.LogSink {
+	l.values = render.AppendValues(l.values, kvList)
+	return &l
+}
+
+func (l sink) WithCallDepth(depth int) logr.LogSink {
+	l.depth += depth
+	return &l
+}
+
+// log writes one entry.
+func (l *sink) log(prio syslogr.Severity, msg string, err error, kvList []any) {
+	buf := make([]byte, 0, 256)
+	buf = appendField(buf, "MESSAGE", msg)
+	buf = appendField(buf, "PRIORITY", strconv.Itoa(int(prio)))
+	if err != nil {
+		buf = appendField(buf, "ERROR", render.Text(l.fmtr, err))
+	}
+	if l.name != "" {
+		buf = appendField(buf, "LOGGER", l.name)
+	}
+	if l.opts.Identifier != "" {
+		buf = appendField(buf, "SYSLOG_IDENTIFIER", l.opts.Identifier)
+	}
+	if l.opts.LogCaller {
+		// +1 for this frame, +1 for Info/Error.
+		if pc, file, line, ok := runtime.Caller(l.depth + 2); ok {
+			buf = appendField(buf, "CODE_FILE", file)
+			buf = appendField(buf, "CODE_LINE", strconv.Itoa(line))
+			if fn := runtime.FuncForPC(pc); fn != nil {
+				buf = appendField(buf, "CODE_FUNC", fn.Name())
+			}
+		}
+	}
+	for _, kvs := range [][]any{l.values, kvList} {
+		for i := 0; i < len(kvs); i += 2 {
+			k := render.Key(l.fmtr, kvs[i])
+			var v any = render.NoValue
+			if i+1 < len(kvs) {
+				v = kvs[i+1]
+			}
+			buf = appendField(buf, fieldName(l.opts.KeyPrefix+k), render.Text(l.fmtr, v))
+		}
+	}
+	l.out.write(buf)
+}
+
+// appendField appends one field in the native protocol: NAME=value and a
+// newline, or for values with newlines, NAME and a newline, the length of the
+// value as a little-endian 64-bit integer, the value, and a newline.
+func appendField(buf []byte, name, value string) []byte {
+	buf = append(buf, name...)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf = append(buf, '=')
+	} else {
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+		buf = append(buf, '\n')
+		buf = append(buf, size[:]...)
+	}
+	buf = append(buf, value...)
+	return append(buf, '\n')
+}
+
+// fieldName converts a key into a valid field name.
+func fieldName(key string) string {
+	name := make([]byte, 0, len(key))
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		default:
+			c = '_'
+		}
+		if c == '_' && len(name) == 0 {
+			continue
+		}
+		name = append(name, c)
+	}
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' || reservedFields[string(name)] {
+		name = append([]byte("KEY_"), name...)
+	}
+	if len(name) > maxFieldName {
+		name = name[:maxFieldName]
+	}
+	return string(name)
+}
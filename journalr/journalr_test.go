@@ -0,0 +1,233 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journalr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/syslogr"
+)
+
+type point struct{ X, Y int }
+
+// field is one field of an entry.
+type field struct{ name, value string }
+
+// parseEntry decodes an entry in the native protocol.
+func parseEntry(t *testing.T, entry []byte) []field {
+	t.Helper()
+	var fields []field
+	for len(entry) > 0 {
+		nl := bytes.IndexByte(entry, '\n')
+		if nl < 0 {
+			t.Fatalf("unterminated field %q", entry)
+		}
+		if eq := bytes.IndexByte(entry[:nl], '='); eq >= 0 {
+			fields = append(fields, field{string(entry[:eq]), string(entry[eq+1 : nl])})
+			entry = entry[nl+1:]
+			continue
+		}
+		name := string(entry[:nl])
+		entry = entry[nl+1:]
+		if len(entry) < 8 {
+			t.Fatalf("%s: missing size", name)
+		}
+		size := binary.LittleEndian.Uint64(entry)
+		entry = entry[8:]
+		if uint64(len(entry)) < size+1 || entry[size] != '\n' {
+			t.Fatalf("%s: wrong size %d", name, size)
+		}
+		fields = append(fields, field{name, string(entry[:size])})
+		entry = entry[size+1:]
+	}
+	return fields
+}
+
+// entries records each write as one entry.
+type entries struct {
+	mu      sync.Mutex
+	entries [][]byte
+}
+
+func (e *entries) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.entries = append(e.entries, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (e *entries) get(t *testing.T) [][]field {
+	t.Helper()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var parsed [][]field
+	for _, entry := range e.entries {
+		parsed = append(parsed, parseEntry(t, entry))
+	}
+	return parsed
+}
+
+func TestLogger(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   Options
+		log    func(logr.Logger)
+		expect [][]field
+	}{{
+		name: "info",
+		opts: Options{Identifier: "app"},
+		log: func(log logr.Logger) {
+			log.Info("hello", "k", "v", "n", 1)
+			log.V(1).Info("not logged")
+		},
+		expect: [][]field{{
+			{"MESSAGE", "hello"}, {"PRIORITY", "6"}, {"SYSLOG_IDENTIFIER", "app"}, {"K", "v"}, {"N", "1"},
+		}},
+	}, {
+		name: "priorities",
+		opts: Options{
+			Identifier:    "app",
+			Verbosity:     1,
+			LevelPriority: func(level int) syslogr.Severity { return syslogr.SeverityNotice + syslogr.Severity(level) },
+		},
+		log: func(log logr.Logger) {
+			log.Info("notice")
+			log.V(1).Info("info")
+			log.Error(fmt.Errorf("multi\nline"), "error")
+		},
+		expect: [][]field{
+			{{"MESSAGE", "notice"}, {"PRIORITY", "5"}, {"SYSLOG_IDENTIFIER", "app"}},
+			{{"MESSAGE", "info"}, {"PRIORITY", "6"}, {"SYSLOG_IDENTIFIER", "app"}},
+			{{"MESSAGE", "error"}, {"PRIORITY", "3"}, {"ERROR", "multi\nline"}, {"SYSLOG_IDENTIFIER", "app"}},
+		},
+	}, {
+		name: "names and values",
+		opts: Options{Identifier: "app", KeyPrefix: "my_"},
+		log: func(log logr.Logger) {
+			log = log.WithName("server").WithValues("user", "alice")
+			log.WithName("handler").Info("values",
+				"point", point{1, 2},
+				"list", []string{"a", "b"},
+				"nil", nil,
+				"multi", "multi\nline",
+				"camelCase-with.dots", 1,
+				42, "bad key",
+				"odd")
+		},
+		expect: [][]field{{
+			{"MESSAGE", "values"}, {"PRIORITY", "6"}, {"LOGGER", "server/handler"}, {"SYSLOG_IDENTIFIER", "app"},
+			{"MY_USER", "alice"}, {"MY_POINT", `{"X":1,"Y":2}`}, {"MY_LIST", `["a","b"]`}, {"MY_NIL", "null"},
+			{"MY_MULTI", "multi\nline"}, {"MY_CAMELCASE_WITH_DOTS", "1"},
+			{"MY__NON_STRING_KEY__42_", "bad key"}, {"MY_ODD", "<no-value>"},
+		}},
+	}, {
+		name: "reserved keys",
+		opts: Options{Identifier: "app"},
+		log: func(log logr.Logger) {
+			log.WithName("server").Error(fmt.Errorf("failed"), "msg",
+				"message", "m", "priority", 0, "error", "e", "logger", "l",
+				"syslog_identifier", "s", "code_file", "f", "message_id", "id")
+		},
+		expect: [][]field{{
+			{"MESSAGE", "msg"}, {"PRIORITY", "3"}, {"ERROR", "failed"}, {"LOGGER", "server"}, {"SYSLOG_IDENTIFIER", "app"},
+			{"KEY_MESSAGE", "m"}, {"KEY_PRIORITY", "0"}, {"KEY_ERROR", "e"}, {"KEY_LOGGER", "l"},
+			{"KEY_SYSLOG_IDENTIFIER", "s"}, {"KEY_CODE_FILE", "f"}, {"KEY_MESSAGE_ID", "id"},
+		}},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &entries{}
+			tc.log(New(e, tc.opts))
+			if got := e.get(t); !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("\nexpected %q\n     got %q", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestCaller(t *testing.T) {
+	e := &entries{}
+	log := New(e, Options{Identifier: "app", LogCaller: true})
+	log.Info("direct")
+	helper := func() {
+		log.WithCallDepth(1).Info("helper")
+	}
+	helper()
+
+	entries := e.get(t)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		fields := map[string]string{}
+		for _, f := range entry {
+			fields[f.name] = f.value
+		}
+		if filepath.Base(fields["CODE_FILE"]) != "journalr_test.go" {
+			t.Errorf("%s: wrong CODE_FILE %q", fields["MESSAGE"], fields["CODE_FILE"])
+		}
+		if fields["CODE_LINE"] == "" || fields["CODE_LINE"] == "0" {
+			t.Errorf("%s: wrong CODE_LINE %q", fields["MESSAGE"], fields["CODE_LINE"])
+		}
+		if !strings.HasSuffix(fields["CODE_FUNC"], ".TestCaller") {
+			t.Errorf("%s: wrong CODE_FUNC %q", fields["MESSAGE"], fields["CODE_FUNC"])
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	e := &entries{}
+	New(e, Options{}).Info("msg")
+	entries := e.get(t)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	expect := field{"SYSLOG_IDENTIFIER", filepath.Base(os.Args[0])}
+	if got := entries[0][2]; got != expect {
+		t.Errorf("expected %q, got %q", expect, got)
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	for key, expect := range map[string]string{
+		"key":                    "KEY",
+		"Mixed_Case":             "MIXED_CASE",
+		"_trusted":               "TRUSTED",
+		"__":                     "KEY_",
+		"":                       "KEY_",
+		"1st":                    "KEY_1ST",
+		"message":                "KEY_MESSAGE",
+		"_Code_Line":             "KEY_CODE_LINE",
+		"messages":               "MESSAGES",
+		"ünïcode":                "N__CODE",
+		strings.Repeat("a", 100): strings.Repeat("A", 64),
+	} {
+		if got := fieldName(key); got != expect {
+			t.Errorf("%q: expected %q, got %q", key, expect, got)
+		}
+	}
+}
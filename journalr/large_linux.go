@@ -0,0 +1,124 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journalr
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// memfdCreate is the number of the memfd_create system call, which the
+// syscall package does not define on all architectures, or 0 if unknown.
+var memfdCreate = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"mips":     4354,
+	"mipsle":   4354,
+	"mips64":   5314,
+	"mips64le": 5314,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+}[runtime.GOARCH]
+
+// Flags and seals for memfds, from linux/memfd.h and linux/fcntl.h.
+const (
+	mfdCloexec      = 0x1
+	mfdAllowSealing = 0x2
+	fAddSeals       = 1024 + 9
+	fSealAll        = 0x1 | 0x2 | 0x4 | 0x8 // seal, shrink, grow and write
+)
+
+// sendLarge sends an entry for which sending a datagram failed with err, by
+// passing a file which holds it, if err says that the datagram was too large.
+// Otherwise it returns err.
+func sendLarge(conn *net.UnixConn, entry []byte, err error) error {
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err
+	}
+	f, err := entryFile(entry)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// WriteMsgUnix refuses connected datagram sockets.
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	if werr := rc.Write(func(fd uintptr) bool {
+		err = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return err != syscall.EAGAIN
+	}); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// entryFile returns a file which holds entry: a sealed memfd, or an unlinked
+// file in /dev/shm, which are the files journald accepts.
+func entryFile(entry []byte) (*os.File, error) {
+	if f, err := sealedMemfd(entry); err == nil {
+		return f, nil
+	}
+	f, err := os.CreateTemp("/dev/shm", "journalr-")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Write(entry); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func sealedMemfd(entry []byte) (*os.File, error) {
+	if memfdCreate == 0 {
+		return nil, syscall.ENOSYS
+	}
+	name, err := syscall.BytePtrFromString("journalr")
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(memfdCreate, uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	f := os.NewFile(fd, "journalr")
+	if _, err := f.Write(entry); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, fSealAll); errno != 0 {
+		f.Close()
+		return nil, errno
+	}
+	return f, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journalr
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestConnLarge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "socket")
+	ln := listen(t, path)
+	c := dial(t, path)
+
+	large := strings.Repeat("x", 4<<20)
+	New(c, Options{Identifier: "app"}).Info(large)
+
+	data, oob := receive(t, ln)
+	if len(data) != 0 {
+		t.Fatalf("expected an empty datagram, got %d bytes", len(data))
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected one control message, got %d: %v", len(msgs), err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("expected one file descriptor, got %d: %v", len(fds), err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "entry")
+	defer f.Close()
+
+	// The file can not be changed anymore, or is not linked anywhere.
+	if _, err := f.WriteAt([]byte("y"), 0); err == nil {
+		if fi, err := f.Stat(); err != nil {
+			t.Fatal(err)
+		} else if st, ok := fi.Sys().(*syscall.Stat_t); !ok || st.Nlink != 0 {
+			t.Fatal("the file is neither sealed nor unlinked")
+		}
+		t.Skip("memfd_create is not supported")
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := parseEntry(t, entry)
+	if len(fields) != 3 || fields[0].name != "MESSAGE" || fields[0].value != large {
+		t.Errorf("wrong entry: %d bytes, %d fields", len(entry), len(fields))
+	}
+}
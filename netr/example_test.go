@@ -0,0 +1,47 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netr_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/netr"
+)
+
+func ExampleNew() {
+	// Ship to a local collector, and keep up to 256 MiB of logs on disk
+	// while it is down.
+	client, err := netr.New("localhost:5170", netr.Options{
+		SpoolDir:  filepath.Join(os.TempDir(), "example-spool"),
+		SpoolSize: 256 << 20,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer client.Close()
+
+	log := client.Logger(funcr.Options{LogTimestamp: true})
+	log.Info("hello", "to", "the collector")
+
+	if stats := client.Stats(); !stats.Connected {
+		fmt.Fprintf(os.Stderr, "collector is down: %v, %d lines dropped\n", stats.LastError, stats.Dropped)
+	}
+}
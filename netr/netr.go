@@ -0,0 +1,558 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package netr implements github.com/go-logr/logr.Logger in terms of
+// newline-delimited JSON, as rendered by funcr, streamed over TCP or TLS to a
+// log collector.
+//
+// Logging never blocks on the network: lines are queued in memory, and a
+// background goroutine writes them to the collector.  While the collector
+// can not be reached, the Client reconnects with exponential backoff and, if
+// Options.SpoolDir is set, appends lines to a bounded file there.  Once
+// connected again, it replays the file before any new lines, so that the
+// collector receives lines in the order they were logged.  A spool left
+// behind by an earlier process is replayed too.
+//
+// Lines which do not fit in the queue or the spool are dropped and counted
+// in Stats.  When a connection fails, lines written to it which the collector
+// may not have received are spooled again, so the collector may receive a
+// line twice, but without a spool they may be lost.
+package netr
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+)
+
+// Options carries parameters which influence the way logs are shipped.
+type Options struct {
+	// TLSConfig tells the client to connect with TLS, with this
+	// configuration.  If nil, plain TCP is used.
+	TLSConfig *tls.Config
+
+	// DialTimeout limits how long connecting may take.  If not specified,
+	// 10 seconds is used.
+	DialTimeout time.Duration
+
+	// WriteTimeout limits how long a write to the collector may take,
+	// after which the connection is considered broken.  If not specified,
+	// 10 seconds is used.
+	WriteTimeout time.Duration
+
+	// MinBackoff is how long the client waits before it reconnects after
+	// the connection broke, and MaxBackoff is the longest it waits, doubling
+	// the wait after each failed attempt, until a write succeeds.  If not
+	// specified, 100 milliseconds and 30 seconds are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// QueueSize is the number of lines which are queued in memory.  If not
+	// specified, 1024 is used.
+	QueueSize int
+
+	// SpoolDir is the directory of the spool file, which holds lines while
+	// the collector can not be reached.  It is created if needed.  It must
+	// not be used by any other Client.  If not specified, lines are dropped
+	// while the collector can not be reached.
+	SpoolDir string
+
+	// SpoolSize is the largest size of the spool file, in bytes.  If not
+	// specified, 64 MiB is used.
+	SpoolSize int64
+}
+
+// Stats describes the health of a Client.
+type Stats struct {
+	// Connected tells whether the client is connected to the collector.
+	Connected bool
+	// LastError is the last error from connecting, writing or spooling, or
+	// nil.
+	LastError error
+	// Connects is the number of times the client connected.
+	Connects uint64
+	// Sent is the number of lines written to the collector.
+	Sent uint64
+	// Spooled is the number of bytes in the spool which are yet to be
+	// sent.
+	Spooled int64
+	// Dropped is the number of lines which were dropped.
+	Dropped uint64
+}
+
+// spoolFile is the name of the spool file in Options.SpoolDir.
+const spoolFile = "spool.ndjson"
+
+// maxBatch is the most queued lines written at once, and chunkSize the most
+// of the spool replayed at once, unless a line is longer.
+const (
+	maxBatch  = 256
+	chunkSize = 64 << 10
+)
+
+// errClosedByPeer is recorded when the collector closes the connection.
+var errClosedByPeer = errors.New("connection closed by the collector")
+
+// Client ships lines to a collector.  It is safe for concurrent use.
+type Client struct {
+	addr   string
+	opts   Options
+	queue  chan string
+	done   chan struct{} // closed by Close
+	exited chan struct{} // closed when run returns
+	once   sync.Once
+	err    error // from Close
+
+	mu     sync.Mutex
+	stats  Stats
+	closed bool // set by shutdown before the queue is drained for the last time
+
+	// These are only used by run.
+	conn      net.Conn // nil while disconnected
+	w         *bufio.Writer
+	dead      chan struct{} // closed when conn was closed by the peer
+	backoff   time.Duration
+	nextDial  time.Time
+	spool     *os.File // nil without Options.SpoolDir
+	spoolSize int64
+	replayed  int64 // offset of the first byte in the spool yet to be sent
+	chunk     []byte
+}
+
+// New returns a Client which ships lines to the collector at addr, a TCP
+// host:port.  It only fails if the spool can not be opened: if the collector
+// can not be reached, the Client keeps trying in the background.
+func New(addr string, opts Options) (*Client, error) {
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = 10 * time.Second
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = opts.MinBackoff
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.SpoolSize == 0 {
+		opts.SpoolSize = 64 << 20
+	}
+	c := &Client{
+		addr:    addr,
+		opts:    opts,
+		queue:   make(chan string, opts.QueueSize),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+		backoff: opts.MinBackoff,
+	}
+	if opts.SpoolDir != "" {
+		if err := c.openSpool(); err != nil {
+			return nil, err
+		}
+	}
+	go c.run()
+	return c, nil
+}
+
+func (c *Client) openSpool() error {
+	if err := os.MkdirAll(c.opts.SpoolDir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(c.opts.SpoolDir, spoolFile), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	c.spool, c.spoolSize = f, fi.Size()
+	if c.spoolSize > 0 {
+		// A process which crashed while spooling may have left a partial
+		// line, which must not run into the next one.
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, c.spoolSize-1); err != nil {
+			f.Close()
+			return err
+		}
+		if last[0] != '\n' {
+			if _, err := f.WriteAt([]byte{'\n'}, c.spoolSize); err != nil {
+				f.Close()
+				return err
+			}
+			c.spoolSize++
+		}
+	}
+	c.updateStats(func(s *Stats) { s.Spooled = c.spoolSize })
+	return nil
+}
+
+// Logger returns a logr.Logger which logs through c, formatted by
+// funcr.NewJSON with opts.
+func (c *Client) Logger(opts funcr.Options) logr.Logger {
+	return funcr.NewJSON(func(obj string) {
+		c.enqueue(obj + "\n")
+	}, opts)
+}
+
+// Write queues p to be shipped, appending a newline if p does not end with
+// one.  It never blocks, and only fails after Close.  Lines which do not fit
+// in the queue are dropped.
+func (c *Client) Write(p []byte) (int, error) {
+	select {
+	case <-c.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	line := string(p)
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	c.enqueue(line)
+	return len(p), nil
+}
+
+func (c *Client) enqueue(line string) {
+	// Queueing under c.mu ensures that no line is queued after shutdown
+	// drained the queue.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		select {
+		case c.queue <- line:
+			return
+		default:
+		}
+	}
+	c.stats.Dropped++
+}
+
+// Stats returns the current health of c.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *Client) updateStats(fn func(*Stats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.stats)
+}
+
+// Close ships the queued lines, or spools them if the client is not
+// connected, and closes the connection and the spool.  Lines logged
+// after Close are dropped.
+func (c *Client) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+		<-c.exited
+	})
+	return c.err
+}
+
+// run ships lines until Close.
+func (c *Client) run() {
+	defer close(c.exited)
+	for {
+		if c.conn == nil && !time.Now().Before(c.nextDial) {
+			c.connect()
+		}
+		if c.conn != nil && c.replayed < c.spoolSize {
+			select {
+			case <-c.done:
+				c.shutdown()
+				return
+			default:
+			}
+			// Lines which arrive meanwhile have to wait their turn.
+			c.spoolLines(c.drain(nil, c.opts.QueueSize))
+			c.replay()
+			continue
+		}
+
+		var timer *time.Timer
+		var retry <-chan time.Time
+		if c.conn == nil {
+			timer = time.NewTimer(time.Until(c.nextDial))
+			retry = timer.C
+		}
+		select {
+		case line := <-c.queue:
+			c.ship(c.drain([]string{line}, maxBatch))
+		case <-c.dead:
+			c.disconnect(errClosedByPeer)
+		case <-retry:
+		case <-c.done:
+			c.shutdown()
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// drain appends up to max queued lines to lines, without waiting for more.
+func (c *Client) drain(lines []string, max int) []string {
+	for len(lines) < max {
+		select {
+		case line := <-c.queue:
+			lines = append(lines, line)
+		default:
+			return lines
+		}
+	}
+	return lines
+}
+
+// ship writes lines to the collector if it is connected and nothing is
+// waiting in the spool, and spools them otherwise.
+func (c *Client) ship(lines []string) {
+	if c.conn == nil || c.replayed < c.spoolSize {
+		c.spoolLines(lines)
+		return
+	}
+	if err := c.write(func(w *bufio.Writer) {
+		for _, line := range lines {
+			_, _ = w.WriteString(line)
+		}
+	}); err != nil {
+		c.disconnect(err)
+		c.spoolLines(lines)
+		return
+	}
+	c.updateStats(func(s *Stats) { s.Sent += uint64(len(lines)) })
+}
+
+// write calls fn to write to the connection and flushes it.
+func (c *Client) write(fn func(w *bufio.Writer)) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteTimeout)); err != nil {
+		return err
+	}
+	fn(c.w)
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	// Only a connection which takes lines counts as working, not one which
+	// the collector accepts and closes.
+	c.backoff = c.opts.MinBackoff
+	return nil
+}
+
+// spoolLines appends lines to the spool, or drops them if there is no spool
+// or no room in it.
+func (c *Client) spoolLines(lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	var buf []byte
+	dropped := 0
+	for _, line := range lines {
+		if c.spool == nil || c.spoolSize+int64(len(buf)+len(line)) > c.opts.SpoolSize {
+			dropped++
+			continue
+		}
+		buf = append(buf, line...)
+	}
+	var err error
+	if len(buf) > 0 {
+		if _, err = c.spool.WriteAt(buf, c.spoolSize); err == nil {
+			c.spoolSize += int64(len(buf))
+		} else {
+			dropped = len(lines)
+		}
+	}
+	c.updateStats(func(s *Stats) {
+		s.Dropped += uint64(dropped)
+		s.Spooled = c.spoolSize - c.replayed
+		if err != nil {
+			s.LastError = err
+		}
+	})
+}
+
+// replay writes the next chunk of the spool to the collector.  Chunks end
+// with a line, so that the spool is only ever sent from the start of one, and
+// only lines which were written completely are counted as sent.
+func (c *Client) replay() {
+	if c.chunk == nil {
+		c.chunk = make([]byte, chunkSize)
+	}
+	chunk := c.chunk
+	if rest := c.spoolSize - c.replayed; rest < int64(len(chunk)) {
+		chunk = chunk[:rest]
+	}
+	n, err := c.spool.ReadAt(chunk, c.replayed)
+	if err != nil && n < len(chunk) {
+		// The spool is unusable, so start over with an empty one.
+		c.updateStats(func(s *Stats) { s.LastError = err })
+		c.resetSpool()
+		return
+	}
+	if end := bytes.LastIndexByte(chunk, '\n') + 1; end > 0 {
+		chunk = chunk[:end]
+	} else if len(chunk) == len(c.chunk) {
+		// The next line does not fit, so try again with larger chunks.
+		c.chunk = make([]byte, 2*len(c.chunk))
+		return
+	}
+	if err := c.write(func(w *bufio.Writer) {
+		_, _ = w.Write(chunk)
+	}); err != nil {
+		c.disconnect(err)
+		return
+	}
+	c.replayed += int64(len(chunk))
+	if c.replayed == c.spoolSize {
+		c.resetSpool()
+	}
+	c.updateStats(func(s *Stats) {
+		s.Sent += uint64(bytes.Count(chunk, []byte{'\n'}))
+		s.Spooled = c.spoolSize - c.replayed
+	})
+}
+
+// compactSpool moves the lines which are yet to be sent to the start of the
+// spool, so that a later process does not send the others again.
+func (c *Client) compactSpool() error {
+	if c.chunk == nil {
+		c.chunk = make([]byte, chunkSize)
+	}
+	size := c.spoolSize - c.replayed
+	for off := int64(0); off < size; {
+		n, err := c.spool.ReadAt(c.chunk, c.replayed+off)
+		if n == 0 {
+			return err
+		}
+		if _, err := c.spool.WriteAt(c.chunk[:n], off); err != nil {
+			return err
+		}
+		off += int64(n)
+	}
+	if err := c.spool.Truncate(size); err != nil {
+		return err
+	}
+	c.spoolSize, c.replayed = size, 0
+	return nil
+}
+
+// resetSpool empties the spool.
+func (c *Client) resetSpool() {
+	if err := c.spool.Truncate(0); err != nil {
+		c.updateStats(func(s *Stats) { s.LastError = err })
+	}
+	c.spoolSize, c.replayed = 0, 0
+	c.updateStats(func(s *Stats) { s.Spooled = 0 })
+}
+
+// connect tries to connect to the collector, and schedules the next attempt
+// if that fails.
+func (c *Client) connect() {
+	dialer := &net.Dialer{Timeout: c.opts.DialTimeout}
+	var conn net.Conn
+	var err error
+	if c.opts.TLSConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, c.opts.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		c.scheduleDial()
+		c.updateStats(func(s *Stats) { s.LastError = err })
+		return
+	}
+	c.conn, c.w = conn, bufio.NewWriter(conn)
+	// The collector is not expected to send anything, but reading notices
+	// when it closes the connection before the next write would.
+	dead := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		close(dead)
+	}()
+	c.dead = dead
+	c.updateStats(func(s *Stats) {
+		s.Connected = true
+		s.Connects++
+	})
+}
+
+// disconnect closes a broken connection, and schedules the next attempt to
+// connect.
+func (c *Client) disconnect(err error) {
+	_ = c.conn.Close()
+	c.conn, c.w, c.dead = nil, nil, nil
+	c.scheduleDial()
+	c.updateStats(func(s *Stats) {
+		s.Connected = false
+		s.LastError = err
+	})
+}
+
+// scheduleDial schedules the next attempt to connect after the backoff, and
+// doubles the backoff for the attempt after that.
+func (c *Client) scheduleDial() {
+	c.nextDial = time.Now().Add(c.backoff)
+	c.backoff *= 2
+	if c.backoff > c.opts.MaxBackoff {
+		c.backoff = c.opts.MaxBackoff
+	}
+}
+
+// shutdown ships or spools the queued lines, and closes everything.
+func (c *Client) shutdown() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.ship(c.drain(nil, c.opts.QueueSize))
+	if c.conn != nil {
+		c.err = c.conn.Close()
+		c.conn, c.w, c.dead = nil, nil, nil
+		c.updateStats(func(s *Stats) { s.Connected = false })
+	}
+	if c.spool != nil {
+		if c.replayed > 0 {
+			if err := c.compactSpool(); err != nil && c.err == nil {
+				c.err = err
+			}
+		}
+		if err := c.spool.Close(); err != nil && c.err == nil {
+			c.err = err
+		}
+		if c.spoolSize == 0 {
+			_ = os.Remove(c.spool.Name())
+		}
+	}
+}
@@ -0,0 +1,478 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netr
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+)
+
+// collector receives lines.
+type collector struct {
+	t     *testing.T
+	ln    net.Listener
+	lines chan string
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// listen starts a collector at addr, or at a new address if addr is empty.
+func listen(t *testing.T, addr string, config *tls.Config) *collector {
+	t.Helper()
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	var ln net.Listener
+	var err error
+	// The address of a stopped collector may take a moment to be free.
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if config != nil {
+			ln, err = tls.Listen("tcp", addr, config)
+		} else {
+			ln, err = net.Listen("tcp", addr)
+		}
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &collector{t: t, ln: ln, lines: make(chan string, 1000)}
+	t.Cleanup(c.stop)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.mu.Lock()
+			c.conns = append(c.conns, conn)
+			c.mu.Unlock()
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					c.lines <- scanner.Text()
+				}
+			}()
+		}
+	}()
+	return c
+}
+
+func (c *collector) addr() string {
+	return c.ln.Addr().String()
+}
+
+// stop closes the listener and all connections.
+func (c *collector) stop() {
+	c.ln.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+	c.conns = nil
+}
+
+func (c *collector) expect(lines ...string) {
+	c.t.Helper()
+	for _, expect := range lines {
+		select {
+		case got := <-c.lines:
+			if got != expect {
+				c.t.Errorf("expected %s, got %s", expect, got)
+			}
+		case <-time.After(10 * time.Second):
+			c.t.Fatalf("timed out waiting for %s", expect)
+		}
+	}
+}
+
+func (c *collector) expectNothing() {
+	c.t.Helper()
+	select {
+	case got := <-c.lines:
+		c.t.Errorf("unexpected line %s", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func newClient(t *testing.T, addr string, opts Options) *Client {
+	t.Helper()
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = 10 * time.Millisecond
+		opts.MaxBackoff = 50 * time.Millisecond
+	}
+	c, err := New(addr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// waitFor waits until cond holds for the stats of c.
+func waitFor(t *testing.T, c *Client, what string, cond func(Stats) bool) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); !cond(c.Stats()); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s: %+v", what, c.Stats())
+		}
+	}
+}
+
+// stopped returns the address of a collector which is not running anymore.
+func stopped(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func line(i int) string {
+	return fmt.Sprintf(`{"logger":"","level":0,"msg":"line","i":%d}`, i)
+}
+
+func TestLogger(t *testing.T) {
+	coll := listen(t, "", nil)
+	c := newClient(t, coll.addr(), Options{})
+	log := c.Logger(funcr.Options{}).WithName("test")
+	log.Info("hello", "k", "v")
+	log.Error(fmt.Errorf("oops"), "failed")
+	if _, err := c.Write([]byte(`{"raw":true}`)); err != nil {
+		t.Fatal(err)
+	}
+	coll.expect(
+		`{"logger":"test","level":0,"msg":"hello","k":"v"}`,
+		`{"logger":"test","msg":"failed","error":"oops"}`,
+		`{"raw":true}`,
+	)
+	waitFor(t, c, "stats", func(s Stats) bool { return s.Sent == 3 })
+	if s := c.Stats(); !s.Connected || s.Connects != 1 || s.Dropped != 0 || s.Spooled != 0 {
+		t.Errorf("wrong stats: %+v", s)
+	}
+}
+
+func TestSpool(t *testing.T) {
+	addr := stopped(t)
+	dir := filepath.Join(t.TempDir(), "spool")
+	c := newClient(t, addr, Options{SpoolDir: dir})
+	log := c.Logger(funcr.Options{})
+	for i := 0; i < 10; i++ {
+		log.Info("line", "i", i)
+	}
+	waitFor(t, c, "spooled lines", func(s Stats) bool { return s.Spooled == int64(10*len(line(0)+"\n")) })
+	if s := c.Stats(); s.Connected || s.LastError == nil || s.Sent != 0 {
+		t.Errorf("wrong stats while down: %+v", s)
+	}
+
+	// The collector comes up, receives the spool in order, and then new
+	// lines.
+	coll := listen(t, addr, nil)
+	var expect []string
+	for i := 0; i < 10; i++ {
+		expect = append(expect, line(i))
+	}
+	coll.expect(expect...)
+	log.Info("line", "i", 10)
+	coll.expect(line(10))
+	waitFor(t, c, "sent lines", func(s Stats) bool { return s.Sent == 11 })
+	if s := c.Stats(); !s.Connected || s.Spooled != 0 || s.Dropped != 0 {
+		t.Errorf("wrong stats after replay: %+v", s)
+	}
+
+	// And goes down again.
+	coll.stop()
+	waitFor(t, c, "disconnect", func(s Stats) bool { return !s.Connected })
+	log.Info("line", "i", 11)
+	waitFor(t, c, "spooled line", func(s Stats) bool { return s.Spooled > 0 })
+	coll = listen(t, addr, nil)
+	coll.expect(line(11))
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, spoolFile)); !os.IsNotExist(err) {
+		t.Errorf("expected the empty spool to be removed, got %v", err)
+	}
+}
+
+func TestSpoolLeftBehind(t *testing.T) {
+	dir := t.TempDir()
+	// A partial line, from a crash.
+	if err := os.WriteFile(filepath.Join(dir, spoolFile), []byte(line(0)+"\n"+line(1)+"\n{\"partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	coll := listen(t, "", nil)
+	c := newClient(t, coll.addr(), Options{SpoolDir: dir})
+	c.Logger(funcr.Options{}).Info("line", "i", 2)
+	coll.expect(line(0), line(1), `{"partial`, line(2))
+}
+
+func TestReplayDropped(t *testing.T) {
+	// More than two chunks of lines, which do not end where chunks would.
+	var spool []byte
+	for i := 0; len(spool) < 2*chunkSize+chunkSize/2; i++ {
+		spool = append(spool, line(i)+"\n"...)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, spoolFile), spool, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{opts: Options{SpoolDir: dir, WriteTimeout: 10 * time.Second}}
+	if err := c.openSpool(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.spool.Close()
+
+	// The collector drops the connection in the middle of the second chunk.
+	conn, peer := net.Pipe()
+	c.conn, c.w = conn, bufio.NewWriter(conn)
+	received := make(chan []byte)
+	go func() {
+		buf := make([]byte, chunkSize+chunkSize/2)
+		n, _ := io.ReadFull(peer, buf)
+		peer.Close()
+		received <- buf[:n]
+	}()
+	for c.conn != nil {
+		c.replay()
+	}
+	got := <-received
+
+	// What is left to send starts with a whole line, right after the lines
+	// which were sent.
+	if err := c.compactSpool(); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := os.ReadFile(filepath.Join(dir, spoolFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := len(spool) - len(rest)
+	if !bytes.HasSuffix(spool, rest) || sent == 0 || spool[sent-1] != '\n' {
+		t.Fatalf("the spool does not start with a line: %.60q", rest)
+	}
+	if !bytes.HasPrefix(got, spool[:sent]) {
+		t.Errorf("the collector did not receive the lines before %.60q", rest)
+	}
+	lines := uint64(bytes.Count(spool[:sent], []byte{'\n'}))
+	if s := c.Stats(); s.Sent != lines || s.Spooled != int64(len(rest)) || s.Connected {
+		t.Errorf("wrong stats after %d lines: %+v", lines, s)
+	}
+}
+
+func TestSpoolKeptOnClose(t *testing.T) {
+	dir := t.TempDir()
+	c := newClient(t, stopped(t), Options{SpoolDir: dir})
+	c.Logger(funcr.Options{}).Info("line", "i", 0)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, spoolFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := line(0) + "\n"; string(data) != expect {
+		t.Errorf("expected spool %q, got %q", expect, data)
+	}
+}
+
+func TestDrop(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    Options
+		dropped uint64
+	}{{
+		name:    "no spool",
+		dropped: 5,
+	}, {
+		name:    "spool full",
+		opts:    Options{SpoolSize: int64(2*len(line(0)+"\n") + 1)},
+		dropped: 3,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.opts.SpoolSize != 0 {
+				tc.opts.SpoolDir = t.TempDir()
+			}
+			c := newClient(t, stopped(t), tc.opts)
+			log := c.Logger(funcr.Options{})
+			for i := 0; i < 5; i++ {
+				log.Info("line", "i", i)
+			}
+			waitFor(t, c, "dropped lines", func(s Stats) bool { return s.Dropped == tc.dropped })
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+			log.Info("after close")
+			if _, err := c.Write([]byte("after close")); err == nil {
+				t.Error("expected an error from Write after Close")
+			}
+			if s := c.Stats(); s.Dropped != tc.dropped+1 {
+				t.Errorf("expected %d dropped lines, got %d", tc.dropped+1, s.Dropped)
+			}
+		})
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	coll := listen(t, "", nil)
+	c := newClient(t, coll.addr(), Options{QueueSize: 1})
+	log := c.Logger(funcr.Options{})
+	for i := 0; i < 1000; i++ {
+		log.Info("line", "i", i)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s := c.Stats()
+	if s.Dropped == 0 || s.Sent+s.Dropped != 1000 {
+		t.Errorf("wrong stats: %+v", s)
+	}
+	for i := uint64(0); i < s.Sent; i++ {
+		<-coll.lines
+	}
+	coll.expectNothing()
+}
+
+func TestBackoff(t *testing.T) {
+	c := newClient(t, stopped(t), Options{MinBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+	waitFor(t, c, "failed attempts", func(Stats) bool { return c.Stats().LastError != nil })
+	time.Sleep(200 * time.Millisecond)
+	if s := c.Stats(); s.Connected || s.Connects != 0 {
+		t.Errorf("wrong stats: %+v", s)
+	}
+}
+
+func TestBackoffClosedByPeer(t *testing.T) {
+	// A collector which accepts connections, but closes them at once.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	c := newClient(t, ln.Addr().String(), Options{MinBackoff: 10 * time.Millisecond, MaxBackoff: time.Hour})
+	waitFor(t, c, "a broken connection", func(s Stats) bool { return s.Connects > 0 && !s.Connected })
+	time.Sleep(300 * time.Millisecond)
+	// 10, 20, 40, 80 and 160 milliseconds, if the backoff grows.
+	if s := c.Stats(); s.Connects > 6 {
+		t.Errorf("expected the backoff to grow, got %d connects", s.Connects)
+	}
+}
+
+func TestCloseConcurrent(t *testing.T) {
+	coll := listen(t, "", nil)
+	c := newClient(t, coll.addr(), Options{})
+	log := c.Logger(funcr.Options{})
+	const goroutines, lines = 4, 500
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				log.Info("line", "i", i)
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	// Each line is either sent or counted as dropped, however it races with
+	// Close.
+	if s := c.Stats(); s.Sent+s.Dropped != goroutines*lines {
+		t.Errorf("expected %d lines sent or dropped, got %+v", goroutines*lines, s)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "collector"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	coll := listen(t, "", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	})
+	c := newClient(t, coll.addr(), Options{TLSConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}})
+	c.Logger(funcr.Options{}).Info("line", "i", 0)
+	coll.expect(line(0))
+
+	// A collector which is not trusted is never sent anything.
+	untrusted := newClient(t, coll.addr(), Options{TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12}})
+	untrusted.Logger(funcr.Options{}).Info("line", "i", 1)
+	waitFor(t, untrusted, "failed handshake", func(s Stats) bool { return s.LastError != nil })
+	coll.expectNothing()
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlpr_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-logr/logr/otlpr"
+)
+
+func ExampleNew() {
+	// A stand-in for an OpenTelemetry collector.
+	collector := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Println(string(body))
+	}))
+	defer collector.Close()
+
+	exp, err := otlpr.New(collector.URL+"/v1/logs", otlpr.Options{
+		Resource: []any{"service.name", "example"},
+		Clock:    func() time.Time { return time.Unix(1136214245, 0) },
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer exp.Close()
+
+	log := exp.Logger().WithName("server")
+	log.Info("started", "port", 8080, "trace_id", "4bf92f3577b34da6a3ce929d0e0e4736")
+	exp.Flush()
+	// Output: {"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"example"}}]},"scopeLogs":[{"scope":{"name":"server"},"logRecords":[{"timeUnixNano":"1136214245000000000","observedTimeUnixNano":"1136214245000000000","severityNumber":9,"severityText":"INFO","body":{"stringValue":"started"},"attributes":[{"key":"port","value":{"intValue":"8080"}}],"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"}]}]}]}
+}
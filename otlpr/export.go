@@ -0,0 +1,263 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlpr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/internal/batch"
+	"github.com/go-logr/logr/internal/render"
+)
+
+// Stats describes the health of an Exporter.
+type Stats struct {
+	// Exported is the number of log records accepted by the endpoint.
+	Exported uint64
+	// Dropped is the number of log records which were not exported: because
+	// the queue was full, the endpoint rejected them, or sending them failed
+	// after all retries.
+	Dropped uint64
+	// Requests is the number of requests sent, including retries.
+	Requests uint64
+	// LastError is the last error from sending a request, or nil.
+	LastError error
+}
+
+// maxResponse limits how much of a response is read.
+const maxResponse = 64 << 10
+
+// queued is a log record waiting to be sent.
+type queued struct {
+	scope string
+	rec   logRecord
+}
+
+// Exporter sends log records to an OTLP/HTTP endpoint.  It is safe for
+// concurrent use.
+type Exporter struct {
+	opts     Options
+	req      batch.Request
+	fmtr     funcr.Formatter // renders values
+	resource resource
+	batches  *batch.Batcher[queued]
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New returns an Exporter which sends log records to endpoint, the full URL
+// of an OTLP/HTTP logs endpoint, like "http://localhost:4318/v1/logs".
+func New(endpoint string, opts Options) (*Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("endpoint %q is not an HTTP or HTTPS URL", endpoint)
+	}
+	if opts.TraceIDKey == "" {
+		opts.TraceIDKey = "trace_id"
+	}
+	if opts.SpanIDKey == "" {
+		opts.SpanIDKey = "span_id"
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = 512
+	}
+	if opts.BatchTimeout == 0 {
+		opts.BatchTimeout = time.Second
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = 2048
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 5
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = 500 * time.Millisecond
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	e := &Exporter{
+		opts: opts,
+		req: batch.Request{
+			Client:      opts.HTTPClient,
+			URL:         endpoint,
+			Server:      "OTLP endpoint",
+			ContentType: "application/json",
+			Headers:     opts.Headers,
+			Timeout:     opts.Timeout,
+			MaxResponse: maxResponse,
+		},
+		fmtr: funcr.NewFormatterJSON(render.WithoutBuiltins(funcr.Options{StrictJSON: true})),
+	}
+	hasServiceName := false
+	for i := 0; i < len(opts.Resource); i += 2 {
+		k := render.Key(e.fmtr, opts.Resource[i])
+		var v any = render.NoValue
+		if i+1 < len(opts.Resource) {
+			v = opts.Resource[i+1]
+		}
+		hasServiceName = hasServiceName || k == "service.name"
+		e.resource.Attributes = append(e.resource.Attributes, keyValue{k, e.value(v)})
+	}
+	if !hasServiceName {
+		e.resource.Attributes = append(e.resource.Attributes, keyValue{"service.name", stringValue(defaultServiceName())})
+	}
+	e.batches = batch.New(batch.Options{
+		Size:      opts.BatchSize,
+		Timeout:   opts.BatchTimeout,
+		QueueSize: opts.QueueSize,
+	}, nil, e.send)
+	return e, nil
+}
+
+// Logger returns a logr.Logger which exports through e.
+func (e *Exporter) Logger() logr.Logger {
+	return logr.New(&sink{exp: e})
+}
+
+func (e *Exporter) enqueue(scope string, rec logRecord) {
+	if !e.batches.Add(queued{scope, rec}) {
+		e.updateStats(func(s *Stats) { s.Dropped++ })
+	}
+}
+
+// Stats returns the current health of e.
+func (e *Exporter) Stats() Stats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.stats
+}
+
+func (e *Exporter) updateStats(fn func(*Stats)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fn(&e.stats)
+}
+
+// Flush sends the queued log records, and waits until they were exported or
+// dropped.
+func (e *Exporter) Flush() {
+	e.batches.Flush()
+}
+
+// Close sends the queued log records, and stops the exporter.  Requests
+// which fail are not retried anymore.  Log records logged after Close are
+// dropped.
+func (e *Exporter) Close() error {
+	e.batches.Close()
+	return nil
+}
+
+// send exports a batch, retrying as needed.
+func (e *Exporter) send(items []queued) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(e.request(items))
+	body := bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})
+	if err != nil {
+		e.updateStats(func(s *Stats) {
+			s.Dropped += uint64(len(items))
+			s.LastError = err
+		})
+		return
+	}
+
+	backoff := batch.Backoff{Min: e.opts.MinBackoff, Max: e.opts.MaxBackoff}
+	dropped := e.batches.Retry(items, e.opts.MaxRetries, backoff, func(items []queued) ([]queued, error) {
+		rejected, err := e.post(body)
+		e.updateStats(func(s *Stats) {
+			s.Requests++
+			if err != nil {
+				s.LastError = err
+				return
+			}
+			if n := int64(len(items)); rejected > n {
+				rejected = n
+			}
+			s.Exported += uint64(int64(len(items)) - rejected)
+			s.Dropped += uint64(rejected)
+		})
+		return nil, err
+	})
+	e.updateStats(func(s *Stats) { s.Dropped += uint64(len(dropped)) })
+}
+
+// request groups a batch by instrumentation scope, in the order in which
+// each scope first appears.
+func (e *Exporter) request(items []queued) exportRequest {
+	var scopes []scopeLogs
+	index := map[string]int{}
+	for _, q := range items {
+		i, found := index[q.scope]
+		if !found {
+			i = len(scopes)
+			index[q.scope] = i
+			scopes = append(scopes, scopeLogs{Scope: scope{Name: q.scope, Version: e.opts.ScopeVersion}})
+		}
+		scopes[i].LogRecords = append(scopes[i].LogRecords, q.rec)
+	}
+	return exportRequest{ResourceLogs: []resourceLogs{{Resource: e.resource, ScopeLogs: scopes}}}
+}
+
+// post sends one request, and returns how many log records the endpoint
+// rejected.
+func (e *Exporter) post(body []byte) (int64, error) {
+	data, err := e.req.Post(body)
+	if err != nil {
+		return 0, err
+	}
+	var result exportResponse
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		// The request succeeded, but whether records were rejected is
+		// unknown.
+		e.updateStats(func(s *Stats) { s.LastError = fmt.Errorf("invalid response from OTLP endpoint: %w", err) })
+		return 0, nil
+	}
+	if result.PartialSuccess == nil {
+		return 0, nil
+	}
+	rejected := int64(result.PartialSuccess.RejectedLogRecords)
+	if rejected > 0 && result.PartialSuccess.ErrorMessage != "" {
+		e.updateStats(func(s *Stats) { s.LastError = errors.New(result.PartialSuccess.ErrorMessage) })
+	}
+	return rejected, nil
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlpr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The types below are the parts of the OTLP log data model which are
+// needed here, with the field names and the encoding of the OTLP/JSON
+// protocol: 64-bit integers are strings, and IDs are hex strings.
+
+type exportRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type scope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type logRecord struct {
+	TimeUnixNano         int64      `json:"timeUnixNano,string"`
+	ObservedTimeUnixNano int64      `json:"observedTimeUnixNano,string"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 anyValue   `json:"body"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+	TraceID              string     `json:"traceId,omitempty"`
+	SpanID               string     `json:"spanId,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+// anyValue holds exactly one of its fields.  An empty anyValue stands for
+// null.
+type anyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *int64      `json:"intValue,omitempty,string"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+	KvlistValue *kvlist     `json:"kvlistValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+type kvlist struct {
+	Values []keyValue `json:"values"`
+}
+
+type exportResponse struct {
+	PartialSuccess *struct {
+		RejectedLogRecords int64Value `json:"rejectedLogRecords"`
+		ErrorMessage       string     `json:"errorMessage"`
+	} `json:"partialSuccess"`
+}
+
+// int64Value is a 64-bit integer in a response, which OTLP/JSON encodes as a
+// string, but which endpoints may send as a number too, as the protobuf JSON
+// mapping allows.
+type int64Value int64
+
+func (v *int64Value) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	i, err := strconv.ParseInt(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid 64-bit integer %s", data)
+	}
+	*v = int64Value(i)
+	return nil
+}
+
+func stringValue(s string) anyValue {
+	return anyValue{StringValue: &s}
+}
+
+// jsonValue converts a JSON value, as rendered by funcr, into an anyValue.
+// Objects become key-value lists in the order of their keys, and numbers
+// become integers if they fit into an int64.
+func jsonValue(js string) (anyValue, error) {
+	dec := json.NewDecoder(strings.NewReader(js))
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+func decodeValue(dec *json.Decoder) (anyValue, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return anyValue{}, err
+	}
+	switch tok := tok.(type) {
+	case nil:
+		return anyValue{}, nil
+	case bool:
+		return anyValue{BoolValue: &tok}, nil
+	case string:
+		return stringValue(tok), nil
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return anyValue{IntValue: &i}, nil
+		}
+		f, err := tok.Float64()
+		if err != nil {
+			return anyValue{}, err
+		}
+		return anyValue{DoubleValue: &f}, nil
+	case json.Delim:
+		switch tok {
+		case '[':
+			arr := &arrayValue{Values: []anyValue{}}
+			for dec.More() {
+				v, err := decodeValue(dec)
+				if err != nil {
+					return anyValue{}, err
+				}
+				arr.Values = append(arr.Values, v)
+			}
+			_, err := dec.Token() // ]
+			return anyValue{ArrayValue: arr}, err
+		case '{':
+			kvs := &kvlist{Values: []keyValue{}}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return anyValue{}, err
+				}
+				v, err := decodeValue(dec)
+				if err != nil {
+					return anyValue{}, err
+				}
+				kvs.Values = append(kvs.Values, keyValue{Key: fmt.Sprint(key), Value: v})
+			}
+			_, err := dec.Token() // }
+			return anyValue{KvlistValue: kvs}, err
+		}
+	}
+	return anyValue{}, fmt.Errorf("unexpected JSON token %v", tok)
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package otlpr implements github.com/go-logr/logr.Logger in terms of
+// OpenTelemetry log records, exported to an OTLP/HTTP endpoint with the JSON
+// encoding.  It does not depend on the OpenTelemetry SDK.
+//
+// Each log line is one log record:
+//
+//   - The body is the message.
+//   - The severity of Info logs is INFO for V(0), and one step less for each
+//     V-level, down to TRACE, as in the OpenTelemetry bridge for logr.  Error
+//     logs are ERROR.
+//   - The attributes are the key-value pairs, including the ones from
+//     WithValues.  The error of Error logs is the attribute
+//     "exception.message".  Values are converted as rendered to JSON by
+//     funcr, so that logr.Marshaler, fmt.Stringer and error values are
+//     handled the same way.
+//   - The instrumentation scope is the logger name.
+//   - The trace and span IDs are taken from the values with the keys
+//     Options.TraceIDKey and Options.SpanIDKey, which are not attributes
+//     then.  They can be hex strings, or byte arrays like the TraceID and
+//     SpanID types of the OpenTelemetry API.
+//
+// An Exporter batches log records and sends them in the background, retrying
+// requests which fail with network errors or with the retryable status codes
+// of the OTLP specification.
+package otlpr
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/internal/render"
+)
+
+// Options carries parameters which influence the way logs are exported.
+type Options struct {
+	// Resource is a list of key-value pairs which describe the entity
+	// producing the logs, like "service.name".  If it does not contain
+	// "service.name", "unknown_service:" and the base name of the
+	// executable is added, as the OpenTelemetry specification asks.
+	Resource []any
+
+	// ScopeVersion is the version of the instrumentation scope of all log
+	// records.
+	ScopeVersion string
+
+	// TraceIDKey and SpanIDKey are the keys of the values which are the
+	// trace and span IDs.  If not specified, "trace_id" and "span_id" are
+	// used.
+	TraceIDKey string
+	SpanIDKey  string
+
+	// Headers are added to each request, for example for authentication.
+	Headers map[string]string
+
+	// HTTPClient sends the requests.  If not specified, http.DefaultClient
+	// is used.
+	HTTPClient *http.Client
+
+	// Timeout limits how long each request may take.  If not specified, 10
+	// seconds is used.
+	Timeout time.Duration
+
+	// BatchSize is the most log records sent in one request.  If not
+	// specified, 512 is used.
+	BatchSize int
+
+	// BatchTimeout is how long log records may wait for a batch to fill
+	// up.  If not specified, 1 second is used.
+	BatchTimeout time.Duration
+
+	// QueueSize is the number of log records which are queued in memory
+	// while a batch is sent.  Log records which do not fit are dropped.  If
+	// not specified, 2048 is used.
+	QueueSize int
+
+	// MaxRetries is how often a failed request is retried.  If not
+	// specified, 5 is used.  Use a negative value to disable retries.
+	MaxRetries int
+
+	// MinBackoff is how long the exporter waits before the first retry, and
+	// MaxBackoff the longest it waits, doubling the wait for each retry
+	// unless the endpoint asks for a different one with Retry-After, which
+	// is honoured up to 10 minutes.  If not specified, 500 milliseconds and
+	// 30 seconds are used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Clock tells the exporter how to get the current time, for the
+	// timestamps of log records.  If not specified, time.Now is used.
+	Clock func() time.Time
+
+	// Verbosity tells the logger which V logs to write.  Higher values
+	// enable more logs.
+	Verbosity int
+}
+
+// Severity numbers, from the OpenTelemetry log data model.
+const (
+	severityTrace = 1
+	severityInfo  = 9
+	severityError = 17
+)
+
+// severityNames are the short names of each range of four severity numbers.
+var severityNames = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// severityText returns the short name of a severity number, like "DEBUG2".
+func severityText(n int) string {
+	name := severityNames[(n-1)/4]
+	if step := (n-1)%4 + 1; step > 1 {
+		name += fmt.Sprint(step)
+	}
+	return name
+}
+
+// levelSeverity returns the severity number of a V-level.
+func levelSeverity(level int) int {
+	if level >= severityInfo-severityTrace {
+		return severityTrace
+	}
+	return severityInfo - level
+}
+
+// sink implements logr.LogSink.
+type sink struct {
+	exp    *Exporter
+	name   string
+	values []any
+}
+
+var _ logr.LogSink = &sink{}
+
+func (*sink) Init(logr.RuntimeInfo) {}
+
+func (l *sink) Enabled(level int) bool {
+	return level <= l.exp.opts.Verbosity
+}
+
+func (l *sink) Info(level int, msg string, kvList ...any) {
+	l.log(levelSeverity(level), msg, nil, kvList)
+}
+
+func (l *sink) Error(err error, msg string, kvList ...any) {
+	l.log(severityError, msg, err, kvList)
+}
+
+func (l sink) WithName(name string) logr.LogSink {
+	if l.name != "" {
+		l.name += "/"
+	}
+	l.name += name
+	return &l
+}
+
+func (l sink) WithValues(kvList ...any) logr.LogSink {
+	l.values = render.AppendValues(l.values, kvList)
+	return &l
+}
+
+// log converts one log record and queues it.
+func (l *sink) log(severity int, msg string, err error, kvList []any) {
+	now := l.exp.opts.Clock().UnixNano()
+	rec := logRecord{
+		TimeUnixNano:         now,
+		ObservedTimeUnixNano: now,
+		SeverityNumber:       severity,
+		SeverityText:         severityText(severity),
+		Body:                 stringValue(msg),
+	}
+	if err != nil {
+		rec.Attributes = append(rec.Attributes, keyValue{"exception.message", l.exp.value(err)})
+	}
+	for _, kvs := range [][]any{l.values, kvList} {
+		for i := 0; i < len(kvs); i += 2 {
+			k := render.Key(l.exp.fmtr, kvs[i])
+			var v any = render.NoValue
+			if i+1 < len(kvs) {
+				v = kvs[i+1]
+			}
+			switch k {
+			case l.exp.opts.TraceIDKey:
+				if id, ok := hexID(v, 16); ok {
+					rec.TraceID = id
+					continue
+				}
+			case l.exp.opts.SpanIDKey:
+				if id, ok := hexID(v, 8); ok {
+					rec.SpanID = id
+					continue
+				}
+			}
+			rec.Attributes = append(rec.Attributes, keyValue{k, l.exp.value(v)})
+		}
+	}
+	l.exp.enqueue(l.name, rec)
+}
+
+// hexID returns a trace or span ID of size bytes as a hex string, if v is
+// one: a hex string, or a byte array.  An all-zero ID is not valid.
+func hexID(v any, size int) (string, bool) {
+	var id []byte
+	if s, ok := v.(string); ok {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return "", false
+		}
+		id = b
+	} else {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Array || rv.Type().Elem().Kind() != reflect.Uint8 {
+			return "", false
+		}
+		id = make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(id), rv)
+	}
+	if len(id) != size {
+		return "", false
+	}
+	for _, b := range id {
+		if b != 0 {
+			return hex.EncodeToString(id), true
+		}
+	}
+	return "", false
+}
+
+// value converts a value into an anyValue, as rendered by funcr.
+func (e *Exporter) value(v any) anyValue {
+	if s, ok := v.(string); ok {
+		return stringValue(s)
+	}
+	js := render.JSON(e.fmtr, v)
+	av, err := jsonValue(js)
+	if err != nil {
+		// Not expected with StrictJSON, but better than nothing.
+		return stringValue(js)
+	}
+	return av
+}
+
+// defaultServiceName returns the "service.name" to use if Options.Resource has
+// none.
+func defaultServiceName() string {
+	if len(os.Args) == 0 {
+		return "unknown_service"
+	}
+	return "unknown_service:" + filepath.Base(os.Args[0])
+}
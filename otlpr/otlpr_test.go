@@ -0,0 +1,417 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package otlpr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// collector records the requests it receives, and answers with the
+// responses it is given, and then with 200 OK.
+type collector struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	requests  []string
+	headers   []http.Header
+	responses []func(http.ResponseWriter)
+}
+
+func newCollector(t *testing.T, responses ...func(http.ResponseWriter)) *collector {
+	c := &collector{responses: responses}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if r.URL.Path != "/v1/logs" || r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s with %q", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		c.requests = append(c.requests, string(body))
+		c.headers = append(c.headers, r.Header)
+		if len(c.responses) > 0 {
+			c.responses[0](w)
+			c.responses = c.responses[1:]
+		}
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *collector) endpoint() string {
+	return c.URL + "/v1/logs"
+}
+
+func (c *collector) get() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests
+}
+
+func status(code int, header ...string) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+		w.WriteHeader(code)
+	}
+}
+
+func newExporter(t *testing.T, endpoint string, opts Options) *Exporter {
+	t.Helper()
+	if opts.Clock == nil {
+		opts.Clock = func() time.Time { return time.Unix(1136214245, 123) }
+	}
+	if opts.Resource == nil {
+		opts.Resource = []any{"service.name", "test"}
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = time.Millisecond
+	}
+	e, err := New(endpoint, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+// compact removes the indentation of expected JSON.
+func compact(t *testing.T, js string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(js)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+type point struct{ X, Y int }
+
+type traceID [16]byte
+
+type marshaler struct{}
+
+func (marshaler) MarshalLog() any { return "marshaled" }
+
+var _ logr.Marshaler = marshaler{}
+
+func TestExport(t *testing.T) {
+	coll := newCollector(t)
+	e := newExporter(t, coll.endpoint(), Options{
+		Resource:     []any{"service.name", "api", "host.name", "example.com"},
+		ScopeVersion: "v1",
+	})
+	log := e.Logger().WithName("server").WithValues("user", "alice")
+	log.Info("hello", "n", 1, "trace_id", "0102030405060708090a0b0c0d0e0f10", "span_id", "0102030405060708")
+	log.WithName("handler").Error(fmt.Errorf("oops"), "failed", "trace_id", traceID{1}, "span_id", "not an ID")
+	e.Logger().Info("root")
+	e.Flush()
+
+	expect := compact(t, `{"resourceLogs":[{
+		"resource":{"attributes":[
+			{"key":"service.name","value":{"stringValue":"api"}},
+			{"key":"host.name","value":{"stringValue":"example.com"}}]},
+		"scopeLogs":[
+			{"scope":{"name":"server","version":"v1"},"logRecords":[
+				{"timeUnixNano":"1136214245000000123","observedTimeUnixNano":"1136214245000000123",
+				"severityNumber":9,"severityText":"INFO","body":{"stringValue":"hello"},
+				"attributes":[
+					{"key":"user","value":{"stringValue":"alice"}},
+					{"key":"n","value":{"intValue":"1"}}],
+				"traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0102030405060708"}]},
+			{"scope":{"name":"server/handler","version":"v1"},"logRecords":[
+				{"timeUnixNano":"1136214245000000123","observedTimeUnixNano":"1136214245000000123",
+				"severityNumber":17,"severityText":"ERROR","body":{"stringValue":"failed"},
+				"attributes":[
+					{"key":"exception.message","value":{"stringValue":"oops"}},
+					{"key":"user","value":{"stringValue":"alice"}},
+					{"key":"span_id","value":{"stringValue":"not an ID"}}],
+				"traceId":"01000000000000000000000000000000"}]},
+			{"scope":{"version":"v1"},"logRecords":[
+				{"timeUnixNano":"1136214245000000123","observedTimeUnixNano":"1136214245000000123",
+				"severityNumber":9,"severityText":"INFO","body":{"stringValue":"root"}}]}]}]}`)
+	requests := coll.get()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	if requests[0] != expect {
+		t.Errorf("\nexpected %s\n     got %s", expect, requests[0])
+	}
+	if s := e.Stats(); s.Exported != 3 || s.Requests != 1 || s.Dropped != 0 || s.LastError != nil {
+		t.Errorf("wrong stats: %+v", s)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	for level, expect := range map[int]string{
+		0:   `"severityNumber":9,"severityText":"INFO"`,
+		1:   `"severityNumber":8,"severityText":"DEBUG4"`,
+		3:   `"severityNumber":6,"severityText":"DEBUG2"`,
+		4:   `"severityNumber":5,"severityText":"DEBUG"`,
+		5:   `"severityNumber":4,"severityText":"TRACE4"`,
+		8:   `"severityNumber":1,"severityText":"TRACE"`,
+		100: `"severityNumber":1,"severityText":"TRACE"`,
+	} {
+		coll := newCollector(t)
+		e := newExporter(t, coll.endpoint(), Options{Verbosity: 100})
+		e.Logger().V(level).Info("msg")
+		e.Logger().V(101).Info("not logged")
+		e.Flush()
+		if requests := coll.get(); len(requests) != 1 || !strings.Contains(requests[0], expect) {
+			t.Errorf("V(%d): expected %s, got %q", level, expect, requests)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	coll := newCollector(t)
+	e := newExporter(t, coll.endpoint(), Options{})
+	e.Logger().Info("values",
+		"string", "s",
+		"bool", true,
+		"int", -1,
+		"uint", uint64(math.MaxUint64),
+		"float", 1.5,
+		"nan", math.NaN(),
+		"nil", nil,
+		"slice", []any{1, "a"},
+		"map", map[string]int{"a": 1},
+		"struct", point{1, 2},
+		"stringer", time.Second,
+		"marshaler", marshaler{},
+		"error", fmt.Errorf("oops"),
+		42, "bad key",
+		"odd")
+	e.Flush()
+
+	expect := compact(t, `[
+		{"key":"string","value":{"stringValue":"s"}},
+		{"key":"bool","value":{"boolValue":true}},
+		{"key":"int","value":{"intValue":"-1"}},
+		{"key":"uint","value":{"doubleValue":18446744073709552000}},
+		{"key":"float","value":{"doubleValue":1.5}},
+		{"key":"nan","value":{"stringValue":"NaN"}},
+		{"key":"nil","value":{}},
+		{"key":"slice","value":{"arrayValue":{"values":[{"intValue":"1"},{"stringValue":"a"}]}}},
+		{"key":"map","value":{"kvlistValue":{"values":[{"key":"a","value":{"intValue":"1"}}]}}},
+		{"key":"struct","value":{"kvlistValue":{"values":[{"key":"X","value":{"intValue":"1"}},{"key":"Y","value":{"intValue":"2"}}]}}},
+		{"key":"stringer","value":{"stringValue":"1s"}},
+		{"key":"marshaler","value":{"stringValue":"marshaled"}},
+		{"key":"error","value":{"stringValue":"oops"}},
+		{"key":"<non-string-key: 42>","value":{"stringValue":"bad key"}},
+		{"key":"odd","value":{"stringValue":"<no-value>"}}]`)
+	requests := coll.get()
+	if len(requests) != 1 || !strings.Contains(requests[0], `"attributes":`+expect) {
+		t.Errorf("\nexpected %s\n     got %q", expect, requests)
+	}
+}
+
+func TestBatching(t *testing.T) {
+	coll := newCollector(t)
+	e := newExporter(t, coll.endpoint(), Options{BatchSize: 3, BatchTimeout: time.Hour})
+	for i := 0; i < 7; i++ {
+		e.Logger().Info("msg")
+	}
+	e.Flush()
+	if n := len(coll.get()); n != 3 {
+		t.Errorf("expected 3 requests, got %d", n)
+	}
+
+	// A batch which does not fill up is sent after the timeout.
+	coll = newCollector(t)
+	e = newExporter(t, coll.endpoint(), Options{BatchTimeout: 10 * time.Millisecond})
+	e.Logger().Info("msg")
+	for deadline := time.Now().Add(10 * time.Second); len(coll.get()) == 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the batch was not sent")
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	testCases := []struct {
+		name      string
+		responses []func(http.ResponseWriter)
+		opts      Options
+		requests  uint64
+		exported  uint64
+		dropped   uint64
+		lastError string
+	}{{
+		name:      "retryable",
+		responses: []func(http.ResponseWriter){status(503), status(429, "Retry-After", "0"), status(502)},
+		requests:  4,
+		exported:  2,
+		lastError: "OTLP endpoint returned 502 Bad Gateway",
+	}, {
+		name:      "too many",
+		responses: []func(http.ResponseWriter){status(503), status(503), status(503)},
+		opts:      Options{MaxRetries: 2},
+		requests:  3,
+		dropped:   2,
+		lastError: "OTLP endpoint returned 503 Service Unavailable",
+	}, {
+		name:      "disabled",
+		responses: []func(http.ResponseWriter){status(503)},
+		opts:      Options{MaxRetries: -1},
+		requests:  1,
+		dropped:   2,
+		lastError: "OTLP endpoint returned 503 Service Unavailable",
+	}, {
+		name: "permanent",
+		responses: []func(http.ResponseWriter){func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid request\n"))
+		}},
+		requests:  1,
+		dropped:   2,
+		lastError: "OTLP endpoint returned 400 Bad Request: invalid request",
+	}, {
+		name: "partial success",
+		responses: []func(http.ResponseWriter){func(w http.ResponseWriter) {
+			_, _ = w.Write([]byte(`{"partialSuccess":{"rejectedLogRecords":"1","errorMessage":"too old"}}`))
+		}},
+		requests:  1,
+		exported:  1,
+		dropped:   1,
+		lastError: "too old",
+	}, {
+		name: "numeric partial success",
+		responses: []func(http.ResponseWriter){func(w http.ResponseWriter) {
+			_, _ = w.Write([]byte(`{"partialSuccess":{"rejectedLogRecords":1,"errorMessage":"too old"}}`))
+		}},
+		requests:  1,
+		exported:  1,
+		dropped:   1,
+		lastError: "too old",
+	}, {
+		name: "invalid response",
+		responses: []func(http.ResponseWriter){func(w http.ResponseWriter) {
+			_, _ = w.Write([]byte(`{"partialSuccess":{"rejectedLogRecords":"many"}}`))
+		}},
+		requests:  1,
+		exported:  2,
+		lastError: `invalid response from OTLP endpoint: invalid 64-bit integer "many"`,
+	}, {
+		name: "empty partial success",
+		responses: []func(http.ResponseWriter){func(w http.ResponseWriter) {
+			_, _ = w.Write([]byte(`{"partialSuccess":{}}`))
+		}},
+		requests: 1,
+		exported: 2,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			coll := newCollector(t, tc.responses...)
+			e := newExporter(t, coll.endpoint(), tc.opts)
+			e.Logger().Info("one")
+			e.Logger().Info("two")
+			e.Flush()
+			s := e.Stats()
+			if s.Requests != tc.requests || s.Exported != tc.exported || s.Dropped != tc.dropped {
+				t.Errorf("wrong stats: %+v", s)
+			}
+			if got := fmt.Sprint(s.LastError); tc.lastError != "" && got != tc.lastError || tc.lastError == "" && s.LastError != nil {
+				t.Errorf("expected error %q, got %q", tc.lastError, got)
+			}
+			requests := coll.get()
+			for _, r := range requests[1:] {
+				if r != requests[0] {
+					t.Errorf("retry differs: %s", r)
+				}
+			}
+		})
+	}
+}
+
+func TestNetworkError(t *testing.T) {
+	coll := newCollector(t)
+	endpoint := coll.endpoint()
+	coll.Close()
+	e := newExporter(t, endpoint, Options{MaxRetries: 1})
+	e.Logger().Info("msg")
+	e.Flush()
+	if s := e.Stats(); s.Requests != 2 || s.Dropped != 1 || s.LastError == nil {
+		t.Errorf("wrong stats: %+v", s)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	coll := newCollector(t)
+	e := newExporter(t, coll.endpoint(), Options{Headers: map[string]string{"Authorization": "Bearer token"}})
+	e.Logger().Info("msg")
+	e.Flush()
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+	if len(coll.headers) != 1 || coll.headers[0].Get("Authorization") != "Bearer token" {
+		t.Errorf("missing header: %v", coll.headers)
+	}
+}
+
+func TestDefaultResource(t *testing.T) {
+	coll := newCollector(t)
+	e := newExporter(t, coll.endpoint(), Options{Resource: []any{"host.name", "example.com"}})
+	e.Logger().Info("msg")
+	e.Flush()
+	expect := `{"key":"service.name","value":{"stringValue":"unknown_service:` + filepath.Base(os.Args[0]) + `"}}`
+	if requests := coll.get(); len(requests) != 1 || !strings.Contains(requests[0], expect) {
+		t.Errorf("expected %s, got %q", expect, requests)
+	}
+}
+
+func TestClose(t *testing.T) {
+	coll := newCollector(t)
+	e := newExporter(t, coll.endpoint(), Options{BatchTimeout: time.Hour})
+	e.Logger().Info("before")
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(coll.get()); n != 1 {
+		t.Errorf("expected 1 request, got %d", n)
+	}
+	e.Logger().Info("after")
+	e.Flush()
+	if s := e.Stats(); s.Exported != 1 || s.Dropped != 1 {
+		t.Errorf("wrong stats: %+v", s)
+	}
+	if err := e.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, endpoint := range []string{"localhost:4318", "ftp://localhost/v1/logs", "http://[::1"} {
+		if _, err := New(endpoint, Options{}); err == nil {
+			t.Errorf("%q: expected an error", endpoint)
+		}
+	}
+}
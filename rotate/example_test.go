@@ -0,0 +1,57 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rotate_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/rotate"
+)
+
+func ExampleNew() {
+	dir, err := os.MkdirTemp("", "logs")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Rotate daily or at 10 MiB, and keep a week of compressed backups.
+	w, err := rotate.New(filepath.Join(dir, "app.log"), rotate.Options{
+		MaxSize:        10 << 20,
+		Interval:       24 * time.Hour,
+		MaxAge:         7 * 24 * time.Hour,
+		Compress:       true,
+		ReopenOnSIGHUP: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer w.Close()
+
+	log := funcr.NewJSON(func(obj string) {
+		fmt.Fprintln(w, obj)
+	}, funcr.Options{})
+	log.Info("hello", "to", "a file")
+
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	fmt.Print(string(data))
+	// Output: {"logger":"","level":0,"msg":"hello","to":"a file"}
+}
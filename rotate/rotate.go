@@ -0,0 +1,463 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rotate implements an io.Writer which writes to a file, and rotates
+// it by size or time, for use with funcr, csvr, or any other LogSink which
+// writes to an io.Writer or a function.
+//
+// When the file is rotated, it is renamed to a backup with the time of the
+// rotation in its name, e.g. "app-2006-01-02T15-04-05.000.log" for
+// "app.log", and a new file is created.  Backups are optionally compressed
+// with gzip, and removed when there are too many or they are too old, in the
+// background.
+//
+// To work with external tools like logrotate, which rename the file and then
+// signal the process, the Writer can reopen the file on demand or on SIGHUP.
+package rotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options carries parameters which influence the way files are rotated.
+type Options struct {
+	// MaxSize is the size in bytes at which the file is rotated.  A write
+	// which would make the file bigger rotates it first, unless the file is
+	// empty, so writes are never split.  If zero, the file is not rotated by
+	// size.
+	MaxSize int64
+
+	// Interval is how often the file is rotated.  Rotations happen at
+	// multiples of Interval since the zero time, in UTC, so e.g. 24 hours
+	// rotates the file at midnight UTC.  The file is only rotated when it is
+	// written to, so there are no empty backups.  If zero, the file is not
+	// rotated by time.
+	Interval time.Duration
+
+	// MaxBackups is the number of backups to keep.  If zero, backups are not
+	// removed because of their number.
+	MaxBackups int
+
+	// MaxAge is how long to keep backups, according to the times in their
+	// names.  If zero, backups are not removed because of their age.
+	MaxAge time.Duration
+
+	// Compress tells the Writer to compress backups with gzip.
+	Compress bool
+
+	// ReopenOnSIGHUP tells the Writer to reopen the file when the process
+	// receives SIGHUP, as Writer.Reopen does.  This is ignored on platforms
+	// which do not have SIGHUP.
+	ReopenOnSIGHUP bool
+
+	// FileMode is the permission bits of new files.  If not specified,
+	// 0o644 is used.
+	FileMode os.FileMode
+
+	// Clock tells the Writer how to get the current time, for rotating the
+	// file and naming and removing backups.  If not specified, time.Now is
+	// used.
+	Clock func() time.Time
+}
+
+// backupTimeFormat is the layout of the times in the names of backups.  It
+// sorts in time order, and avoids characters which are not allowed in file
+// names on some platforms.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// compressSuffix is added to the names of compressed backups.
+const compressSuffix = ".gz"
+
+// Writer writes to a file, and rotates it according to its Options.  It is
+// safe for concurrent use.
+type Writer struct {
+	path string
+	opts Options
+
+	mu         sync.Mutex
+	file       *os.File // nil once closed
+	size       int64
+	nextRotate time.Time // zero if not rotating by time
+
+	millMu  sync.Mutex // serializes compressing and removing backups
+	millWG  sync.WaitGroup
+	millErr error // the first error, guarded by mu
+
+	stopSignals func() // stops reopening on SIGHUP
+}
+
+var _ io.WriteCloser = &Writer{}
+
+// New returns a Writer which appends to the file at path, creating it and
+// its directory if needed.
+func New(path string, opts Options) (*Writer, error) {
+	if opts.FileMode == 0 {
+		opts.FileMode = 0o644
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	w := &Writer{path: path, opts: opts}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	if opts.ReopenOnSIGHUP {
+		w.stopSignals = notifySIGHUP(func() { _ = w.Reopen() })
+	}
+	return w, nil
+}
+
+// open opens the file for appending.  The caller must hold mu, if the Writer
+// is shared.
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, w.opts.FileMode)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	if w.opts.Interval > 0 {
+		// A file from an earlier period is rotated on the first write.
+		last := w.opts.Clock()
+		if w.size > 0 {
+			last = info.ModTime()
+		}
+		w.nextRotate = last.UTC().Truncate(w.opts.Interval).Add(w.opts.Interval)
+	}
+	return nil
+}
+
+// Write writes p to the file, after rotating it if needed.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	var rerr error
+	if w.needsRotate(len(p)) {
+		// If the file could not be rotated but is open again, p is still
+		// written, and the error returned.
+		if rerr = w.rotate(); w.file == nil {
+			return 0, rerr
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err == nil {
+		err = rerr
+	}
+	return n, err
+}
+
+// needsRotate tells whether the file must be rotated before writing n bytes.
+func (w *Writer) needsRotate(n int) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.MaxSize > 0 && w.size+int64(n) > w.opts.MaxSize {
+		return true
+	}
+	return !w.nextRotate.IsZero() && !w.opts.Clock().Before(w.nextRotate)
+}
+
+// Rotate rotates the file now, unless it is empty.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if w.size == 0 {
+		return nil
+	}
+	return w.rotate()
+}
+
+// rotate renames the file to a backup and opens a new one.  The file is open
+// afterwards unless reopening it failed, even if it could not be renamed.  The
+// caller must hold mu.
+func (w *Writer) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err == nil {
+		var backup string
+		if backup, err = w.backupName(w.opts.Clock()); err == nil {
+			err = os.Rename(w.path, backup)
+		}
+	}
+	// Reopen the file even if it could not be renamed, to keep logging.
+	if oerr := w.open(); oerr != nil {
+		return oerr
+	}
+	if err != nil {
+		return err
+	}
+	w.startMill()
+	return nil
+}
+
+// backupName returns the name for a backup made at t, which is unique among
+// existing files.
+func (w *Writer) backupName(t time.Time) (string, error) {
+	dir, prefix, ext := w.nameParts()
+	for {
+		name := filepath.Join(dir, prefix+t.UTC().Format(backupTimeFormat)+ext)
+		_, err := os.Lstat(name)
+		if errors.Is(err, os.ErrNotExist) {
+			_, err = os.Lstat(name + compressSuffix)
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// nameParts splits the path of the file into the parts of the names of its
+// backups: the directory, the prefix before the time, and the extension.
+func (w *Writer) nameParts() (dir, prefix, ext string) {
+	dir, base := filepath.Split(w.path)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// Reopen closes the file and opens it again, creating it if it no longer
+// exists, as after it was renamed by an external tool.
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	return w.open()
+}
+
+// Close syncs and closes the file, and waits for backups to be compressed
+// and removed.  It returns the first error from doing that in the background,
+// if any.  Writes after Close fail with os.ErrClosed.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.file == nil {
+		w.mu.Unlock()
+		return nil
+	}
+	if w.stopSignals != nil {
+		w.stopSignals()
+	}
+	err := w.file.Sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	w.mu.Unlock()
+
+	w.millWG.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err == nil {
+		err = w.millErr
+	}
+	return err
+}
+
+// startMill compresses and removes backups in the background, as needed.
+// The caller must hold mu.
+func (w *Writer) startMill() {
+	if !w.opts.Compress && w.opts.MaxBackups <= 0 && w.opts.MaxAge <= 0 {
+		return
+	}
+	w.millWG.Add(1)
+	go func() {
+		defer w.millWG.Done()
+		w.millMu.Lock()
+		err := w.mill()
+		w.millMu.Unlock()
+		if err != nil {
+			w.mu.Lock()
+			if w.millErr == nil {
+				w.millErr = err
+			}
+			w.mu.Unlock()
+		}
+	}()
+}
+
+// backup describes a backup file.
+type backup struct {
+	name       string
+	time       time.Time
+	compressed bool
+}
+
+// backups lists the backups of the file, newest first.
+func (w *Writer) backups() ([]backup, error) {
+	dir, prefix, ext := w.nameParts()
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var list []backup
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		b := backup{name: filepath.Join(dir, name)}
+		if strings.HasSuffix(name, compressSuffix) {
+			b.compressed = true
+			name = strings.TrimSuffix(name, compressSuffix)
+		}
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, name[len(prefix):len(name)-len(ext)])
+		if err != nil {
+			continue
+		}
+		b.time = t
+		list = append(list, b)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].time.After(list[j].time)
+	})
+	return list, nil
+}
+
+// mill compresses backups and removes old ones.  The caller must hold millMu.
+func (w *Writer) mill() error {
+	list, err := w.backups()
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	keep := list[:0]
+	cutoff := w.opts.Clock().Add(-w.opts.MaxAge)
+	for _, b := range list {
+		n := len(keep)
+		if n > 0 && keep[n-1].time.Equal(b.time) {
+			// An uncompressed copy, left by an interrupted compression.
+			if !b.compressed {
+				keep[n-1] = b
+			}
+			continue
+		}
+		if (w.opts.MaxBackups > 0 && n >= w.opts.MaxBackups) || (w.opts.MaxAge > 0 && b.time.Before(cutoff)) {
+			if err := removeBackup(b.name); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		keep = append(keep, b)
+	}
+
+	if w.opts.Compress {
+		for _, b := range keep {
+			if b.compressed {
+				continue
+			}
+			if err := compress(b.name); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// removeBackup removes a backup, with or without its compressed copy.
+func removeBackup(name string) error {
+	err := os.Remove(name)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if !strings.HasSuffix(name, compressSuffix) {
+		if gzErr := os.Remove(name + compressSuffix); err == nil && !errors.Is(gzErr, os.ErrNotExist) {
+			err = gzErr
+		}
+	}
+	return err
+}
+
+// compress compresses a file into a copy with compressSuffix, and removes it.
+func compress(name string) error {
+	if err := gzipFile(name); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+// gzipFile writes a compressed copy of a file, with compressSuffix.
+func gzipFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file, so that an interrupted compression does not
+	// leave a truncated backup.
+	tmp := name + compressSuffix + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+compressSuffix)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,397 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clock is a fake clock for tests.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2026, time.October, 18, 10, 30, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// readDir returns the contents of the files in dir, decompressed, by name.
+func readDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, entry := range entries {
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(entry.Name(), compressSuffix) {
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("%s: %v", entry.Name(), err)
+			}
+			r = zr
+		}
+		data, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", entry.Name(), err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func write(t *testing.T, w io.Writer, s string) {
+	t.Helper()
+	if n, err := io.WriteString(w, s); err != nil || n != len(s) {
+		t.Fatalf("write %q: wrote %d bytes, error %v", s, n, err)
+	}
+}
+
+func checkFiles(t *testing.T, dir string, expect map[string]string) {
+	t.Helper()
+	if got := readDir(t, dir); !reflect.DeepEqual(got, expect) {
+		t.Errorf("\nexpected %q\n     got %q", expect, got)
+	}
+}
+
+func TestSize(t *testing.T) {
+	dir := t.TempDir()
+	clk := newClock()
+	w, err := New(filepath.Join(dir, "app.log"), Options{MaxSize: 10, Clock: clk.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "one\n")
+	write(t, w, "two\n")
+	clk.Advance(time.Second)
+	write(t, w, "three\n") // 14 bytes is too many
+	write(t, w, "a very long line\n")
+	write(t, w, "four\n") // another rotation at the same time
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, map[string]string{
+		"app-2026-10-18T10-30-01.000.log": "one\ntwo\n",
+		"app-2026-10-18T10-30-01.001.log": "three\n",
+		"app-2026-10-18T10-30-01.002.log": "a very long line\n",
+		"app.log":                         "four\n",
+	})
+}
+
+func TestInterval(t *testing.T) {
+	dir := t.TempDir()
+	clk := newClock()
+	w, err := New(filepath.Join(dir, "app"), Options{Interval: time.Hour, Clock: clk.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "a\n")
+	clk.Advance(29 * time.Minute)
+	write(t, w, "b\n")
+	clk.Advance(time.Minute)
+	write(t, w, "c\n")
+	clk.Advance(3 * time.Hour) // no empty backups for missed hours
+	write(t, w, "d\n")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, map[string]string{
+		"app-2026-10-18T11-00-00.000": "a\nb\n",
+		"app-2026-10-18T14-00-00.000": "c\n",
+		"app":                         "d\n",
+	})
+}
+
+func TestIntervalExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clk := newClock()
+	yesterday := clk.Now().Add(-24 * time.Hour)
+	if err := os.Chtimes(path, yesterday, yesterday); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(path, Options{Interval: 24 * time.Hour, Clock: clk.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "new\n")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, map[string]string{
+		"app-2026-10-18T10-30-00.000.log": "old\n",
+		"app.log":                         "new\n",
+	})
+}
+
+func TestRetention(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   Options
+		expect []string
+	}{{
+		name:   "count",
+		opts:   Options{MaxBackups: 2},
+		expect: []string{"app-2026-10-18T10-34-00.000.log", "app-2026-10-18T10-35-00.000.log", "app.log"},
+	}, {
+		name: "age",
+		opts: Options{MaxAge: 150 * time.Second},
+		expect: []string{"app-2026-10-18T10-33-00.000.log", "app-2026-10-18T10-34-00.000.log",
+			"app-2026-10-18T10-35-00.000.log", "app.log"},
+	}, {
+		name: "compressed",
+		opts: Options{MaxBackups: 3, Compress: true},
+		expect: []string{"app-2026-10-18T10-33-00.000.log.gz", "app-2026-10-18T10-34-00.000.log.gz",
+			"app-2026-10-18T10-35-00.000.log.gz", "app.log"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// Someone else's files are never removed.
+			for _, name := range []string{"other.log", "app-notatime.log", "app-2020-01-01T00-00-00.000.txt"} {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			clk := newClock()
+			tc.opts.Clock = clk.Now
+			w, err := New(filepath.Join(dir, "app.log"), tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 5; i++ {
+				write(t, w, fmt.Sprintf("%d\n", i))
+				clk.Advance(time.Minute)
+				if err := w.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write(t, w, "last\n")
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			files := readDir(t, dir)
+			var got []string
+			for name := range files {
+				if strings.HasPrefix(name, "app") && !strings.Contains(name, "notatime") && !strings.HasSuffix(name, ".txt") {
+					got = append(got, name)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("\nexpected %q\n     got %q", tc.expect, got)
+			}
+			last := "app-2026-10-18T10-35-00.000.log"
+			if tc.opts.Compress {
+				last += compressSuffix
+			}
+			if files[last] != "4\n" {
+				t.Errorf("wrong content of the last backup: %q", files)
+			}
+			for _, name := range []string{"other.log", "app-notatime.log", "app-2020-01-01T00-00-00.000.txt"} {
+				if _, found := files[name]; !found {
+					t.Errorf("%s was removed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	// As if compressing the backup was interrupted, after the compressed
+	// copy was written but before the original was removed.
+	backup := filepath.Join(dir, "app-2026-10-18T10-00-00.000.log")
+	if err := os.WriteFile(backup, []byte("backup\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(backup+compressSuffix, []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clk := newClock()
+	w, err := New(filepath.Join(dir, "app.log"), Options{Compress: true, Clock: clk.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "rotated\n")
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, map[string]string{
+		"app-2026-10-18T10-00-00.000.log.gz": "backup\n",
+		"app-2026-10-18T10-30-00.000.log.gz": "rotated\n",
+		"app.log":                            "",
+	})
+}
+
+func TestRotateFailed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("long paths are limited differently on Windows")
+	}
+	dir := t.TempDir()
+	// The name of a backup is too long, so the file can not be renamed.
+	name := strings.Repeat("a", 240) + ".log"
+	w, err := New(filepath.Join(dir, name), Options{MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "one\n")
+	for _, line := range []string{"a long line\n", "two\n"} {
+		n, err := io.WriteString(w, line)
+		if err == nil {
+			t.Errorf("write %q: expected an error", line)
+		}
+		if n != len(line) {
+			t.Errorf("write %q: wrote %d bytes", line, n)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, map[string]string{
+		name: "one\na long line\ntwo\n",
+	})
+}
+
+func TestReopen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("open files can not be renamed on Windows")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := New(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "before\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "renamed\n")
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "after\n")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, dir, map[string]string{
+		"app.log.1": "before\nrenamed\n",
+		"app.log":   "after\n",
+	})
+}
+
+func TestClose(t *testing.T) {
+	w, err := New(filepath.Join(t.TempDir(), "sub", "dir", "app.log"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write: expected %v, got %v", os.ErrClosed, err)
+	}
+	if err := w.Rotate(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Rotate: expected %v, got %v", os.ErrClosed, err)
+	}
+	if err := w.Reopen(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Reopen: expected %v, got %v", os.ErrClosed, err)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(filepath.Join(dir, "app.log"), Options{MaxSize: 100, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				fmt.Fprintf(w, "line %d %d\n", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := map[string]bool{}
+	for name, data := range readDir(t, dir) {
+		if name != "app.log" && !strings.HasSuffix(name, compressSuffix) {
+			t.Errorf("%s was not compressed", name)
+		}
+		if len(data) > 100 {
+			t.Errorf("%s is too big: %d bytes", name, len(data))
+		}
+		for _, line := range strings.SplitAfter(data, "\n") {
+			if line != "" {
+				lines[line] = true
+			}
+		}
+	}
+	if len(lines) != 500 {
+		t.Errorf("expected 500 lines, got %d", len(lines))
+	}
+}
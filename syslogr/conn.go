@@ -0,0 +1,174 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syslogr
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// localPaths are where the local syslog daemon listens, in the order they are
+// tried.
+var localPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// timeout limits how long connecting and each write may take, so that a
+// stuck server does not block logging forever.
+const timeout = 10 * time.Second
+
+// Conn sends syslog messages to a syslog daemon or server.  Each call to
+// Write sends one message.  Over TCP, messages are framed by octet counting,
+// as defined by RFC 6587: each is preceded by its length in bytes and a
+// space.  Over Unix stream sockets, which local daemons read line by line,
+// each message is followed by a newline instead, so a message which contains
+// newlines is split.  Over datagram sockets, like UDP, each message is one
+// datagram.
+//
+// If a write fails, Conn reconnects and tries once more.  If that fails too,
+// the message is lost, and the next Write reconnects again.  A Conn is safe
+// for concurrent use.
+type Conn struct {
+	network string // empty for the local daemon
+	addr    string
+
+	mu      sync.Mutex
+	conn    net.Conn // nil while disconnected
+	framing framing  // of conn
+	closed  bool
+}
+
+// Dial connects to a syslog server at addr, over network, which is one of the
+// networks supported by net.Dial, like "udp", "tcp", "unix" or "unixgram".  If
+// network and addr are both empty, Dial connects to the local syslog daemon,
+// at /dev/log, /var/run/syslog or /var/run/log.
+func Dial(network, addr string) (*Conn, error) {
+	c := &Conn{network: network, addr: addr}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect (re)connects c.  It must be called with c.mu held, or before c is
+// shared.
+func (c *Conn) connect() error {
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	if c.network != "" || c.addr != "" {
+		conn, err := net.DialTimeout(c.network, c.addr, timeout)
+		if err != nil {
+			return err
+		}
+		c.conn, c.framing = conn, framingOf(c.network)
+		return nil
+	}
+	var errs []error
+	for _, path := range localPaths {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.DialTimeout(network, path, timeout)
+			if err == nil {
+				c.conn, c.framing = conn, framingOf(network)
+				return nil
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.New("no local syslog daemon found: " + errs[0].Error())
+}
+
+// framing is how messages are delimited on a connection.
+type framing int
+
+const (
+	datagram      framing = iota // one message per datagram
+	octetCounting                // preceded by the length and a space
+	newline                      // followed by a newline
+)
+
+func framingOf(network string) framing {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return octetCounting
+	case "unix":
+		return newline
+	}
+	return datagram
+}
+
+// Write sends one message.
+func (c *Conn) Write(msg []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.conn == nil || attempt > 0 {
+			if err = c.connect(); err != nil {
+				continue
+			}
+		}
+		if err = c.send(msg); err == nil {
+			return len(msg), nil
+		}
+	}
+	return 0, err
+}
+
+// send writes one message to c.conn, which must not be nil.
+func (c *Conn) send(msg []byte) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	var frame []byte
+	switch c.framing {
+	case octetCounting:
+		frame = make([]byte, 0, len(msg)+8)
+		frame = strconv.AppendInt(frame, int64(len(msg)), 10)
+		frame = append(frame, ' ')
+		frame = append(frame, msg...)
+	case newline:
+		frame = make([]byte, 0, len(msg)+1)
+		frame = append(frame, msg...)
+		frame = append(frame, '\n')
+	default:
+		frame = msg
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close closes the connection.  Writes after Close fail with net.ErrClosed.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syslogr
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// readFrame reads one octet-counted message.
+func readFrame(r *bufio.Reader) (string, error) {
+	n, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	size, err := strconv.Atoi(n[:len(n)-1])
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// readLine reads one message followed by a newline.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return line[:len(line)-1], nil
+}
+
+// streamServer accepts connections and sends the messages it reads to msgs,
+// framed by octet counting over TCP, and by newlines over Unix sockets.  Each
+// connection is handled by handle, if set, before its messages are read.
+func streamServer(t *testing.T, network, addr string, handle func(i int, conn net.Conn) bool) (net.Listener, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	read := readFrame
+	if network == "unix" {
+		read = readLine
+	}
+	msgs := make(chan string, 100)
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if handle != nil && !handle(i, conn) {
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					msg, err := read(r)
+					if err != nil {
+						return
+					}
+					msgs <- msg
+				}
+			}()
+		}
+	}()
+	return ln, msgs
+}
+
+// packetServer sends the datagrams it receives to msgs.
+func packetServer(t *testing.T, network, addr string) (net.PacketConn, <-chan string) {
+	t.Helper()
+	pc, err := net.ListenPacket(network, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	msgs := make(chan string, 100)
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			msgs <- string(buf[:n])
+		}
+	}()
+	return pc, msgs
+}
+
+func receive(t *testing.T, msgs <-chan string, expect ...string) {
+	t.Helper()
+	for _, e := range expect {
+		select {
+		case got := <-msgs:
+			if got != e {
+				t.Errorf("expected %q, got %q", e, got)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for %q", e)
+		}
+	}
+}
+
+func dial(t *testing.T, network, addr string) *Conn {
+	t.Helper()
+	c, err := Dial(network, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestConnTCP(t *testing.T) {
+	ln, msgs := streamServer(t, "tcp", "127.0.0.1:0", nil)
+	log := New(dial(t, "tcp", ln.Addr().String()), testOptions)
+	log.Info("one")
+	log.Info("multi\nline")
+	receive(t, msgs,
+		`<14>1 2006-01-02T15:04:05.123456-07:00 host app 42 - - one`,
+		"<14>1 2006-01-02T15:04:05.123456-07:00 host app 42 - - multi\nline")
+}
+
+func TestConnUDP(t *testing.T) {
+	pc, msgs := packetServer(t, "udp", "127.0.0.1:0")
+	log := New(dial(t, "udp", pc.LocalAddr().String()), testOptions)
+	log.Info("one", "k", "v")
+	receive(t, msgs, `<14>1 2006-01-02T15:04:05.123456-07:00 host app 42 - [logr@32473 k="v"] one`)
+}
+
+func TestConnUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix datagram sockets are not supported on Windows")
+	}
+	dir := t.TempDir()
+	gram := filepath.Join(dir, "log")
+	_, gramMsgs := packetServer(t, "unixgram", gram)
+	stream := filepath.Join(dir, "syslog")
+	_, streamMsgs := streamServer(t, "unix", stream, nil)
+
+	saved := localPaths
+	defer func() { localPaths = saved }()
+
+	localPaths = []string{filepath.Join(dir, "missing"), gram}
+	c := dial(t, "", "")
+	if _, err := c.Write([]byte("datagram")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, gramMsgs, "datagram")
+
+	localPaths = []string{stream}
+	c = dial(t, "", "")
+	if _, err := c.Write([]byte("stream")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, streamMsgs, "stream")
+
+	c = dial(t, "unix", stream)
+	for _, msg := range []string{"one", "two"} {
+		if _, err := c.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive(t, streamMsgs, "one", "two")
+
+	localPaths = []string{filepath.Join(dir, "missing")}
+	if _, err := Dial("", ""); err == nil {
+		t.Error("expected an error without a local daemon")
+	}
+}
+
+func TestConnReconnect(t *testing.T) {
+	// The server drops the first connection.
+	ln, msgs := streamServer(t, "tcp", "127.0.0.1:0", func(i int, conn net.Conn) bool {
+		if i == 0 {
+			conn.Close()
+			return false
+		}
+		return true
+	})
+	c := dial(t, "tcp", ln.Addr().String())
+
+	// Writes to the dropped connection may succeed before the client
+	// notices, so keep writing until one arrives.
+	deadline := time.After(10 * time.Second)
+	for {
+		if _, err := c.Write([]byte("retry")); err != nil {
+			t.Fatalf("write was not retried: %v", err)
+		}
+		select {
+		case msg := <-msgs:
+			if msg != "retry" {
+				t.Fatalf("expected %q, got %q", "retry", msg)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("did not reconnect")
+		}
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	ln, _ := streamServer(t, "tcp", "127.0.0.1:0", nil)
+	c := dial(t, "tcp", ln.Addr().String())
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if _, err := c.Write([]byte("x")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected %v, got %v", net.ErrClosed, err)
+	}
+}
+
+func TestDialError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	if _, err := Dial("tcp", addr); err == nil {
+		t.Error("expected an error without a server")
+	}
+}
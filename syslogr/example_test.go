@@ -0,0 +1,61 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syslogr_test
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr/syslogr"
+)
+
+// stdout writes each message on its own line.
+type stdout struct{}
+
+func (stdout) Write(msg []byte) (int, error) {
+	return fmt.Println(string(msg))
+}
+
+func ExampleNew() {
+	log := syslogr.New(stdout{}, syslogr.Options{
+		Hostname: "example.com",
+		AppName:  "example",
+		ProcID:   "1234",
+		Clock:    func() time.Time { return time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC) },
+	})
+	log = log.WithName("server")
+	log.Info("started", "port", 8080)
+	log.Error(os.ErrNotExist, "failed to open", "path", "/etc/example.conf")
+	// Output:
+	// <14>1 2006-01-02T15:04:05.000000Z example.com example 1234 server [logr@32473 port="8080"] started
+	// <11>1 2006-01-02T15:04:05.000000Z example.com example 1234 server [logr@32473 error="file does not exist" path="/etc/example.conf"] failed to open
+}
+
+func ExampleDial() {
+	// Send to the local syslog daemon, or to a server with
+	// syslogr.Dial("tcp", "logs.example.com:6514").
+	conn, err := syslogr.Dial("", "")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer conn.Close()
+
+	log := syslogr.New(conn, syslogr.Options{Facility: syslogr.FacilityDaemon})
+	log.Info("hello", "to", "syslog")
+}
@@ -0,0 +1,353 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package syslogr implements github.com/go-logr/logr.Logger in terms of
+// syslog messages, as defined by RFC 5424.
+//
+// Each log line is one message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID key="value"...] MSG
+//
+// The severity in PRI is Options.LevelSeverity of the V-level for Info logs,
+// and SeverityError for Error logs.  The logger name is the MSGID or, with
+// Options.NameToAppName, the APP-NAME.  Key-value pairs, including the error
+// of Error logs, are parameters of a single STRUCTURED-DATA element: strings
+// as-is, and other values as JSON, as rendered by funcr.  Header fields and
+// parameter names are truncated to the lengths allowed by RFC 5424, and
+// characters which are not allowed there are replaced by '_'.  Invalid UTF-8
+// in parameter values is replaced by U+FFFD.
+//
+// The message is written as MSG as given, without the byte order mark which
+// RFC 5424 asks for to mark MSG as UTF-8: common receivers, like rsyslog and
+// syslog-ng, keep it as part of the message text.
+//
+// Messages are written to an io.Writer, one message per call to Write.  Dial
+// returns a Conn, which sends them to the local syslog daemon or to a server
+// over UDP or TCP.
+package syslogr
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/go-logr/logr/internal/render"
+)
+
+// Severity is the severity of a syslog message.
+type Severity int
+
+// The severities defined by RFC 5424.
+const (
+	SeverityEmergency Severity = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+// Facility is the facility of a syslog message.
+type Facility int
+
+// The facilities defined by RFC 5424.
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLPR
+	FacilityNews
+	FacilityUUCP
+	FacilityCron
+	FacilityAuthPriv
+	FacilityFTP
+	FacilityNTP
+	FacilityAudit
+	FacilityAlert
+	FacilityClock
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// Options carries parameters which influence the way logs are generated.
+type Options struct {
+	// Facility is the facility of all messages.  If not specified,
+	// FacilityUser is used.  FacilityKern is reserved for the kernel, so
+	// it can not be specified.
+	Facility Facility
+
+	// LevelSeverity tells the logger which severity to use for Info logs
+	// of a V-level.  If not specified, V(0) logs are SeverityInfo and
+	// higher levels are SeverityDebug.
+	LevelSeverity func(level int) Severity
+
+	// Hostname is the HOSTNAME of all messages.  If not specified,
+	// os.Hostname is used.
+	Hostname string
+
+	// AppName is the APP-NAME of all messages, or of messages without a
+	// logger name when NameToAppName is set.  If not specified, the base
+	// name of the executable is used.
+	AppName string
+
+	// ProcID is the PROCID of all messages.  If not specified, the process
+	// ID is used.
+	ProcID string
+
+	// NameToAppName tells the logger to write the logger name as the
+	// APP-NAME, rather than as the MSGID.
+	NameToAppName bool
+
+	// SDID is the SD-ID of the STRUCTURED-DATA element which holds the
+	// key-value pairs.  If not specified, "logr@32473" is used.  32473 is
+	// the private enterprise number reserved for documentation, so
+	// programs which log to shared servers should use their own.
+	SDID string
+
+	// Clock tells the logger how to get the current time, for the
+	// TIMESTAMP.  If not specified, time.Now is used.
+	Clock func() time.Time
+
+	// Verbosity tells the logger which V logs to write.  Higher values
+	// enable more logs.
+	Verbosity int
+}
+
+// DefaultSDID is the SD-ID used when Options.SDID is not specified.
+const DefaultSDID = "logr@32473"
+
+// The maximum lengths of header fields and SD-NAMEs, from RFC 5424.
+const (
+	maxHostname = 255
+	maxAppName  = 48
+	maxProcID   = 128
+	maxMsgID    = 32
+	maxSDName   = 32
+)
+
+// timestampFormat is RFC 3339 with the precision allowed by RFC 5424.
+const timestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// nilValue is written for empty header fields.
+const nilValue = "-"
+
+// New returns a logr.Logger which writes syslog messages to w, one message
+// per call to w.Write.  Writes are serialized, so w need not be safe for
+// concurrent use.  Errors from w are ignored.
+func New(w io.Writer, opts Options) logr.Logger {
+	if opts.Facility == 0 {
+		opts.Facility = FacilityUser
+	}
+	if opts.LevelSeverity == nil {
+		opts.LevelSeverity = defaultLevelSeverity
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.AppName == "" && len(os.Args) > 0 {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	if opts.ProcID == "" {
+		opts.ProcID = strconv.Itoa(os.Getpid())
+	}
+	if opts.SDID == "" {
+		opts.SDID = DefaultSDID
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	return logr.New(&sink{
+		opts: &opts,
+		out:  &output{w: w},
+		fmtr: funcr.NewFormatterJSON(render.WithoutBuiltins(funcr.Options{StrictJSON: true})),
+	})
+}
+
+func defaultLevelSeverity(level int) Severity {
+	if level == 0 {
+		return SeverityInfo
+	}
+	return SeverityDebug
+}
+
+// output serializes the messages of a logger and those derived from it.
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *output) write(msg []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, _ = o.w.Write(msg)
+}
+
+// sink implements logr.LogSink.
+type sink struct {
+	opts   *Options
+	out    *output
+	name   string
+	values []any
+	fmtr   funcr.Formatter // renders values
+}
+
+var _ logr.LogSink = &sink{}
+
+func (*sink) Init(logr.RuntimeInfo) {}
+
+func (l *sink) Enabled(level int) bool {
+	return level <= l.opts.Verbosity
+}
+
+func (l *sink) Info(level int, msg string, kvList ...any) {
+	l.log(l.opts.LevelSeverity(level), msg, nil, kvList)
+}
+
+func (l *sink) Error(err error, msg string, kvList ...any) {
+	l.log(SeverityError, msg, err, kvList)
+}
+
+func (l sink) WithName(name string) logr.LogSink {
+	if l.name != "" {
+		l.name += "/"
+	}
+	l.name += name
+	return &l
+}
+
+func (l sink) WithValues(kvList ...any) logr.LogSink {
+	l.values = render.AppendValues(l.values, kvList)
+	return &l
+}
+
+// log writes one message.
+func (l *sink) log(sev Severity, msg string, err error, kvList []any) {
+	appName, msgID := l.opts.AppName, l.name
+	if l.opts.NameToAppName {
+		if l.name != "" {
+			appName = l.name
+		}
+		msgID = ""
+	}
+
+	buf := make([]byte, 0, 256)
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(l.opts.Facility)*8+int64(sev), 10)
+	buf = append(buf, ">1 "...)
+	buf = l.opts.Clock().AppendFormat(buf, timestampFormat)
+	for _, field := range []struct {
+		value  string
+		maxLen int
+	}{
+		{l.opts.Hostname, maxHostname},
+		{appName, maxAppName},
+		{l.opts.ProcID, maxProcID},
+		{msgID, maxMsgID},
+	} {
+		buf = append(buf, ' ')
+		buf = appendName(buf, field.value, field.maxLen, false)
+	}
+
+	buf = append(buf, ' ')
+	start := len(buf)
+	buf = append(buf, '[')
+	buf = appendName(buf, l.opts.SDID, maxSDName, true)
+	params := 0
+	if err != nil {
+		buf = l.appendParam(buf, "error", err)
+		params++
+	}
+	for _, kvs := range [][]any{l.values, kvList} {
+		for i := 0; i < len(kvs); i += 2 {
+			k := render.Key(l.fmtr, kvs[i])
+			var v any = render.NoValue
+			if i+1 < len(kvs) {
+				v = kvs[i+1]
+			}
+			buf = l.appendParam(buf, k, v)
+			params++
+		}
+	}
+	if params == 0 {
+		// An element without parameters is allowed, but it is just noise.
+		buf = append(buf[:start], nilValue...)
+	} else {
+		buf = append(buf, ']')
+	}
+
+	if msg != "" {
+		buf = append(buf, ' ')
+		buf = append(buf, msg...)
+	}
+	l.out.write(buf)
+}
+
+// appendParam appends an SD-PARAM, with a leading space.
+func (l *sink) appendParam(buf []byte, key string, value any) []byte {
+	buf = append(buf, ' ')
+	buf = appendName(buf, key, maxSDName, true)
+	buf = append(buf, `="`...)
+	v := strings.ToValidUTF8(render.Text(l.fmtr, value), "\uFFFD")
+	for _, r := range []byte(v) {
+		if r == '"' || r == '\\' || r == ']' {
+			buf = append(buf, '\\')
+		}
+		buf = append(buf, r)
+	}
+	return append(buf, '"')
+}
+
+// appendName appends a header field or, if sd is set, an SD-NAME: at most
+// maxLen printable US-ASCII characters, and for SD-NAMEs none of the ones
+// which delimit structured data.  Other characters are replaced by '_'.  An
+// empty header field is written as the NILVALUE, and an empty SD-NAME as
+// "_".
+func appendName(buf []byte, name string, maxLen int, sd bool) []byte {
+	if name == "" {
+		if sd {
+			return append(buf, '_')
+		}
+		return append(buf, nilValue...)
+	}
+	if len(name) > maxLen {
+		name = name[:maxLen]
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c <= ' ' || c > '~' || sd && (c == '=' || c == ']' || c == '"') {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2026 The logr Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syslogr
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type point struct{ X, Y int }
+
+// messages records each write as one message.
+type messages struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (m *messages) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.msgs = append(m.msgs, string(p))
+	return len(p), nil
+}
+
+func (m *messages) get() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.msgs
+}
+
+var testOptions = Options{
+	Hostname: "host",
+	AppName:  "app",
+	ProcID:   "42",
+	Clock: func() time.Time {
+		return time.Date(2006, time.January, 2, 15, 4, 5, 123456789, time.FixedZone("", -7*3600))
+	},
+}
+
+func TestLogger(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   func(*Options)
+		log    func(*messages, Options)
+		expect []string
+	}{{
+		name: "info",
+		log: func(m *messages, opts Options) {
+			log := New(m, opts)
+			log.Info("hello", "k", "v", "n", 1)
+			log.Info("")
+			log.V(1).Info("not logged")
+		},
+		expect: []string{
+			`<14>1 2006-01-02T15:04:05.123456-07:00 host app 42 - [logr@32473 k="v" n="1"] hello`,
+			`<14>1 2006-01-02T15:04:05.123456-07:00 host app 42 - -`,
+		},
+	}, {
+		name: "verbosity",
+		opts: func(opts *Options) { opts.Verbosity = 2 },
+		log: func(m *messages, opts Options) {
+			log := New(m, opts)
+			log.V(1).Info("one")
+			log.V(2).Info("two")
+			log.V(3).Info("three")
+		},
+		expect: []string{
+			`<15>1 2006-01-02T15:04:05.123456-07:00 host app 42 - - one`,
+			`<15>1 2006-01-02T15:04:05.123456-07:00 host app 42 - - two`,
+		},
+	}, {
+		name: "severity and facility",
+		opts: func(opts *Options) {
+			opts.Facility = FacilityLocal3
+			opts.Verbosity = 1
+			opts.LevelSeverity = func(level int) Severity { return SeverityNotice + Severity(level) }
+		},
+		log: func(m *messages, opts Options) {
+			log := New(m, opts)
+			log.Info("notice")
+			log.V(1).Info("info")
+			log.Error(nil, "error")
+		},
+		expect: []string{
+			`<157>1 2006-01-02T15:04:05.123456-07:00 host app 42 - - notice`,
+			`<158>1 2006-01-02T15:04:05.123456-07:00 host app 42 - - info`,
+			`<155>1 2006-01-02T15:04:05.123456-07:00 host app 42 - - error`,
+		},
+	}, {
+		name: "error",
+		log: func(m *messages, opts Options) {
+			New(m, opts).Error(fmt.Errorf(`bad "quote" [x] \ y`), "failed", "k", "v")
+		},
+		expect: []string{
+			`<11>1 2006-01-02T15:04:05.123456-07:00 host app 42 - [logr@32473 error="bad \"quote\" [x\] \\ y" k="v"] failed`,
+		},
+	}, {
+		name: "name as msgid",
+		log: func(m *messages, opts Options) {
+			log := New(m, opts).WithName("server")
+			log.Info("a")
+			log.WithName("handler with spaces and a very long name").Info("b")
+		},
+		expect: []string{
+			`<14>1 2006-01-02T15:04:05.123456-07:00 host app 42 server - a`,
+			`<14>1 2006-01-02T15:04:05.123456-07:00 host app 42 server/handler_with_spaces_and_a - b`,
+		},
+	}, {
+		name: "name as app-name",
+		opts: func(opts *Options) { opts.NameToAppName = true },
+		log: func(m *messages, opts Options) {
+			log := New(m, opts)
+			log.Info("a")
+			log.WithName("server").Info("b")
+		},
+		expect: []string{
+			`<14>1 2006-01-02T15:04:05.123456-07:00 host app 42 - - a`,
+			`<14>1 2006-01-02T15:04:05.123456-07:00 host server 42 - - b`,
+		},
+	}, {
+		name: "values",
+		opts: func(opts *Options) { opts.SDID = "values@12345" },
+		log: func(m *messages, opts Options) {
+			log := New(m, opts).WithValues("user", "alice")
+			log.Info("values",
+				"point", point{1, 2},
+				"list", []string{"a", "b"},
+				"map", map[string]int{"x": 1},
+				"nil", nil,
+				"time", time.Duration(1500)*time.Millisecond,
+				"key=with]odd\"chars", "multi\nline",
+				"ünïcode", "ünïcode",
+				"invalid", "a\xff\xfeb",
+				42, "bad key",
+				"odd")
+		},
+		expect: []string{
+			`<14>1 2006-01-02T15:04:05.123456-07:00 host app 42 - [values@12345 user="alice" ` +
+				`point="{\"X\":1,\"Y\":2}" list="[\"a\",\"b\"\]" map="{\"x\":1}" nil="null" time="1.5s" ` +
+				`key_with_odd_chars="multi` + "\n" + `line" __n__code="ünïcode" invalid="a` + "\uFFFD" + `b" ` +
+				`<non-string-key:_42>="bad key" odd="<no-value>"] values`,
+		},
+	}, {
+		name: "header fields",
+		opts: func(opts *Options) {
+			opts.Hostname = "host with spaces"
+			opts.AppName = strings.Repeat("a", 50)
+			opts.ProcID = "ü"
+		},
+		log: func(m *messages, opts Options) {
+			New(m, opts).Info("msg")
+		},
+		expect: []string{
+			`<14>1 2006-01-02T15:04:05.123456-07:00 host_with_spaces ` + strings.Repeat("a", 48) + ` __ - - msg`,
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := testOptions
+			if tc.opts != nil {
+				tc.opts(&opts)
+			}
+			m := &messages{}
+			tc.log(m, opts)
+			if got := m.get(); !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("\nexpected:\n%s\n     got:\n%s", strings.Join(tc.expect, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	m := &messages{}
+	New(m, Options{}).Info("msg")
+	msgs := m.get()
+	if len(msgs) != 1 {
+		t.Fatalf("expected one message, got %q", msgs)
+	}
+	fields := strings.SplitN(msgs[0], " ", 7)
+	if len(fields) != 7 {
+		t.Fatalf("malformed message %q", msgs[0])
+	}
+	if fields[0] != "<14>1" {
+		t.Errorf("expected PRI and version <14>1, got %q", fields[0])
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, fields[1]); err != nil {
+		t.Errorf("invalid timestamp: %v", err)
+	} else if d := time.Since(ts); d < 0 || d > time.Minute {
+		t.Errorf("timestamp %s is not now", ts)
+	}
+	if hostname, _ := os.Hostname(); hostname != "" && fields[2] != hostname {
+		t.Errorf("expected hostname %q, got %q", hostname, fields[2])
+	}
+	if fields[3] == nilValue {
+		t.Errorf("expected the executable as APP-NAME")
+	}
+	if pid := strconv.Itoa(os.Getpid()); fields[4] != pid {
+		t.Errorf("expected PROCID %s, got %q", pid, fields[4])
+	}
+	if fields[5] != "-" || fields[6] != "- msg" {
+		t.Errorf("expected no MSGID or STRUCTURED-DATA, got %q", msgs[0])
+	}
+}
+
+func TestLoggerConcurrent(t *testing.T) {
+	m := &messages{}
+	log := New(m, testOptions)
+
+	const n = 100
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			log.WithValues("i", i).Info("msg")
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, msg := range m.get() {
+		seen[msg] = true
+	}
+	if len(seen) != n {
+		t.Errorf("expected %d distinct messages, got %d", n, len(seen))
+	}
+}
@@ -19,6 +19,7 @@ package testr
 
 import (
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/go-logr/logr/funcr"
@@ -40,6 +41,10 @@ type Options struct {
 	// Verbosity tells the logger which V logs to be write.
 	// Higher values enable more logs.
 	Verbosity int
+
+	// Clock tells the logger how to get the current time, for
+	// timestamps. If not specified, time.Now is used.
+	Clock func() time.Time
 }
 
 // NewWithOptions returns a logr.Logger that prints through a testing.T object.
@@ -71,6 +76,7 @@ func newLoggerInterfaceWithOptions(t TestingT, opts Options) testloggerInterface
 		Formatter: funcr.NewFormatter(funcr.Options{
 			LogTimestamp: opts.LogTimestamp,
 			Verbosity:    opts.Verbosity,
+			Clock:        opts.Clock,
 		}),
 	}
 }
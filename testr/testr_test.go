@@ -19,6 +19,7 @@ package testr
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 )
@@ -71,6 +72,32 @@ func TestLoggerInterface(t *testing.T) {
 	}
 }
 
+// fakeT records the lines logged through it.
+type fakeT struct {
+	lines []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Log(args ...any) {
+	f.lines = append(f.lines, fmt.Sprint(args...))
+}
+
+func TestLoggerClock(t *testing.T) {
+	ft := &fakeT{}
+	log := NewWithInterface(ft, Options{
+		LogTimestamp: true,
+		Clock: func() time.Time {
+			return time.Date(2006, time.January, 2, 15, 4, 5, 0, time.Local)
+		},
+	})
+	log.Info("info")
+	want := `"ts"="2006-01-02 15:04:05.000000" "level"=0 "msg"="info"`
+	if len(ft.lines) != 1 || ft.lines[0] != want {
+		t.Errorf("expected %q, got %q", want, ft.lines)
+	}
+}
+
 func TestLoggerTestingB(_ *testing.T) {
 	b := &testing.B{}
 	_ = NewWithInterface(b, Options{})